@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// abbreviationsByFileType caches the abbreviations loaded for each file type,
+// keyed by the word which is expanded.
+var abbreviationsByFileType = make(map[string]map[string]string)
+
+// editorAbbreviations returns the abbreviations for the type of the open file.
+//
+// They're read from the abbreviations file in the config directory, which
+// contains lines like "teh = the". Those following a line like "[go]" only
+// apply to files of that type. In the expansion, \n starts a new line with the
+// same indentation, and \t at the start of a line is one level of
+// indentation.
+func editorAbbreviations() map[string]string {
+	fileType := ""
+	if e.syntax != nil {
+		fileType = e.syntax.fileType
+	}
+
+	if abbreviations, ok := abbreviationsByFileType[fileType]; ok {
+		return abbreviations
+	}
+
+	abbreviations := make(map[string]string)
+	abbreviationsByFileType[fileType] = abbreviations
+
+	f, err := os.Open(filepath.Join(configDir(), "abbreviations"))
+	if err != nil {
+		return abbreviations
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if name, ok := strings.CutPrefix(line, "["); ok && strings.HasSuffix(name, "]") {
+			section = strings.TrimSpace(strings.TrimSuffix(name, "]"))
+			continue
+		}
+
+		word, expansion, ok := parseConfigLine(line)
+		if !ok || word == "" || (section != "" && section != fileType) {
+			continue
+		}
+
+		abbreviations[word] = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`).Replace(expansion)
+	}
+
+	return abbreviations
+}
+
+// editorExpandAbbreviation replaces the word before the cursor with its
+// expansion, if it's an abbreviation. It's called before a character which
+// ends a word is inserted.
+func editorExpandAbbreviation(c rune) {
+	if c >= utf8.RuneSelf || !isWordSeparator(byte(c)) || e.cy >= len(e.row) {
+		return
+	}
+
+	start := completionWordStart()
+	word := e.row[e.cy].raw[start:e.cx]
+	if word == "" {
+		return
+	}
+
+	expansion, ok := editorAbbreviations()[word]
+	if !ok {
+		return
+	}
+
+	expansion, _ = indentSnippet(expansion, nil, leadingWhitespace(e.row[e.cy].raw))
+	end := bufferReplaceRange(bufferPos{e.cy, start}, bufferPos{e.cy, e.cx}, expansion)
+	e.cy, e.cx = end.line, end.col
+}
@@ -0,0 +1,62 @@
+package main
+
+import "strings"
+
+// editorAlignCommand pads the selected rows, or the whole file, so that the
+// first occurrence of the delimiter given in args (= by default) is in the
+// same column on each row. Rows without the delimiter aren't changed.
+func editorAlignCommand(args string) {
+	delimiter := args
+	if delimiter == "" {
+		delimiter = "="
+	}
+
+	first, last, ok := editorCommandRows()
+	if !ok {
+		return
+	}
+
+	// The text before the delimiter on each row, without trailing whitespace,
+	// and the width that it's displayed with.
+	type prefix struct {
+		text  string
+		width int
+	}
+	prefixes := make(map[int]prefix)
+
+	column := 0
+	spaced := false
+	for i := first; i <= last; i++ {
+		raw := e.row[i].raw
+		index := strings.Index(raw, delimiter)
+		if index < 0 {
+			continue
+		}
+
+		text := strings.TrimRight(raw[:index], " \t")
+		spaced = spaced || len(text) < index
+
+		p := prefix{text, editorRowCxToRx(e.row[i], len(text))}
+		prefixes[i] = p
+		column = max(column, p.width)
+	}
+
+	if len(prefixes) == 0 {
+		editorSetStatusMessage("No lines contain %q", delimiter)
+		return
+	}
+
+	// Keep a space before the delimiter when there was one on any of the rows.
+	if spaced {
+		column++
+	}
+
+	lines := rowsText(first, last)
+	for i, p := range prefixes {
+		index := len(p.text) + strings.Index(e.row[i].raw[len(p.text):], delimiter)
+		lines[i-first] = p.text + strings.Repeat(" ", column-p.width) + e.row[i].raw[index:]
+	}
+
+	editorReplaceRows(first, last, lines)
+	editorSetStatusMessage("Aligned %d lines on %q", len(prefixes), delimiter)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// binarySniffSize is the number of bytes at the start of a file which are
+// checked to determine whether it's binary.
+const binarySniffSize = 8000
+
+// isBinary returns whether data, the start of a file, looks like it isn't
+// text. Text doesn't contain NUL bytes, and rarely contains other control
+// characters or invalid UTF-8.
+func isBinary(data []byte) bool {
+	unusual := 0
+	total := 0
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		// A character may be cut off at the end.
+		if r == utf8.RuneError && size == 1 && len(data) < utf8.UTFMax {
+			break
+		}
+		data = data[size:]
+		total++
+
+		switch {
+		case r == 0:
+			return true
+		case r == utf8.RuneError && size == 1,
+			r < ' ' && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != '\x1b':
+			unusual++
+		}
+	}
+
+	return total > 0 && unusual*10 > total
+}
+
+// editorCheckBinary asks what to do when the file at path looks binary, since
+// loading it into rows and saving it could corrupt it. It can be viewed
+// read-only, edited in hex mode (after which it's viewed read-only), or not
+// opened at all, in which case ok is false.
+func editorCheckBinary(path string) (readOnly, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		// editorOpen reports the error.
+		return false, true
+	}
+	data := make([]byte, binarySniffSize)
+	n, _ := io.ReadFull(f, data)
+	f.Close()
+
+	if !isBinary(data[:n]) {
+		return false, true
+	}
+
+	for {
+		editorSetPromptMessage("Binary file: open (r)ead-only, in (h)ex mode, or (a)bort?")
+		editorRefreshScreen()
+
+		switch editorReadKey() {
+		case 'r', 'R':
+			editorSetStatusMessage("")
+			return true, true
+		case 'h', 'H':
+			editorSetStatusMessage("")
+			if _, err := editorHexEdit(path); err != nil {
+				editorSetStatusMessage("Can't open %s: %s", path, err.Error())
+				return false, false
+			}
+			return true, true
+		case 'a', 'A', '\x1b':
+			editorSetStatusMessage("")
+			return false, false
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blameInfo describes the commit which last changed a line.
+type blameInfo struct {
+	hash    string
+	author  string
+	time    time.Time
+	summary string
+}
+
+// blameAnnotations indicates whether every row is annotated with the commit
+// which last changed it.
+var blameAnnotations bool
+
+// editorBlameCommand shows the commit which last changed the cursor's row in
+// the status bar. With the argument "all", annotations are shown after every
+// row instead, or hidden if they're already shown.
+func editorBlameCommand(args string) {
+	switch args {
+	case "":
+		editorBlameLine()
+	case "all":
+		editorToggleBlameAnnotations()
+	default:
+		editorSetStatusMessage("Usage: blame [all]")
+	}
+}
+
+// editorBlameLine shows the commit which last changed the cursor's row in the
+// status bar.
+func editorBlameLine() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	blame, err := editorGitBlame(e.cy, e.cy)
+	if err != nil {
+		editorSetStatusMessage("Blame: %s", err.Error())
+		return
+	}
+	if len(blame) == 0 {
+		return
+	}
+
+	b := blame[0]
+	if b.summary == "" {
+		editorSetStatusMessage("%s", formatBlame(b))
+		return
+	}
+	editorSetStatusMessage("%s %s", formatBlame(b), b.summary)
+}
+
+// editorToggleBlameAnnotations shows or hides the commit which last changed
+// each row as virtual text after it.
+func editorToggleBlameAnnotations() {
+	if blameAnnotations {
+		blameAnnotations = false
+		editorShowDiagnosticText()
+		return
+	}
+	if len(e.row) == 0 {
+		return
+	}
+
+	blame, err := editorGitBlame(0, len(e.row)-1)
+	if err != nil {
+		editorSetStatusMessage("Blame: %s", err.Error())
+		return
+	}
+
+	blameAnnotations = true
+	for i, b := range blame {
+		editorSetVirtualText(i, formatBlame(b))
+	}
+}
+
+// formatBlame formats b in a short form, like "1a2b3c4d Jane Doe 2024-05-01".
+func formatBlame(b blameInfo) string {
+	if strings.Trim(b.hash, "0") == "" {
+		return "Not committed yet"
+	}
+
+	return fmt.Sprintf("%.8s %s %s", b.hash, b.author, b.time.Format(time.DateOnly))
+}
+
+// editorGitBlame returns the commit which last changed each row from first to
+// last, inclusive. The rows are used rather than the saved file, so that they
+// line up with it when there are unsaved changes.
+func editorGitBlame(first, last int) ([]blameInfo, error) {
+	if e.filename == "" {
+		return nil, fmt.Errorf("no file name")
+	}
+
+	dir, name := filepath.Split(e.filename)
+	cmd := exec.Command(
+		"git", "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", first+1, last+1),
+		"--contents", "-", "--", name,
+	)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(editorRowsToString())
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+
+	return parseBlame(output), nil
+}
+
+// parseBlame parses the output of git blame --porcelain. Information about
+// each commit is only given the first time that it appears, so it's
+// remembered for the later lines from the same commit.
+func parseBlame(output []byte) []blameInfo {
+	commits := make(map[string]*blameInfo)
+	var blame []blameInfo
+	var current *blameInfo
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// The contents of each line end its entry.
+		if strings.HasPrefix(line, "\t") {
+			if current != nil {
+				blame = append(blame, *current)
+			}
+			current = nil
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if current == nil {
+			if commits[key] == nil {
+				commits[key] = &blameInfo{hash: key}
+			}
+			current = commits[key]
+			continue
+		}
+
+		switch key {
+		case "author":
+			current.author = value
+		case "author-time":
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.time = time.Unix(seconds, 0)
+			}
+		case "summary":
+			current.summary = value
+		}
+	}
+
+	return blame
+}
@@ -0,0 +1,183 @@
+package main
+
+// bracketPairs maps each bracket to the one which matches it.
+var bracketPairs = map[byte]byte{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+}
+
+// renderPos is a position in the file given as an index into the render field
+// of a row.
+type renderPos struct {
+	row, rx int
+}
+
+// matchedBrackets contains the positions of the bracket next to the cursor and
+// the one which matches it, or nothing when there isn't a match.
+var matchedBrackets []renderPos
+
+// editorUpdateBracketMatch finds the bracket which matches the one that the
+// cursor is on, or immediately after, so that both can be highlighted.
+func editorUpdateBracketMatch() {
+	matchedBrackets = matchedBrackets[:0]
+
+	if !e.matchBrackets || e.cy >= len(e.row) {
+		return
+	}
+
+	// The bracket under the cursor takes priority over the one before it.
+	for _, rx := range []int{e.rx, e.rx - 1} {
+		if match, ok := findMatchingBracket(renderPos{e.cy, rx}); ok {
+			matchedBrackets = append(matchedBrackets, renderPos{e.cy, rx}, match)
+			return
+		}
+	}
+}
+
+// editorJumpToMatchingBracket moves the cursor to the bracket which matches the
+// one that it's on, or immediately after.
+func editorJumpToMatchingBracket() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	rx := editorRowCxToRx(e.row[e.cy], e.cx)
+	for _, rx := range []int{rx, rx - 1} {
+		if match, ok := findMatchingBracket(renderPos{e.cy, rx}); ok {
+			editorRecordJump()
+			e.cy = match.row
+			e.cx = editorRowRxToCx(e.row[match.row], match.rx)
+			return
+		}
+	}
+
+	editorSetStatusMessage("No matching bracket")
+}
+
+// findMatchingBracket returns the position of the bracket which matches the
+// one at pos. Brackets inside of strings and comments are ignored.
+func findMatchingBracket(pos renderPos) (renderPos, bool) {
+	row := &e.row[pos.row]
+	if pos.rx < 0 || pos.rx >= len(row.render) || !isCodeHighlight(row.highlight[pos.rx]) {
+		return renderPos{}, false
+	}
+
+	bracket := row.render[pos.rx]
+	match, ok := bracketPairs[bracket]
+	if !ok {
+		return renderPos{}, false
+	}
+
+	dir := 1
+	if bracket == ')' || bracket == ']' || bracket == '}' {
+		dir = -1
+	}
+
+	depth := 0
+	for y := pos.row; y >= 0 && y < len(e.row); y += dir {
+		row := &e.row[y]
+
+		x := 0
+		if y == pos.row {
+			x = pos.rx
+		} else if dir < 0 {
+			x = len(row.render) - 1
+		}
+
+		for ; x >= 0 && x < len(row.render); x += dir {
+			if !isCodeHighlight(row.highlight[x]) {
+				continue
+			}
+
+			switch row.render[x] {
+			case bracket:
+				depth++
+			case match:
+				depth--
+				if depth == 0 {
+					return renderPos{y, x}, true
+				}
+			}
+		}
+	}
+
+	return renderPos{}, false
+}
+
+// isCodeHighlight returns whether hl is used for characters which aren't part
+// of a string or comment.
+func isCodeHighlight(hl editorHighlight) bool {
+	return hl != highlightComment && hl != highlightMultiComment && hl != highlightString
+}
+
+// isMatchedBracket returns whether the character at index rx of the render
+// field of the row at index at is one of the brackets that are highlighted.
+func isMatchedBracket(at, rx int) bool {
+	for _, pos := range matchedBrackets {
+		if pos.row == at && pos.rx == rx {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rainbowBracketHighlights are used to highlight brackets based on how deeply
+// they're nested, so that it's easier to see which ones match.
+var rainbowBracketHighlights = []editorHighlight{
+	highlightBracket1,
+	highlightBracket2,
+	highlightBracket3,
+}
+
+// highlightRainbowBrackets highlights the brackets in row which aren't part of
+// a string or comment, and keeps track of how deeply nested the end of the row
+// is so that highlighting continues onto the next row.
+func highlightRainbowBrackets(row *editorRow) {
+	depth := 0
+	if row.idx > 0 {
+		depth = e.row[row.idx-1].bracketDepth
+	}
+
+	for i := 0; i < len(row.render); i++ {
+		if !isCodeHighlight(row.highlight[i]) {
+			continue
+		}
+
+		switch row.render[i] {
+		case '(', '[', '{':
+			row.highlight[i] = rainbowBracketHighlights[depth%len(rainbowBracketHighlights)]
+			depth++
+		case ')', ']', '}':
+			// Closing brackets without an opening one are left as they are.
+			if depth > 0 {
+				depth--
+				row.highlight[i] = rainbowBracketHighlights[depth%len(rainbowBracketHighlights)]
+			}
+		}
+	}
+
+	row.bracketDepth = depth
+}
+
+func rainbowBracketsOption() editorOption {
+	opt := boolOption("rainbowbrackets", &e.rainbowBrackets)
+
+	set := opt.set
+	opt.set = func(value string) error {
+		if err := set(value); err != nil {
+			return err
+		}
+
+		// The highlighting of every row depends on the option.
+		for i := range e.row {
+			e.row[i].bracketDepth = 0
+			editorUpdateSyntax(&e.row[i])
+		}
+
+		return nil
+	}
+
+	return opt
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// bufferPos is a position in the buffer. col is an index into the raw field of
+// the row at index line. {len(e.row), 0} is the position at the end of the
+// buffer, after the newline of the last row.
+type bufferPos struct {
+	line, col int
+}
+
+// before returns whether p comes before other in the buffer.
+func (p bufferPos) before(other bufferPos) bool {
+	return p.line < other.line || (p.line == other.line && p.col < other.col)
+}
+
+// bufferChange describes a modification of the buffer, where the text from
+// start up to end was replaced with newText. Positions are relative to the
+// contents of the buffer before the change, and text uses \n to separate rows.
+type bufferChange struct {
+	start, end bufferPos
+
+	oldText string
+	newText string
+}
+
+// bufferListeners are notified after every change to the buffer.
+var bufferListeners []func(change bufferChange)
+
+// bufferOnChange registers a function to be called after every change to the
+// buffer. Features which need to stay in sync with the contents of the buffer
+// (e.g. undo) use this instead of hooking into each function which modifies
+// it.
+func bufferOnChange(listener func(change bufferChange)) {
+	bufferListeners = append(bufferListeners, listener)
+}
+
+// bufferText returns the text from start up to end.
+func bufferText(start, end bufferPos) string {
+	var text strings.Builder
+	for line := start.line; line <= end.line && line < len(e.row); line++ {
+		raw := e.row[line].raw
+
+		from := 0
+		if line == start.line {
+			from = start.col
+		}
+
+		if line == end.line {
+			text.WriteString(raw[from:end.col])
+			break
+		}
+
+		text.WriteString(raw[from:])
+		text.WriteByte('\n')
+	}
+
+	return text.String()
+}
+
+// bufferInsertLines inserts lines as new rows before the row at index at.
+func bufferInsertLines(at int, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	bufferReplaceRange(bufferPos{at, 0}, bufferPos{at, 0}, strings.Join(lines, "\n")+"\n")
+}
+
+// bufferDeleteRange deletes the text from start up to end.
+func bufferDeleteRange(start, end bufferPos) {
+	bufferReplaceRange(start, end, "")
+}
+
+// bufferReplaceRange replaces the text from start up to end with text, which
+// may span multiple rows. It returns the position at the end of the inserted
+// text.
+//
+// All modifications of the buffer go through here so that the rows which
+// changed are rendered again, and so that listeners are notified. Nothing
+// changes when the file is read-only.
+func bufferReplaceRange(start, end bufferPos, text string) bufferPos {
+	if e.readOnly {
+		editorSetStatusMessage("The file is read-only")
+		return start
+	}
+
+	before := ""
+	if start.line < len(e.row) {
+		before = e.row[start.line].raw[:start.col]
+	}
+	after := ""
+	if end.line < len(e.row) {
+		after = e.row[end.line].raw[end.col:]
+	}
+
+	// Every row ends with a newline, so text which ends up in the last row
+	// implicitly gets one too.
+	if end.line == len(e.row) && before+text != "" && !strings.HasSuffix(before+text, "\n") {
+		text += "\n"
+	}
+
+	change := bufferChange{
+		start:   start,
+		end:     end,
+		oldText: bufferText(start, end),
+		newText: text,
+	}
+
+	lines := strings.Split(before+text+after, "\n")
+	if end.line == len(e.row) {
+		// The text ends with a newline, so there isn't a row after it.
+		lines = lines[:len(lines)-1]
+	}
+
+	// Reuse the row which is being edited so that the information attached to
+	// it (e.g. virtual text) stays with it.
+	newRows := make([]editorRow, len(lines))
+	if len(lines) > 0 {
+		if start.col > 0 || (start.line == end.line && !strings.Contains(text, "\n")) {
+			if start.line < len(e.row) {
+				newRows[0] = e.row[start.line]
+			}
+		} else if end.col == 0 && end.line < len(e.row) {
+			newRows[len(newRows)-1] = e.row[end.line]
+		}
+	}
+	for i, line := range lines {
+		newRows[i].raw = line
+	}
+
+	e.row = slices.Replace(e.row, start.line, min(end.line+1, len(e.row)), newRows...)
+	for i := start.line; i < len(e.row); i++ {
+		e.row[i].idx = i
+	}
+
+	for i := range newRows {
+		editorUpdateRow(&e.row[start.line+i])
+	}
+	// The highlighting of the following row may depend on the rows which
+	// changed (e.g. if a multi-line comment was opened).
+	if next := start.line + len(newRows); next < len(e.row) {
+		editorUpdateSyntax(&e.row[next])
+	}
+
+	e.dirty = true
+
+	for _, listener := range bufferListeners {
+		listener(change)
+	}
+
+	return textEnd(start, text)
+}
+
+// textEnd returns the position at the end of text if it were inserted at
+// start.
+func textEnd(start bufferPos, text string) bufferPos {
+	newlines := strings.Count(text, "\n")
+	if newlines == 0 {
+		return bufferPos{start.line, start.col + len(text)}
+	}
+
+	return bufferPos{start.line + newlines, len(text) - strings.LastIndexByte(text, '\n') - 1}
+}
+
+// adjust returns the position that p moves to when change is made, so that it
+// stays on the same text. Positions inside of the text which was replaced move
+// to the start of the change.
+func (p bufferPos) adjust(change bufferChange) bufferPos {
+	if p.before(change.start) {
+		return p
+	}
+	if p.before(change.end) {
+		return change.start
+	}
+
+	newEnd := textEnd(change.start, change.newText)
+	if p.line == change.end.line {
+		return bufferPos{newEnd.line, newEnd.col + p.col - change.end.col}
+	}
+
+	return bufferPos{p.line + newEnd.line - change.end.line, p.col}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// editorChangeCase replaces the selected text, or the word under the cursor
+// when nothing is selected, with the result of convert.
+func editorChangeCase(convert func(string) string) {
+	start, end, ok := editorSelection()
+	if !ok {
+		start, end, ok = editorWordUnderCursor()
+	}
+	if !ok {
+		editorSetStatusMessage("No word under the cursor")
+		return
+	}
+
+	text := bufferText(start, end)
+	converted := convert(text)
+	if converted == text {
+		return
+	}
+
+	cursor := bufferPos{e.cy, e.cx}
+	editorReplaceKeepingCursor(start, end, converted)
+
+	// Keep the cursor where it was in the word when the conversion didn't
+	// change its length, rather than moving it to the start.
+	if len(converted) == len(text) && !cursor.before(start) && cursor.before(end) {
+		e.cy, e.cx = cursor.line, cursor.col
+	}
+}
+
+// editorWordUnderCursor returns the range of the word which contains the
+// cursor, or which ends at it.
+func editorWordUnderCursor() (start, end bufferPos, ok bool) {
+	if e.cy >= len(e.row) {
+		return bufferPos{}, bufferPos{}, false
+	}
+
+	raw := e.row[e.cy].raw
+	from, to := e.cx, e.cx
+	for from > 0 && !isWordSeparator(raw[from-1]) {
+		from--
+	}
+	for to < len(raw) && !isWordSeparator(raw[to]) {
+		to++
+	}
+
+	return bufferPos{e.cy, from}, bufferPos{e.cy, to}, from < to
+}
+
+// titleCase returns s with the first letter of each word in title case, and
+// the rest of the letters in lower case.
+func titleCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	inWord := false
+	for _, r := range s {
+		if r < utf8.RuneSelf && isWordSeparator(byte(r)) {
+			inWord = false
+			b.WriteRune(r)
+			continue
+		}
+
+		if inWord {
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(unicode.ToTitle(r))
+		}
+		inWord = true
+	}
+
+	return b.String()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type colourKind uint8
+
+const (
+	// colourDefault is the terminal's default colour.
+	colourDefault colourKind = iota
+	// colourIndexed is one of the 256 colours in the terminal's palette.
+	colourIndexed
+	// colourRGB is a 24-bit colour.
+	colourRGB
+)
+
+// colour is a colour to display text in. The zero value is the terminal's
+// default colour.
+type colour struct {
+	kind colourKind
+
+	index   uint8
+	r, g, b uint8
+}
+
+func indexedColour(index uint8) colour {
+	return colour{kind: colourIndexed, index: index}
+}
+
+func rgbColour(r, g, b uint8) colour {
+	return colour{kind: colourRGB, r: r, g: g, b: b}
+}
+
+// style is how a character is displayed.
+type style struct {
+	fg, bg    colour
+	underline bool
+}
+
+// sgr returns the escape sequence which sets the colours and attributes to
+// those of s.
+func (s style) sgr() string {
+	attributes := "\x1b[24m"
+	if s.underline {
+		attributes = "\x1b[4m"
+	}
+
+	return attributes + s.fg.fgSGR() + s.bg.bgSGR()
+}
+
+// colourDepth is the number of colours that the terminal can display.
+type colourDepth int
+
+const (
+	colourDepth16 colourDepth = iota
+	colourDepth256
+	colourDepthTrue
+)
+
+// detectColourDepth guesses the number of colours that the terminal supports
+// based on the environment.
+func detectColourDepth() colourDepth {
+	colorTerm := os.Getenv("COLORTERM")
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return colourDepthTrue
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case strings.HasSuffix(term, "-direct"):
+		return colourDepthTrue
+	case strings.Contains(term, "256color"):
+		return colourDepth256
+	}
+
+	return colourDepth16
+}
+
+// basicPalette contains the RGB values of the 16 basic colours, as defined by
+// xterm. These are used to approximate other colours on terminals which only
+// support the basic ones.
+var basicPalette = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the values of each component in the 6x6x6 colour cube of the
+// 256 colour palette.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// fgSGR returns the escape sequence which sets the foreground colour to c.
+func (c colour) fgSGR() string {
+	return c.sgr(30, 90, 38, 39)
+}
+
+// bgSGR returns the escape sequence which sets the background colour to c.
+func (c colour) bgSGR() string {
+	return c.sgr(40, 100, 48, 49)
+}
+
+// sgr returns the escape sequence which sets c, using the parameters for
+// either the foreground or the background.
+func (c colour) sgr(basic, bright, extended, reset int) string {
+	switch c.kind {
+	case colourIndexed:
+		if c.index < 8 {
+			return fmt.Sprintf("\x1b[%dm", basic+int(c.index))
+		} else if c.index < 16 {
+			return fmt.Sprintf("\x1b[%dm", bright+int(c.index)-8)
+		} else if e.colourDepth == colourDepth16 {
+			r, g, b := paletteToRGB(c.index)
+			return rgbColour(r, g, b).sgr(basic, bright, extended, reset)
+		}
+
+		return fmt.Sprintf("\x1b[%d;5;%dm", extended, c.index)
+	case colourRGB:
+		switch e.colourDepth {
+		case colourDepthTrue:
+			return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", extended, c.r, c.g, c.b)
+		case colourDepth256:
+			return fmt.Sprintf("\x1b[%d;5;%dm", extended, rgbTo256(c.r, c.g, c.b))
+		default:
+			return indexedColour(rgbTo16(c.r, c.g, c.b)).sgr(basic, bright, extended, reset)
+		}
+	}
+
+	return fmt.Sprintf("\x1b[%dm", reset)
+}
+
+// paletteToRGB returns the RGB value of the colour at index in the 256 colour
+// palette.
+func paletteToRGB(index uint8) (r, g, b uint8) {
+	switch {
+	case index < 16:
+		c := basicPalette[index]
+		return c[0], c[1], c[2]
+	case index < 232:
+		i := index - 16
+		return cubeLevels[i/36], cubeLevels[(i/6)%6], cubeLevels[i%6]
+	default:
+		grey := 8 + (index-232)*10
+		return grey, grey, grey
+	}
+}
+
+// rgbTo256 returns the index of the closest colour in the 256 colour palette,
+// excluding the basic colours since they vary between terminals.
+func rgbTo256(r, g, b uint8) uint8 {
+	best := uint8(16)
+	bestDistance := -1
+	for i := 16; i < 256; i++ {
+		pr, pg, pb := paletteToRGB(uint8(i))
+		d := colourDistance(r, g, b, pr, pg, pb)
+		if bestDistance < 0 || d < bestDistance {
+			best = uint8(i)
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+// rgbTo16 returns the index of the closest of the 16 basic colours.
+func rgbTo16(r, g, b uint8) uint8 {
+	best := uint8(0)
+	bestDistance := -1
+	for i, c := range basicPalette {
+		d := colourDistance(r, g, b, c[0], c[1], c[2])
+		if bestDistance < 0 || d < bestDistance {
+			best = uint8(i)
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+func colourDistance(r1, g1, b1, r2, g2, b2 uint8) int {
+	dr := int(r1) - int(r2)
+	dg := int(g1) - int(g2)
+	db := int(b1) - int(b2)
+	return dr*dr + dg*dg + db*db
+}
+
+func colourDepthOption() editorOption {
+	names := map[colourDepth]string{
+		colourDepth16:   "16",
+		colourDepth256:  "256",
+		colourDepthTrue: "truecolor",
+	}
+
+	return editorOption{
+		name: "colourdepth",
+		set: func(value string) error {
+			for depth, name := range names {
+				if name == value {
+					e.colourDepth = depth
+					return nil
+				}
+			}
+
+			return fmt.Errorf("expected 16, 256, or truecolor, given %q", value)
+		},
+		get: func() string {
+			return names[e.colourDepth]
+		},
+	}
+}
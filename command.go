@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+)
+
+type editorCommand struct {
+	name string
+	// run executes the command. args contains the text which followed the name
+	// of the command in the prompt, with surrounding whitespace removed.
+	run func(args string)
+}
+
+// editorCommands contains the commands which can be run from the command
+// prompt. It's populated in init to avoid an initialization cycle with
+// editorCommandPrompt.
+var editorCommands []editorCommand
+
+func init() {
+	editorCommands = []editorCommand{
+		{name: "set", run: editorSetOptionCommand},
+		{name: "open", run: editorOpenCommand},
+		{name: "replace", run: func(string) { editorReplace(false) }},
+		{name: "replace-regex", run: func(string) { editorReplace(true) }},
+		{name: "check-json", run: editorCheckJSON},
+		{name: "theme", run: editorThemeCommand},
+		{name: "join", run: func(args string) { editorJoinCommand(args, false) }},
+		{name: "marks", run: editorListMarks},
+		{name: "join-keep-space", run: func(args string) { editorJoinCommand(args, true) }},
+		{name: "upcase", run: func(string) { editorChangeCase(strings.ToUpper) }},
+		{name: "downcase", run: func(string) { editorChangeCase(strings.ToLower) }},
+		{name: "titlecase", run: func(string) { editorChangeCase(titleCase) }},
+		{name: "sort", run: editorSortCommand},
+		{name: "uniq", run: editorUniqCommand},
+		{name: "align", run: editorAlignCommand},
+		{name: "increment", run: func(args string) { editorIncrementCommand(args, 1) }},
+		{name: "decrement", run: func(args string) { editorIncrementCommand(args, -1) }},
+		{name: "timestamp", run: editorInsertTimestamp},
+		{name: "insert-file", run: editorInsertFile},
+		{name: "pipe", run: editorPipeCommand},
+		{name: "!", run: editorRunCommand},
+		{name: "r", run: editorReadCommand},
+		{name: "format", run: func(string) { editorFormat() }},
+		{name: "compile", run: editorCompile},
+		{name: "errors", run: func(string) { editorListErrors() }},
+		{name: "definition", run: func(string) { editorGoToDefinition() }},
+		{name: "hover", run: func(string) { editorHover() }},
+		{name: "rename", run: editorRename},
+		{name: "tag", run: editorGoToTag},
+		{name: "spell", run: func(string) { editorSpellSuggest() }},
+		{name: "git-diff", run: editorGitDiffCommand},
+		{name: "next-hunk", run: func(string) { editorNextHunk(1) }},
+		{name: "prev-hunk", run: func(string) { editorNextHunk(-1) }},
+		{name: "blame", run: editorBlameCommand},
+		{name: "keep-ours", run: func(string) { editorResolveConflict(true, false) }},
+		{name: "keep-theirs", run: func(string) { editorResolveConflict(false, true) }},
+		{name: "keep-both", run: func(string) { editorResolveConflict(true, true) }},
+		{name: "next-conflict", run: func(string) { editorNextConflict(1) }},
+		{name: "prev-conflict", run: func(string) { editorNextConflict(-1) }},
+		{name: "diff-saved", run: func(string) { editorDiffSaved() }},
+		{name: "hex", run: editorHexCommand},
+		{name: "preview", run: func(string) { editorTogglePreview() }},
+		{name: "export-html", run: editorExportHTML},
+		{name: "count", run: func(string) { editorCountCommand() }},
+		{name: "messages", run: func(string) { editorShowMessageLog() }},
+	}
+}
+
+// editorCommandPrompt prompts for a command and runs it.
+func editorCommandPrompt() {
+	input := editorPromptCompleting("Command: %s", "", func(string, rune) {}, completeCommand)
+	if input == "" {
+		return
+	}
+
+	name, args, _ := strings.Cut(strings.TrimSpace(input), " ")
+	if command, ok := strings.CutPrefix(strings.TrimSpace(input), "!"); ok {
+		// Shell commands don't need a space after the !.
+		name, args = "!", command
+	}
+	for _, cmd := range editorCommands {
+		if cmd.name == name {
+			cmd.run(strings.TrimSpace(args))
+			return
+		}
+	}
+
+	editorSetStatusMessage("Unknown command: %s", name)
+}
@@ -0,0 +1,106 @@
+package main
+
+import "strings"
+
+// editorToggleComment comments out the selected rows, or the row containing
+// the cursor, or uncomments them if they're already commented out. Line
+// comments are used when the file type has them, otherwise the rows are
+// wrapped in a block comment.
+func editorToggleComment() {
+	if e.syntax == nil {
+		editorSetStatusMessage("Don't know how to comment this file")
+		return
+	}
+
+	first, last, ok := editorSelectedRows()
+	if !ok {
+		return
+	}
+
+	switch {
+	case e.syntax.singleLineCommentStart != "":
+		toggleLineComments(first, last, e.syntax.singleLineCommentStart)
+	case e.syntax.multilineCommentStart != "":
+		toggleBlockComment(first, last, e.syntax.multilineCommentStart, e.syntax.multilineCommentEnd)
+	default:
+		editorSetStatusMessage("No comment syntax for %s", e.syntax.fileType)
+	}
+}
+
+// toggleLineComments adds prefix to the start of each non-blank row from first
+// to last, or removes it if they all have it already. The prefix is inserted
+// at the same column in each row so that the indentation is preserved.
+func toggleLineComments(first, last int, prefix string) {
+	indent := -1
+	commented := true
+	for i := first; i <= last; i++ {
+		raw := e.row[i].raw
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		ws := len(leadingWhitespace(raw))
+		if indent < 0 || ws < indent {
+			indent = ws
+		}
+		if !strings.HasPrefix(raw[ws:], prefix) {
+			commented = false
+		}
+	}
+
+	if indent < 0 {
+		// Every row is blank
+		return
+	}
+
+	for i := first; i <= last; i++ {
+		raw := e.row[i].raw
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		if commented {
+			ws := len(leadingWhitespace(raw))
+			n := len(prefix)
+			if strings.HasPrefix(raw[ws+n:], " ") {
+				n++
+			}
+			editorReplaceKeepingCursor(bufferPos{i, ws}, bufferPos{i, ws + n}, "")
+		} else {
+			editorReplaceKeepingCursor(bufferPos{i, indent}, bufferPos{i, indent}, prefix+" ")
+		}
+	}
+}
+
+// toggleBlockComment wraps the rows from first to last in a block comment, or
+// removes the comment if they're already wrapped in one.
+func toggleBlockComment(first, last int, start, end string) {
+	firstRaw := e.row[first].raw
+	lastRaw := strings.TrimRight(e.row[last].raw, " \t")
+	ws := len(leadingWhitespace(firstRaw))
+
+	isCommented := strings.HasPrefix(firstRaw[ws:], start) && strings.HasSuffix(lastRaw, end)
+	if first == last {
+		isCommented = isCommented && len(lastRaw) >= ws+len(start)+len(end)
+	}
+
+	if isCommented {
+		// Remove the end first so that the position of the start stays the same
+		// when they're on the same row.
+		endCol := len(lastRaw) - len(end)
+		if endCol > 0 && lastRaw[endCol-1] == ' ' {
+			endCol--
+		}
+		editorReplaceKeepingCursor(bufferPos{last, endCol}, bufferPos{last, len(lastRaw)}, "")
+
+		n := len(start)
+		if strings.HasPrefix(e.row[first].raw[ws+n:], " ") {
+			n++
+		}
+		editorReplaceKeepingCursor(bufferPos{first, ws}, bufferPos{first, ws + n}, "")
+		return
+	}
+
+	editorReplaceKeepingCursor(bufferPos{last, len(lastRaw)}, bufferPos{last, len(lastRaw)}, " "+end)
+	editorReplaceKeepingCursor(bufferPos{first, ws}, bufferPos{first, ws}, start+" ")
+}
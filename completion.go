@@ -0,0 +1,375 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// completionPopupSize is the maximum number of completions shown at once.
+const completionPopupSize = 8
+
+// completionItem is a piece of text which can be inserted to complete what's
+// being typed.
+type completionItem struct {
+	label  string
+	detail string
+	// edit replaces the text being completed, and additionalEdits make other
+	// changes which go along with it, like adding an import.
+	edit            textEdit
+	additionalEdits []textEdit
+}
+
+// textEdit replaces the text from start up to end with newText.
+type textEdit struct {
+	start, end bufferPos
+	newText    string
+}
+
+// completion is the state of the completion popup.
+var completion struct {
+	// active indicates whether the popup is shown.
+	active bool
+	items  []completionItem
+	// selected is the index of the highlighted item, and offset is the index
+	// of the first one which is shown.
+	selected, offset int
+	// pos is the position of the cursor when the completions were requested.
+	pos bufferPos
+
+	// generation is incremented for each request so that responses to earlier
+	// ones are ignored.
+	generation int
+}
+
+// editorShowCompletions shows items in the popup, if there are any.
+func editorShowCompletions(items []completionItem) {
+	if len(items) == 0 {
+		editorCloseCompletions()
+		return
+	}
+
+	completion.active = true
+	completion.items = items
+	completion.selected = 0
+	completion.offset = 0
+	completion.pos = bufferPos{e.cy, e.cx}
+}
+
+// editorCloseCompletions hides the popup.
+func editorCloseCompletions() {
+	completion.active = false
+	completion.items = nil
+}
+
+// editorHandleCompletionKey handles key when the popup is shown. The arrow keys
+// move through the completions, Tab and Enter insert the selected one, and
+// Escape closes the popup. It returns whether the key was handled.
+func editorHandleCompletionKey(key rune) bool {
+	if !completion.active {
+		return false
+	}
+
+	switch key {
+	case arrowUp, arrowDown:
+		delta := 1
+		if key == arrowUp {
+			delta = -1
+		}
+		n := len(completion.items)
+		completion.selected = (completion.selected + delta + n) % n
+
+		if completion.selected < completion.offset {
+			completion.offset = completion.selected
+		}
+		if completion.selected >= completion.offset+completionPopupSize {
+			completion.offset = completion.selected - completionPopupSize + 1
+		}
+		return true
+	case '\t', '\r':
+		item := completion.items[completion.selected]
+		editorCloseCompletions()
+		editorApplyCompletion(item)
+		return true
+	case '\x1b':
+		editorCloseCompletions()
+		return true
+	}
+
+	return false
+}
+
+// editorApplyCompletion inserts item, and moves the cursor to the end of it.
+func editorApplyCompletion(item completionItem) {
+	edits := append([]textEdit{item.edit}, item.additionalEdits...)
+
+	// Making the edits from the bottom keeps the positions of the earlier ones
+	// valid.
+	slices.SortStableFunc(edits, func(a, b textEdit) int {
+		switch {
+		case a.start.before(b.start):
+			return 1
+		case b.start.before(a.start):
+			return -1
+		}
+		return 0
+	})
+
+	for _, edit := range edits {
+		if edit == item.edit {
+			end := bufferReplaceRange(edit.start, edit.end, edit.newText)
+			e.cy, e.cx = end.line, end.col
+		} else {
+			editorReplaceKeepingCursor(edit.start, edit.end, edit.newText)
+		}
+	}
+}
+
+// editorUpdateCompletion is called after each key is processed to request
+// completions when typing a word, or after one of the characters which the
+// language server says start completions, like a dot. The popup is closed for
+// other keys.
+func editorUpdateCompletion(key rune) {
+	if e.selecting || len(e.cursors) > 0 {
+		editorCloseCompletions()
+		return
+	}
+
+	typing := key == '_' || unicode.IsLetter(key) || unicode.IsDigit(key)
+	trigger := slices.Contains(editorCompletionTriggers(), string(key))
+
+	if key == backspace && completion.active {
+		// Update the completions for the shorter word, if there's one left.
+		start := completionWordStart()
+		typing = start < e.cx
+	}
+
+	if !typing && !trigger {
+		editorCloseCompletions()
+		return
+	}
+
+	editorRequestCompletions(trigger, key)
+}
+
+// completionWordStart returns the index of the start of the word before the
+// cursor.
+func completionWordStart() int {
+	raw := editorRowText(e.cy)
+	start := min(e.cx, len(raw))
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(raw[:start])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		start -= size
+	}
+
+	return start
+}
+
+// editorCompletionTriggers returns the characters which the language server
+// says should start completions.
+func editorCompletionTriggers() []string {
+	if lsp == nil || !lsp.ready {
+		return nil
+	}
+
+	var caps struct {
+		CompletionProvider *struct {
+			TriggerCharacters []string `json:"triggerCharacters"`
+		} `json:"completionProvider"`
+	}
+	json.Unmarshal(lsp.capabilities, &caps)
+	if caps.CompletionProvider == nil {
+		return nil
+	}
+
+	return caps.CompletionProvider.TriggerCharacters
+}
+
+// editorRequestCompletions asks the language server for completions at the
+// cursor in the background. They're shown if the cursor hasn't moved by the
+// time they're received.
+func editorRequestCompletions(trigger bool, key rune) {
+	if lsp == nil || !lsp.ready {
+		return
+	}
+	editorLSPFlush()
+
+	context := map[string]any{"triggerKind": 1}
+	if trigger {
+		context = map[string]any{"triggerKind": 2, "triggerCharacter": string(key)}
+	}
+	params := editorLSPTextDocumentPosition()
+	params["context"] = context
+
+	completion.generation++
+	generation := completion.generation
+	pos := bufferPos{e.cy, e.cx}
+	version := lsp.version
+
+	lsp.requestAsync("textDocument/completion", params, func(result json.RawMessage, err error) {
+		stale := generation != completion.generation || lsp.version != version || pos != (bufferPos{e.cy, e.cx})
+		if err != nil || stale {
+			return
+		}
+
+		editorShowCompletions(parseCompletionResult(result))
+	})
+}
+
+// lspTextEdit is an edit to a file, as given by the language server.
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+// parseCompletionResult converts the response to a completion request, which
+// is either a list of items or an object containing one, to completionItems.
+func parseCompletionResult(result json.RawMessage) []completionItem {
+	type lspCompletionItem struct {
+		Label      string `json:"label"`
+		Detail     string `json:"detail"`
+		SortText   string `json:"sortText"`
+		FilterText string `json:"filterText"`
+		InsertText string `json:"insertText"`
+		TextEdit   *struct {
+			lspTextEdit
+			// Servers may give separate ranges for inserting and replacing
+			// instead of range.
+			Insert *lspRange `json:"insert"`
+		} `json:"textEdit"`
+		AdditionalTextEdits []lspTextEdit `json:"additionalTextEdits"`
+	}
+
+	var list struct {
+		Items []lspCompletionItem `json:"items"`
+	}
+	if err := json.Unmarshal(result, &list.Items); err != nil {
+		json.Unmarshal(result, &list)
+	}
+
+	slices.SortStableFunc(list.Items, func(a, b lspCompletionItem) int {
+		return cmp.Compare(cmp.Or(a.SortText, a.Label), cmp.Or(b.SortText, b.Label))
+	})
+
+	raw := editorRowText(e.cy)
+	wordStart := completionWordStart()
+	prefix := strings.ToLower(raw[wordStart:e.cx])
+
+	var items []completionItem
+	for _, it := range list.Items {
+		if !isSubsequence(prefix, strings.ToLower(cmp.Or(it.FilterText, it.Label))) {
+			continue
+		}
+
+		item := completionItem{label: it.Label, detail: it.Detail}
+
+		switch {
+		case it.TextEdit != nil:
+			r := it.TextEdit.Range
+			if it.TextEdit.Insert != nil {
+				r = *it.TextEdit.Insert
+			}
+			item.edit = lsp.toTextEdit(lspTextEdit{r, it.TextEdit.NewText})
+		default:
+			item.edit = textEdit{
+				start:   bufferPos{e.cy, wordStart},
+				end:     bufferPos{e.cy, e.cx},
+				newText: cmp.Or(it.InsertText, it.Label),
+			}
+		}
+
+		for _, edit := range it.AdditionalTextEdits {
+			item.additionalEdits = append(item.additionalEdits, lsp.toTextEdit(edit))
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// toTextEdit converts an edit to the open file from the language server.
+func (c *lspClient) toTextEdit(edit lspTextEdit) textEdit {
+	return textEdit{
+		start:   c.fromLSP(edit.Range.Start, editorRowText),
+		end:     c.fromLSP(edit.Range.End, editorRowText),
+		newText: edit.NewText,
+	}
+}
+
+// isSubsequence returns whether the characters of s appear in t in order.
+func isSubsequence(s, t string) bool {
+	for _, r := range s {
+		i := strings.IndexRune(t, r)
+		if i < 0 {
+			return false
+		}
+		t = t[i+utf8.RuneLen(r):]
+	}
+
+	return true
+}
+
+// editorDrawCompletions draws the popup below the cursor, or above it when
+// there isn't enough room.
+func editorDrawCompletions(w io.Writer) {
+	if !completion.active || e.cy >= len(e.row) {
+		return
+	}
+
+	items := completion.items[completion.offset:]
+	items = items[:min(len(items), completionPopupSize)]
+
+	width := 0
+	for _, item := range items {
+		width = max(width, utf8.RuneCountInString(completionLine(item)))
+	}
+	width = min(width+2, editorTextCols())
+
+	cursorRow := e.cy - e.rowOffset
+	top := cursorRow + 1
+	if top+len(items) > e.screenRows {
+		top = max(cursorRow-len(items), 0)
+	}
+
+	startRx := editorRowCxToRx(e.row[e.cy], completionWordStart())
+	left := editorGutterWidth() + max(startRx-e.colOffset-1, 0)
+	left = max(min(left, e.screenCols-width), 0)
+
+	t := editorCurrentTheme()
+	for i, item := range items {
+		fmt.Fprintf(w, "\x1b[%d;%dH", top+i+1, left+1)
+
+		switch {
+		case completion.offset+i == completion.selected:
+			fmt.Fprint(w, t.selection.bgSGR())
+		case t.popup == (colour{}):
+			fmt.Fprint(w, "\x1b[7m")
+		default:
+			fmt.Fprint(w, t.popup.bgSGR())
+		}
+
+		line := []rune(" " + completionLine(item))
+		line = line[:min(len(line), width)]
+		fmt.Fprint(w, string(line))
+		fmt.Fprint(w, strings.Repeat(" ", width-len(line)))
+		fmt.Fprint(w, "\x1b[m")
+	}
+}
+
+// completionLine returns the text shown for item in the popup.
+func completionLine(item completionItem) string {
+	if item.detail == "" {
+		return item.label
+	}
+
+	return item.label + "  " + item.detail
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configDir returns the directory which contains the editor's configuration.
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "lte")
+}
+
+// configOption is an option set in the config file.
+type configOption struct {
+	key, value string
+	// location is the path and line number of where the option was set.
+	location string
+}
+
+// fileTypeConfig contains the options set in sections of the config file which
+// only apply to files of a certain type, keyed by the file type.
+var fileTypeConfig = make(map[string][]configOption)
+
+// globalOptions contains the values that the options set in file type
+// sections had before any of those sections were applied, keyed by the name of
+// the option. They're restored before applying the section for another file,
+// so that options don't carry over from the type of file which was open
+// before.
+var globalOptions = make(map[string]string)
+
+// config contains the options set in the config file.
+type config struct {
+	options []configOption
+	// fileTypes contains the options which only apply to files of a certain
+	// type, keyed by the file type.
+	fileTypes map[string][]configOption
+	// themes contains the themes named by theme options, which are loaded
+	// along with the config. See preloadedThemes.
+	themes map[string]*theme
+}
+
+// editorLoadConfig reads the config file in the background, so that it doesn't
+// delay opening the file, and applies it on the main goroutine once it's been
+// read.
+func editorLoadConfig() {
+	go func() {
+		c, err := readConfig()
+		editorPostToMain(func() {
+			if err == nil {
+				err = editorApplyConfig(c)
+			}
+			if err != nil {
+				editorSetStatusMessage("Can't load config: %s", err.Error())
+			}
+		})
+	}()
+}
+
+// readConfig reads the config file. It contains lines like "theme = gruvbox",
+// which are equivalent to running "set theme gruvbox" from the command prompt.
+// Lines starting with # are ignored.
+//
+// Options which follow a line like "[go]" only apply to files of that type,
+// and are applied once the type of the file is known.
+//
+// It doesn't access the editor's state, so that it can run in the background.
+func readConfig() (config, error) {
+	c := config{
+		fileTypes: make(map[string][]configOption),
+		themes:    make(map[string]*theme),
+	}
+
+	path := filepath.Join(configDir(), "config")
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	fileType := ""
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if section, ok := strings.CutPrefix(line, "["); ok && strings.HasSuffix(section, "]") {
+			fileType = strings.TrimSpace(strings.TrimSuffix(section, "]"))
+			continue
+		}
+
+		key, value, ok := parseConfigLine(line)
+		if !ok {
+			continue
+		}
+		if !editorHasOption(key) {
+			return c, fmt.Errorf("%s:%d: unknown option %q", path, lineNumber, key)
+		}
+
+		opt := configOption{
+			key:      key,
+			value:    value,
+			location: fmt.Sprintf("%s:%d", path, lineNumber),
+		}
+		if fileType != "" {
+			c.fileTypes[fileType] = append(c.fileTypes[fileType], opt)
+		} else {
+			c.options = append(c.options, opt)
+		}
+
+		if key == "theme" && !isBuiltinTheme(value) {
+			// Errors are reported when the option is applied.
+			if t, err := loadTheme(value); err == nil {
+				c.themes[value] = t
+			}
+		}
+	}
+
+	return c, scanner.Err()
+}
+
+// editorApplyConfig sets the options in c, and the ones for the type of the
+// file being edited.
+func editorApplyConfig(c config) error {
+	for name, t := range c.themes {
+		preloadedThemes[name] = t
+	}
+	fileTypeConfig = c.fileTypes
+
+	for _, opt := range c.options {
+		if err := editorSetOption(opt.key, opt.value); err != nil {
+			return fmt.Errorf("%s: %w", opt.location, err)
+		}
+	}
+
+	globalOptions = make(map[string]string)
+	for _, options := range fileTypeConfig {
+		for _, opt := range options {
+			globalOptions[opt.key], _ = editorGetOption(opt.key)
+		}
+	}
+
+	editorApplyFileTypeConfig()
+
+	// The file was opened before the config was read, so its indentation is
+	// detected again to take precedence over the options, and its language
+	// server may only be known now.
+	editorDetectIndent()
+	if lsp == nil || lsp.command != editorLanguageServer() {
+		editorStartLanguageServer()
+	}
+	return nil
+}
+
+// editorApplyFileTypeConfig applies the options set in the config file for the
+// type of the file being edited, in place of the ones for the previous file.
+func editorApplyFileTypeConfig() {
+	for name, value := range globalOptions {
+		if current, _ := editorGetOption(name); current != value {
+			editorSetOption(name, value)
+		}
+	}
+
+	if e.syntax == nil {
+		return
+	}
+
+	for _, opt := range fileTypeConfig[e.syntax.fileType] {
+		if err := editorSetOption(opt.key, opt.value); err != nil {
+			editorSetStatusMessage("%s: %s", opt.location, err.Error())
+		}
+	}
+}
+
+// parseConfigLine splits a line of the form "key = value". ok is false for
+// blank lines and comments.
+func parseConfigLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	key, value, _ = strings.Cut(line, "=")
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
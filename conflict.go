@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+)
+
+// conflict is a region of the file containing conflicting changes from a
+// merge, given as the indices of the rows with its markers. base is -1 when
+// the common ancestor isn't included.
+type conflict struct {
+	start, base, mid, end int
+}
+
+// conflictSide identifies which part of a conflict a row is in.
+type conflictSide int
+
+const (
+	conflictNone conflictSide = iota
+	conflictMarker
+	conflictOurs
+	conflictBase
+	conflictTheirs
+)
+
+// conflicts contains the conflicts in the file, in order. It's found again
+// before drawing when conflictsStale is set.
+var (
+	conflicts      []conflict
+	conflictsStale = true
+)
+
+func init() {
+	bufferOnChange(func(bufferChange) { conflictsStale = true })
+}
+
+// editorUpdateConflicts finds the conflicts in the file, if it's changed.
+func editorUpdateConflicts() {
+	if !conflictsStale {
+		return
+	}
+	conflictsStale = false
+
+	conflicts = conflicts[:0]
+	c := conflict{start: -1, base: -1, mid: -1}
+	for i, row := range e.row {
+		switch {
+		case strings.HasPrefix(row.raw, "<<<<<<<"):
+			c = conflict{start: i, base: -1, mid: -1}
+		case c.start < 0:
+			continue
+		case strings.HasPrefix(row.raw, "|||||||") && c.mid < 0:
+			c.base = i
+		case strings.HasPrefix(row.raw, "=======") && c.mid < 0:
+			c.mid = i
+		case strings.HasPrefix(row.raw, ">>>>>>>") && c.mid >= 0:
+			c.end = i
+			conflicts = append(conflicts, c)
+			c = conflict{start: -1, base: -1, mid: -1}
+		}
+	}
+}
+
+// conflictSideAt returns which part of a conflict the row at index at is in.
+func conflictSideAt(at int) conflictSide {
+	for _, c := range conflicts {
+		switch {
+		case at < c.start || at > c.end:
+			continue
+		case at == c.start || at == c.base || at == c.mid || at == c.end:
+			return conflictMarker
+		case at > c.mid:
+			return conflictTheirs
+		case c.base >= 0 && at > c.base:
+			return conflictBase
+		default:
+			return conflictOurs
+		}
+	}
+
+	return conflictNone
+}
+
+// conflictStyle changes s, the style of a character in the row at index at,
+// to show which part of a conflict it's in.
+func conflictStyle(s style, at int) style {
+	t := editorCurrentTheme()
+	switch conflictSideAt(at) {
+	case conflictMarker:
+		if t.conflictMarker != (colour{}) {
+			s.fg = t.conflictMarker
+		}
+	case conflictOurs:
+		s.bg = t.conflictOurs
+	case conflictBase:
+		s.bg = t.conflictBase
+	case conflictTheirs:
+		s.bg = t.conflictTheirs
+	}
+
+	return s
+}
+
+// editorConflictAtCursor returns the conflict which contains the cursor.
+func editorConflictAtCursor() (conflict, bool) {
+	editorUpdateConflicts()
+	for _, c := range conflicts {
+		if e.cy >= c.start && e.cy <= c.end {
+			return c, true
+		}
+	}
+
+	return conflict{}, false
+}
+
+// editorResolveConflict replaces the conflict containing the cursor with the
+// rows from the sides that are kept, in order, and moves to the next
+// conflict.
+func editorResolveConflict(ours, theirs bool) {
+	c, ok := editorConflictAtCursor()
+	if !ok {
+		editorSetStatusMessage("No conflict at the cursor")
+		return
+	}
+
+	oursEnd := c.mid
+	if c.base >= 0 {
+		oursEnd = c.base
+	}
+
+	var text strings.Builder
+	if ours {
+		text.WriteString(bufferText(bufferPos{c.start + 1, 0}, bufferPos{oursEnd, 0}))
+	}
+	if theirs {
+		text.WriteString(bufferText(bufferPos{c.mid + 1, 0}, bufferPos{c.end, 0}))
+	}
+
+	bufferReplaceRange(bufferPos{c.start, 0}, bufferPos{c.end + 1, 0}, text.String())
+	e.cy, e.cx = min(c.start, len(e.row)), 0
+
+	// The next conflict may start on the row after the resolved one, which is
+	// where the cursor is now.
+	editorUpdateConflicts()
+	if next, ok := conflictAfter(c.start-1, 1); ok {
+		e.cy = next
+	}
+	editorSetStatusMessage("%d conflicts remaining", len(conflicts))
+}
+
+// editorNextConflict moves the cursor to the start of the next conflict, or
+// the previous one when dir is negative.
+func editorNextConflict(dir int) {
+	editorUpdateConflicts()
+
+	next, ok := conflictAfter(e.cy, dir)
+	if !ok {
+		editorSetStatusMessage("No more conflicts")
+		return
+	}
+
+	editorRecordJump()
+	e.cy, e.cx = next, 0
+}
+
+// conflictAfter returns the first row of the first conflict which starts after
+// line, or before it when dir is negative.
+func conflictAfter(line, dir int) (int, bool) {
+	for i := range conflicts {
+		if dir < 0 {
+			i = len(conflicts) - 1 - i
+		}
+
+		if start := conflicts[i].start; (dir > 0 && start > line) || (dir < 0 && start < line) {
+			return start, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textCount is the size of some text in various units.
+type textCount struct {
+	lines, words, chars, bytes int
+}
+
+// fileCount caches the size of the whole file for the status bar, since it
+// would be slow to count large files each time it's drawn. It's counted again
+// when fileCountStale is set.
+var (
+	fileCount      textCount
+	fileCountStale = true
+)
+
+func init() {
+	bufferOnChange(func(bufferChange) { fileCountStale = true })
+}
+
+// editorCountCommand shows the size of the selection, or of the whole file
+// when nothing is selected.
+func editorCountCommand() {
+	c := editorFileCount()
+	what := "File"
+	if start, end, ok := editorSelection(); ok {
+		c = countSelection(start, end)
+		what = "Selection"
+	}
+
+	editorSetStatusMessage("%s: %s, %s, %s, %s", what,
+		plural(c.lines, "line"), plural(c.words, "word"), plural(c.chars, "character"), plural(c.bytes, "byte"))
+}
+
+// editorCountWords returns the number of words in the selection, or in the
+// whole file when nothing is selected.
+func editorCountWords() int {
+	if start, end, ok := editorSelection(); ok {
+		return countSelection(start, end).words
+	}
+
+	return editorFileCount().words
+}
+
+// editorFileCount returns the size of the file, as it would be saved.
+func editorFileCount() textCount {
+	if !fileCountStale {
+		return fileCount
+	}
+	fileCountStale = false
+
+	fileCount = textCount{lines: len(e.row)}
+	for _, row := range e.row {
+		fileCount.add(row.raw)
+		// Each row ends with a newline when it's saved.
+		fileCount.chars++
+		fileCount.bytes++
+	}
+
+	return fileCount
+}
+
+// countSelection returns the size of the text between start and end. The last
+// row only counts as a line when some of it is selected.
+func countSelection(start, end bufferPos) textCount {
+	c := textCount{lines: end.line - start.line + 1}
+	if end.col == 0 && end.line > start.line {
+		c.lines--
+	}
+	c.add(bufferText(start, end))
+
+	return c
+}
+
+// add adds the words, characters, and bytes in s to c. Characters are
+// grapheme clusters, so that each one is what the user sees as a single
+// character.
+func (c *textCount) add(s string) {
+	c.bytes += len(s)
+	for i := 0; i < len(s); i = nextGraphemeEnd(s, i) {
+		c.chars++
+	}
+	c.words += countWords(s)
+}
+
+// countWords returns the number of words in s. A word is a run of letters,
+// digits, and combining marks, which can contain apostrophes and hyphens
+// (e.g. "don't" and "well-known"). Scripts which aren't written with spaces
+// between words, like Chinese and Japanese, count each character as a word.
+func countWords(s string) int {
+	words := 0
+	inWord := false
+	for i, r := range s {
+		switch {
+		case isIdeograph(r):
+			words++
+			inWord = false
+		case isWordRune(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case inWord && isWordJoiner(r):
+			// Joiners are only part of the word when another word character
+			// follows them.
+			next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+			inWord = isWordRune(next) && !isIdeograph(next)
+		default:
+			inWord = false
+		}
+	}
+
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+func isWordJoiner(r rune) bool {
+	return r == '\'' || r == '’' || r == '-'
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// plural returns n followed by the noun, which is made plural unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
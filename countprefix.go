@@ -0,0 +1,91 @@
+package main
+
+import "strconv"
+
+// maxCount is the largest count which can be typed before a key, so that a
+// mistyped one doesn't leave the editor repeating a key for a long time.
+const maxCount = 9999
+
+// pendingCount is the number of times to repeat the next key, which is typed
+// with Alt and the digit keys, or 0 when none has been typed.
+var pendingCount int
+
+// uncountableKeys aren't repeated when a count is typed before them, since
+// they prompt for input or would be pointless to repeat.
+var uncountableKeys = []rune{
+	ctrl('q'), ctrl('s'), ctrl('f'), ctrl('p'), ctrl('l'), '\x1b',
+	alt('%'), alt('s'), alt('m'), alt('\''),
+}
+
+// editorCountKey handles the keys which type a count, e.g. Alt-1 then Alt-0
+// types 10. It returns whether key was one of them.
+func editorCountKey(key rune) bool {
+	if !isAltKey(key) {
+		return false
+	}
+
+	digit := key &^ altModifier
+	if digit < '0' || digit > '9' {
+		return false
+	}
+
+	pendingCount = min(pendingCount*10+int(digit-'0'), maxCount)
+	return true
+}
+
+// editorTakeCount returns the count typed before key and clears it, or 1 when
+// there isn't one.
+func editorTakeCount(key rune) int {
+	count := pendingCount
+	pendingCount = 0
+
+	if count == 0 {
+		return 1
+	}
+	if key == '\x1b' {
+		editorSetStatusMessage("Count cancelled")
+	}
+	for _, k := range uncountableKeys {
+		if k == key {
+			return 1
+		}
+	}
+
+	return count
+}
+
+// editorRepeatKey handles key count times, as a single edit which is undone
+// all at once. Some keys use the count differently, e.g. Ctrl-G goes to the
+// line with that number.
+func editorRepeatKey(key rune, count int) {
+	switch key {
+	case ctrl('g'):
+		editorClearSelection()
+		editorGoToLineNumber(count)
+		return
+	case ctrl('k'):
+		editorUndoBoundary(key)
+		editorKillBoundary(key)
+		editorKillLines(count)
+		return
+	}
+
+	editorUndoBoundary(key)
+
+	// This may be part of repeating an edit already.
+	defer func(replaying bool) { replayingEdit = replaying }(replayingEdit)
+	replayingEdit = true
+
+	for range count {
+		editorHandleKey(key, bufferChangeCount)
+	}
+}
+
+// countKeys returns the keys which type count before a key.
+func countKeys(count int) []rune {
+	var keys []rune
+	for _, digit := range strconv.Itoa(count) {
+		keys = append(keys, alt(digit))
+	}
+	return keys
+}
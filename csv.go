@@ -0,0 +1,123 @@
+package main
+
+import "slices"
+
+// csvColumnWidths contains the width of the widest cell in each column of the
+// file, when its columns are aligned. It's recalculated before drawing when
+// csvWidthsStale is set.
+var (
+	csvColumnWidths []int
+	csvWidthsStale  = true
+)
+
+func init() {
+	bufferOnChange(func(bufferChange) { csvWidthsStale = true })
+}
+
+// editorColumnDelimiter returns the character which separates the cells of
+// each row when the columns of the file are aligned, or 0 when they aren't.
+func editorColumnDelimiter() byte {
+	if !e.alignColumns || e.syntax == nil {
+		return 0
+	}
+
+	return e.syntax.columnDelimiter
+}
+
+// csvDelimiters returns the indices in raw of the delimiters which separate
+// its cells. Delimiters in quoted cells don't count.
+func csvDelimiters(raw string, delimiter byte) []int {
+	var found []int
+	quoted := false
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '"':
+			quoted = !quoted
+		case delimiter:
+			if !quoted {
+				found = append(found, i)
+			}
+		}
+	}
+
+	return found
+}
+
+// editorUpdateColumnWidths finds the width of each column, and renders the
+// rows again when they've changed, so that the cells are padded to line up.
+func editorUpdateColumnWidths() {
+	if !csvWidthsStale {
+		return
+	}
+	csvWidthsStale = false
+
+	var widths []int
+	if delimiter := editorColumnDelimiter(); delimiter != 0 {
+		for _, row := range e.row {
+			start := 0
+			for column, end := range append(csvDelimiters(row.raw, delimiter), len(row.raw)) {
+				if column == len(widths) {
+					widths = append(widths, 0)
+				}
+				widths[column] = max(widths[column], end-start)
+				start = end + 1
+			}
+		}
+	}
+
+	if slices.Equal(widths, csvColumnWidths) {
+		return
+	}
+
+	csvColumnWidths = widths
+	for i := range e.row {
+		editorUpdateRow(&e.row[i])
+	}
+}
+
+// csvPadding returns the padding which follows each delimiter in raw, so that
+// the next cell starts at the same column in every row. Each element contains
+// the index of a delimiter, and the number of spaces after it.
+func csvPadding(raw string) [][2]int {
+	delimiter := editorColumnDelimiter()
+	if delimiter == 0 {
+		return nil
+	}
+
+	var padding [][2]int
+	start := 0
+	for column, at := range csvDelimiters(raw, delimiter) {
+		if column < len(csvColumnWidths) {
+			padding = append(padding, [2]int{at, csvColumnWidths[column] - (at - start)})
+		}
+		start = at + 1
+	}
+
+	return padding
+}
+
+// alignColumnsOption returns the option which enables aligning the columns of
+// CSV and TSV files.
+func alignColumnsOption() editorOption {
+	opt := boolOption("aligncolumns", &e.alignColumns)
+
+	set := opt.set
+	opt.set = func(value string) error {
+		if err := set(value); err != nil {
+			return err
+		}
+
+		csvWidthsStale = true
+		editorUpdateColumnWidths()
+		return nil
+	}
+
+	return opt
+}
+
+// editorHeaderPinned returns whether the first row is drawn at the top of the
+// screen, in place of the row that's scrolled there, so that the names of the
+// columns stay visible.
+func editorHeaderPinned() bool {
+	return editorColumnDelimiter() != 0 && e.rowOffset > 0
+}
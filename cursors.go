@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// cursorsRender contains the positions of the secondary cursors in the render
+// field of rows. It's updated before drawing by editorUpdateCursorsRender.
+var cursorsRender []renderPos
+
+// cursor is a secondary cursor.
+type cursor struct {
+	pos    bufferPos
+	sticky stickyColumn
+}
+
+func init() {
+	bufferOnChange(cursorsOnChange)
+}
+
+// cursorsOnChange keeps the secondary cursors on the same text when the buffer
+// changes.
+func cursorsOnChange(change bufferChange) {
+	for i, c := range e.cursors {
+		e.cursors[i].pos = c.pos.adjust(change)
+	}
+}
+
+// editorForEachCursor runs action once for each cursor, with the cursor moved
+// to it, so that edits and movements apply to all of them.
+func editorForEachCursor(action func()) {
+	if len(e.cursors) == 0 {
+		action()
+		return
+	}
+
+	// The primary cursor is kept with the others while action runs so that it
+	// follows the changes that are made at them.
+	e.cursors = slices.Insert(e.cursors, 0, cursor{bufferPos{e.cy, e.cx}, e.sticky})
+	for i := range e.cursors {
+		e.cy, e.cx = e.cursors[i].pos.line, e.cursors[i].pos.col
+		e.sticky = e.cursors[i].sticky
+		action()
+		e.cursors[i] = cursor{bufferPos{e.cy, e.cx}, e.sticky}
+	}
+
+	primary := e.cursors[0]
+	e.cursors = e.cursors[1:]
+	e.cy, e.cx = primary.pos.line, primary.pos.col
+	e.sticky = primary.sticky
+
+	// Cursors which end up in the same place are merged.
+	e.cursors = slices.DeleteFunc(e.cursors, func(c cursor) bool {
+		return c.pos == primary.pos
+	})
+	slices.SortFunc(e.cursors, func(a, b cursor) int {
+		if a.pos.before(b.pos) {
+			return -1
+		}
+		if b.pos.before(a.pos) {
+			return 1
+		}
+		return 0
+	})
+	e.cursors = slices.CompactFunc(e.cursors, func(a, b cursor) bool {
+		return a.pos == b.pos
+	})
+}
+
+// editorClearCursors removes the secondary cursors.
+func editorClearCursors() {
+	e.cursors = nil
+}
+
+// editorAddCursorAtNextMatch adds a cursor at the next occurrence of the word
+// under the cursor, at the same place in the word.
+func editorAddCursorAtNextMatch() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	raw := e.row[e.cy].raw
+	start, end := e.cx, e.cx
+	for start > 0 && !isWordSeparator(raw[start-1]) {
+		start--
+	}
+	for end < len(raw) && !isWordSeparator(raw[end]) {
+		end++
+	}
+	if start == end {
+		editorSetStatusMessage("No word under the cursor")
+		return
+	}
+
+	word := raw[start:end]
+	offset := e.cx - start
+
+	// Search after the most recently added cursor, wrapping around at the end
+	// of the file.
+	from := bufferPos{e.cy, end}
+	if len(e.cursors) > 0 {
+		last := e.cursors[len(e.cursors)-1].pos
+		from = bufferPos{last.line, last.col - offset + len(word)}
+	}
+
+	for n := 0; n <= len(e.row); n++ {
+		y := (from.line + n) % len(e.row)
+		row := e.row[y].raw
+
+		x := 0
+		if n == 0 {
+			x = min(from.col, len(row))
+		}
+
+		for {
+			i := strings.Index(row[x:], word)
+			if i < 0 {
+				break
+			}
+			i += x
+			x = i + len(word)
+
+			if i > 0 && !isWordSeparator(row[i-1]) {
+				continue
+			}
+			if x < len(row) && !isWordSeparator(row[x]) {
+				continue
+			}
+
+			pos := bufferPos{y, i + offset}
+			exists := slices.ContainsFunc(e.cursors, func(c cursor) bool {
+				return c.pos == pos
+			})
+			if pos == (bufferPos{e.cy, e.cx}) || exists {
+				editorSetStatusMessage("No more matches of %s", word)
+				return
+			}
+
+			e.cursors = append(e.cursors, cursor{pos: pos})
+			editorSetStatusMessage("%d cursors", len(e.cursors)+1)
+			return
+		}
+	}
+
+	editorSetStatusMessage("No more matches of %s", word)
+}
+
+// editorUpdateCursorsRender converts the positions of the secondary cursors to
+// positions in the render field of rows so that they can be drawn.
+func editorUpdateCursorsRender() {
+	cursorsRender = cursorsRender[:0]
+	for _, c := range e.cursors {
+		if c.pos.line < len(e.row) {
+			cursorsRender = append(cursorsRender, renderPos{c.pos.line, editorRowCxToRx(e.row[c.pos.line], c.pos.col)})
+		}
+	}
+}
+
+// isSecondaryCursor returns whether there's a secondary cursor at index rx of
+// the render field of the row at index at.
+func isSecondaryCursor(at, rx int) bool {
+	return slices.Contains(cursorsRender, renderPos{at, rx})
+}
+
+// editorDrawSecondaryCursors draws the secondary cursors which are past the
+// end of the text of the row at index at. drawn is the number of columns of the
+// screen which are already used by the row.
+func editorDrawSecondaryCursors(w io.Writer, at, drawn int) {
+	for _, pos := range cursorsRender {
+		x := pos.rx - e.colOffset
+		if pos.row != at || x < drawn || x >= editorTextCols() {
+			continue
+		}
+
+		fmt.Fprintf(w, "\x1b[%dG", editorGutterWidth()+x+1)
+		fmt.Fprint(w, editorCurrentTheme().secondaryCursor.bgSGR())
+		fmt.Fprint(w, " ")
+		fmt.Fprint(w, "\x1b[49m")
+	}
+}
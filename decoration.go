@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// editorSetVirtualText sets the text displayed after the contents of the row
+// at the given index, e.g. to show a diagnostic message. Passing an empty
+// string removes it.
+func editorSetVirtualText(at int, text string) {
+	if at < 0 || at >= len(e.row) {
+		return
+	}
+
+	e.row[at].virtualText = text
+}
+
+// editorClearVirtualText removes the virtual text from every row.
+func editorClearVirtualText() {
+	for i := range e.row {
+		e.row[i].virtualText = ""
+	}
+}
+
+// editorCellStyle returns the style of the character at index rx of the render
+// field of row. This is where decorations which change the appearance of text
+// in the file, rather than adding to it, are drawn over syntax highlighting.
+func editorCellStyle(row *editorRow, rx int) style {
+	s := editorSyntaxToStyle(row.highlight[rx])
+
+	if s.bg == (colour{}) && slices.Contains(e.colourColumns, rx+1) {
+		s.bg = editorCurrentTheme().colourColumn
+	}
+
+	s = conflictStyle(s, row.idx)
+
+	if editorIsCursorLine(row.idx) && s.bg == (colour{}) {
+		s.bg = editorCurrentTheme().cursorLine
+	}
+
+	if e.showWhitespace {
+		t := editorCurrentTheme()
+		if isTabStart(row, rx) {
+			s.fg = t.whitespace
+		}
+		if rx >= row.trailingWhitespace {
+			s.bg = t.trailingWhitespace
+		}
+	}
+
+	if isIndentGuide(row, rx) {
+		s.fg = editorCurrentTheme().indentGuide
+	}
+
+	if isSelected(row.idx, rx) {
+		s.bg = editorCurrentTheme().selection
+	}
+
+	if isSecondaryCursor(row.idx, rx) {
+		s.bg = editorCurrentTheme().secondaryCursor
+	}
+
+	if isDiagnosticRange(row.idx, rx) {
+		s.underline = true
+	}
+
+	if isMisspelled(row, rx) {
+		if misspelled := editorCurrentTheme().misspelled; misspelled != (colour{}) {
+			s.fg = misspelled
+		}
+		s.underline = true
+	}
+
+	if isMatchedBracket(row.idx, rx) {
+		matching := editorCurrentTheme().matchingBracket
+		if matching.fg != (colour{}) {
+			s.fg = matching.fg
+		}
+		if matching.bg != (colour{}) {
+			s.bg = matching.bg
+		}
+	}
+
+	return s
+}
+
+// editorIsCursorLine returns whether the row at index at should be highlighted
+// as the one containing the cursor.
+func editorIsCursorLine(at int) bool {
+	return e.cursorLine && at == e.cy
+}
+
+// isTabStart returns whether the character at index rx of the render field of
+// row is the first one which a tab was replaced with.
+func isTabStart(row *editorRow, rx int) bool {
+	_, found := slices.BinarySearch(row.tabs, rx)
+	return found
+}
+
+// isIndentGuide returns whether an indent guide is drawn at index rx of the
+// render field of row. Guides are drawn in the whitespace at the start of rows
+// of code, at each level of indentation.
+func isIndentGuide(row *editorRow, rx int) bool {
+	if !e.indentGuides || e.syntax == nil {
+		return false
+	}
+
+	return rx < row.indent && rx%editorIndentWidth() == 0
+}
+
+// editorDrawVirtualText draws the virtual text of row, if any, in the space
+// that remains on the screen after its contents. It returns the number of
+// columns which were drawn.
+func editorDrawVirtualText(w io.Writer, row editorRow, remaining int) int {
+	if row.virtualText == "" {
+		return 0
+	}
+	if e.virtualTextCursorLineOnly && row.idx != e.cy {
+		return 0
+	}
+
+	// Leave a space between the contents of the row and the virtual text.
+	remaining--
+	if remaining <= 0 {
+		return 0
+	}
+
+	// Cut the text between characters, and by the width it's displayed with,
+	// so that wide characters don't go past the edge of the screen.
+	text := row.virtualText
+	width := 0
+	for end := 0; end < len(row.virtualText); {
+		next := nextGraphemeEnd(row.virtualText, end)
+		cols := graphemeWidth(row.virtualText[end:next])
+		if width+cols > remaining {
+			text = row.virtualText[:end]
+			break
+		}
+		width += cols
+		end = next
+	}
+
+	fmt.Fprint(w, " ")
+	fmt.Fprint(w, "\x1b[2m")
+	fmt.Fprint(w, editorCurrentTheme().virtualText.fgSGR())
+	fmt.Fprint(w, text)
+	fmt.Fprint(w, "\x1b[22;39m")
+
+	return 1 + width
+}
+
+// editorDrawColourColumns draws the colour columns which are past the end of
+// what was drawn for a row. drawn is the number of columns of the screen which
+// are already used by the row. Columns inside of the text of the row are
+// drawn by editorCellStyle instead.
+func editorDrawColourColumns(w io.Writer, drawn int) {
+	if len(e.colourColumns) == 0 {
+		return
+	}
+
+	bg := editorCurrentTheme().colourColumn.bgSGR()
+	for _, column := range e.colourColumns {
+		x := column - 1 - e.colOffset
+		if x < drawn || x >= editorTextCols() {
+			continue
+		}
+
+		fmt.Fprintf(w, "\x1b[%dG", editorGutterWidth()+x+1)
+		fmt.Fprint(w, bg)
+		fmt.Fprint(w, " ")
+		fmt.Fprint(w, "\x1b[49m")
+	}
+}
+
+func colourColumnOption() editorOption {
+	return editorOption{
+		name: "colourcolumn",
+		set: func(value string) error {
+			if value == "" || value == "off" {
+				e.colourColumns = nil
+				return nil
+			}
+
+			var columns []int
+			for field := range strings.SplitSeq(value, ",") {
+				column, err := strconv.Atoi(strings.TrimSpace(field))
+				if err != nil || column < 1 {
+					return fmt.Errorf("expected a list of columns like 80,100, given %q", value)
+				}
+
+				columns = append(columns, column)
+			}
+
+			e.colourColumns = columns
+			return nil
+		},
+		get: func() string {
+			if len(e.colourColumns) == 0 {
+				return "off"
+			}
+
+			columns := make([]string, len(e.colourColumns))
+			for i, column := range e.colourColumns {
+				columns[i] = strconv.Itoa(column)
+			}
+			return strings.Join(columns, ",")
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// lspLocation is a range in a file, as given by the language server.
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+// editorLSPReady returns whether there's a language server which requests can
+// be sent to, and shows a message when there isn't.
+func editorLSPReady() bool {
+	if lsp == nil || !lsp.ready {
+		editorSetStatusMessage("No language server")
+		return false
+	}
+
+	editorLSPFlush()
+	return true
+}
+
+// editorLSPTextDocumentPosition returns the parameters which identify the
+// position of the cursor in requests to the language server.
+func editorLSPTextDocumentPosition() map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": lsp.uri},
+		"position":     lsp.toLSP(bufferPos{e.cy, e.cx}),
+	}
+}
+
+// editorGoToDefinition asks the language server where the symbol under the
+// cursor is defined, and jumps there, opening its file if necessary. The tags
+// file is used instead when there's no language server.
+func editorGoToDefinition() {
+	if lsp == nil {
+		editorGoToTag("")
+		return
+	}
+	if !editorLSPReady() {
+		return
+	}
+
+	result, err := lsp.request("textDocument/definition", editorLSPTextDocumentPosition())
+	if err != nil {
+		editorSetStatusMessage("Definition: %s", err.Error())
+		return
+	}
+
+	loc, ok := parseDefinitionResult(result)
+	if !ok {
+		editorSetStatusMessage("No definition found")
+		return
+	}
+
+	path := uriPath(loc.URI)
+	if path == "" {
+		editorSetStatusMessage("Can't open %s", loc.URI)
+		return
+	}
+
+	// The position has to be converted using the text of the file it's in,
+	// which may not be open yet.
+	pos := lsp.fromLSP(loc.Range.Start, fileLineText(path))
+
+	from := editorCurrentJump()
+	if !editorSwitchFile(path) {
+		return
+	}
+
+	editorPushJump(from)
+	editorGoToPos(pos)
+}
+
+// parseDefinitionResult returns the first location in the response to a
+// definition request, which may be a single location, or a list of either
+// locations or location links.
+func parseDefinitionResult(result json.RawMessage) (lspLocation, bool) {
+	var loc lspLocation
+	if err := json.Unmarshal(result, &loc); err == nil && loc.URI != "" {
+		return loc, true
+	}
+
+	var list []struct {
+		lspLocation
+		TargetURI            string   `json:"targetUri"`
+		TargetSelectionRange lspRange `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(result, &list); err != nil || len(list) == 0 {
+		return lspLocation{}, false
+	}
+
+	if list[0].TargetURI != "" {
+		return lspLocation{list[0].TargetURI, list[0].TargetSelectionRange}, true
+	}
+	return list[0].lspLocation, list[0].URI != ""
+}
+
+// uriPath returns the path of the file that a file:// URI refers to, or "" if
+// it's another kind of URI.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+
+	return u.Path
+}
+
+// fileLineText returns a function which returns the text of each line of the
+// file at path, for converting positions given by the language server. The
+// rows being edited are used when it's the open file.
+func fileLineText(path string) func(int) string {
+	if sameFile(path, e.filename) {
+		return editorRowText
+	}
+
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return func(int) string { return "" }
+	}
+
+	lines := strings.Split(string(bb), "\n")
+	return func(at int) string {
+		if at < 0 || at >= len(lines) {
+			return ""
+		}
+		return lines[at]
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// diagnosticSource identifies what reported a diagnostic, so that the ones
+// from each source can be replaced independently.
+type diagnosticSource int
+
+const (
+	diagnosticSourceLint diagnosticSource = iota
+	diagnosticSourceLSP
+)
+
+// diagnostic is a problem in the file, e.g. reported by a linter.
+type diagnostic struct {
+	// pos is where the problem is, and end is the end of the text which it
+	// applies to. end is the same as pos when it applies to a position rather
+	// than a range.
+	pos, end bufferPos
+	message  string
+	source   diagnosticSource
+}
+
+// diagnostics contains the problems in the file, ordered by position. Their
+// positions are adjusted as the file is edited so that they stay on the same
+// text.
+var diagnostics []diagnostic
+
+// diagnosticsRender contains the ranges of the diagnostics in render
+// coordinates, for drawing. It's updated before the screen is drawn.
+var diagnosticsRender [][2]renderPos
+
+// lastDiagnosticRow is the row that the cursor was on when the message of a
+// diagnostic was last shown, so that it's only shown when moving onto it.
+var lastDiagnosticRow = -1
+
+func init() {
+	bufferOnChange(func(change bufferChange) {
+		for i := range diagnostics {
+			diagnostics[i].pos = diagnostics[i].pos.adjust(change)
+			diagnostics[i].end = diagnostics[i].end.adjust(change)
+		}
+	})
+}
+
+// editorSetDiagnostics replaces the diagnostics reported by source with found.
+func editorSetDiagnostics(source diagnosticSource, found []diagnostic) {
+	diagnostics = slices.DeleteFunc(diagnostics, func(d diagnostic) bool {
+		return d.source == source
+	})
+	for _, d := range found {
+		d.source = source
+		diagnostics = append(diagnostics, d)
+	}
+
+	slices.SortStableFunc(diagnostics, func(a, b diagnostic) int {
+		switch {
+		case a.pos.before(b.pos):
+			return -1
+		case b.pos.before(a.pos):
+			return 1
+		}
+		return 0
+	})
+
+	lastDiagnosticRow = -1
+	editorShowDiagnosticText()
+}
+
+// editorShowDiagnosticText replaces the virtual text of each row with the
+// message of the first diagnostic on it. Blame annotations are shown instead
+// while they're on.
+func editorShowDiagnosticText() {
+	if blameAnnotations {
+		return
+	}
+
+	editorClearVirtualText()
+	for i := len(diagnostics) - 1; i >= 0; i-- {
+		d := diagnostics[i]
+		message, _, _ := strings.Cut(d.message, "\n")
+		editorSetVirtualText(d.pos.line, message)
+	}
+}
+
+// editorDiagnosticAt returns the first diagnostic on the row at index at.
+func editorDiagnosticAt(at int) (diagnostic, bool) {
+	for _, d := range diagnostics {
+		if d.pos.line == at {
+			return d, true
+		}
+	}
+
+	return diagnostic{}, false
+}
+
+// editorUpdateDiagnostics updates diagnosticsRender, and shows the message of
+// the diagnostic on the cursor's row in the status bar when the cursor moves
+// onto it.
+func editorUpdateDiagnostics() {
+	diagnosticsRender = diagnosticsRender[:0]
+	for _, d := range diagnostics {
+		if d.pos == d.end || d.pos.line >= len(e.row) || d.end.line >= len(e.row) {
+			continue
+		}
+
+		diagnosticsRender = append(diagnosticsRender, [2]renderPos{
+			{d.pos.line, editorRowCxToRx(e.row[d.pos.line], d.pos.col)},
+			{d.end.line, editorRowCxToRx(e.row[d.end.line], d.end.col)},
+		})
+	}
+
+	if inPrompt || e.cy == lastDiagnosticRow {
+		return
+	}
+
+	d, ok := editorDiagnosticAt(e.cy)
+	if !ok {
+		lastDiagnosticRow = -1
+		return
+	}
+
+	lastDiagnosticRow = e.cy
+	editorSetStatusMessage("%d:%d: %s", d.pos.line+1, d.pos.col+1, d.message)
+}
+
+// isDiagnosticRange returns whether the character at index rx of the render
+// field of the row at index at is part of the text that a diagnostic applies
+// to.
+func isDiagnosticRange(at, rx int) bool {
+	for _, r := range diagnosticsRender {
+		start, end := r[0], r[1]
+		afterStart := at > start.row || (at == start.row && rx >= start.rx)
+		beforeEnd := at < end.row || (at == end.row && rx < end.rx)
+		if afterStart && beforeEnd {
+			return true
+		}
+	}
+
+	return false
+}
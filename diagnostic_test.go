@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestSetDiagnosticsShowsVirtualText(t *testing.T) {
+	e.row = []editorRow{{raw: "package main"}, {raw: "x := 1"}, {raw: ""}}
+	t.Cleanup(func() {
+		e.row = nil
+		diagnostics = nil
+	})
+
+	editorSetDiagnostics(diagnosticSourceLint, []diagnostic{
+		{pos: bufferPos{1, 0}, end: bufferPos{1, 1}, message: "x declared and not used\nmore detail"},
+		{pos: bufferPos{1, 5}, end: bufferPos{1, 5}, message: "second problem"},
+	})
+
+	want := []string{"", "x declared and not used", ""}
+	for i, row := range e.row {
+		if row.virtualText != want[i] {
+			t.Errorf("virtual text of row %d = %q, want %q", i, row.virtualText, want[i])
+		}
+	}
+
+	editorSetDiagnostics(diagnosticSourceLint, nil)
+
+	for i, row := range e.row {
+		if row.virtualText != "" {
+			t.Errorf("virtual text of row %d = %q after clearing, want none", i, row.virtualText)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+// diffHunk is a range of lines which differ between two versions of a text.
+// The lines from oldStart up to oldEnd in the old version were replaced by the
+// lines from newStart up to newEnd in the new one.
+type diffHunk struct {
+	oldStart, oldEnd int
+	newStart, newEnd int
+}
+
+// diffLines returns the hunks which change a into b, in order, using Myers'
+// algorithm to find the smallest number of lines to insert and delete.
+func diffLines(a, b []string) []diffHunk {
+	// Lines which are the same at the start and end don't need to go through
+	// the algorithm, and this is usually most of them.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	hunks := myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for i := range hunks {
+		hunks[i].oldStart += prefix
+		hunks[i].oldEnd += prefix
+		hunks[i].newStart += prefix
+		hunks[i].newEnd += prefix
+	}
+
+	return hunks
+}
+
+// myersDiff implements diffLines without trimming the common prefix and
+// suffix.
+func myersDiff(a, b []string) []diffHunk {
+	deleted := make([]bool, len(a))
+	inserted := make([]bool, len(b))
+	markChanges(a, b, deleted, inserted)
+
+	// Lines which aren't changed are the same in both, in the same order, so
+	// the changes between each pair of them make up a hunk.
+	var hunks []diffHunk
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if (i < len(a) && deleted[i]) || (j < len(b) && inserted[j]) {
+			h := diffHunk{oldStart: i, newStart: j}
+			for i < len(a) && deleted[i] {
+				i++
+			}
+			for j < len(b) && inserted[j] {
+				j++
+			}
+			h.oldEnd, h.newEnd = i, j
+			hunks = append(hunks, h)
+			continue
+		}
+
+		i++
+		j++
+	}
+
+	return hunks
+}
+
+// markChanges sets deleted for the lines of a, and inserted for the lines of
+// b, which have to change to turn a into b. It uses the linear space variant
+// of Myers' algorithm, which finds where the middle of the shortest edit path
+// is, and then handles the parts before and after it separately. This keeps
+// the memory used proportional to the length of the texts, rather than to the
+// square of the number of differences.
+func markChanges(a, b []string, deleted, inserted []bool) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+		deleted, inserted = deleted[1:], inserted[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+		deleted, inserted = deleted[:len(deleted)-1], inserted[:len(inserted)-1]
+	}
+
+	x, y, ok := splitEdits(a, b)
+	if !ok || (x == 0 && y == 0) || (x == len(a) && y == len(b)) {
+		// There's nothing in common, or nothing to split.
+		for i := range deleted {
+			deleted[i] = true
+		}
+		for i := range inserted {
+			inserted[i] = true
+		}
+		return
+	}
+
+	markChanges(a[:x], b[:y], deleted[:x], inserted[:y])
+	markChanges(a[x:], b[y:], deleted[x:], inserted[y:])
+}
+
+// splitEdits returns a point in the middle of a shortest edit path from a to
+// b, where the lines up to x in a and y in b can be diffed separately from the
+// rest. It searches forwards from the start and backwards from the end at the
+// same time until the paths overlap. ok is false when a and b have nothing in
+// common, or either is empty.
+func splitEdits(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[offset+k] is the furthest index into a reached on diagonal k,
+	// where the index into b is that index minus k, searching from the start.
+	// backward is the same searching from the end, counting from the end.
+	// They're -1 for diagonals which haven't been reached.
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	// When delta is odd, the paths meet while searching forwards.
+	front := delta%2 != 0
+
+	// Diagonals which run off of the edge are trimmed from the search.
+	forwardStart, forwardEnd := 0, 0
+	backwardStart, backwardEnd := 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				x = forward[i+1]
+			} else {
+				x = forward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case front:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && x >= n-backward[j] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && backward[i-1] < backward[i+1]) {
+				x = backward[i+1]
+			} else {
+				x = backward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[i] = x
+
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !front:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					forwardX := forward[j]
+					forwardY := forwardX - (delta - k)
+					if forwardX >= n-x {
+						return forwardX, forwardY, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
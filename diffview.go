@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// diffViewLine is a row of a side-by-side diff. Lines which were only added or
+// deleted have nothing on the other side.
+type diffViewLine struct {
+	left, right       string
+	hasLeft, hasRight bool
+	changed           bool
+	// leftChange and rightChange are the ranges of runes in left and right
+	// which differ, when a line was changed rather than added or deleted.
+	leftChange, rightChange [2]int
+}
+
+// diffView is a side-by-side comparison of two texts, which is displayed in
+// place of the file.
+type diffView struct {
+	leftTitle, rightTitle string
+	lines                 []diffViewLine
+	// hunks contains the indices in lines where each change starts.
+	hunks []int
+	// offset and colOffset are how far the view is scrolled, which is the same
+	// for both sides.
+	offset, colOffset int
+}
+
+// activeDiffView is the diff being displayed, if any.
+var activeDiffView *diffView
+
+// newDiffView compares the lines in a and b, lining up those which are the
+// same.
+func newDiffView(leftTitle string, a []string, rightTitle string, b []string) *diffView {
+	v := &diffView{leftTitle: leftTitle, rightTitle: rightTitle}
+
+	i, j := 0, 0
+	same := func(end int) {
+		for ; i < end; i, j = i+1, j+1 {
+			left, right := expandTabs(a[i]), expandTabs(b[j])
+			v.lines = append(v.lines, diffViewLine{left: left, right: right, hasLeft: true, hasRight: true})
+		}
+	}
+
+	for _, h := range diffLines(a, b) {
+		same(h.oldStart)
+		v.hunks = append(v.hunks, len(v.lines))
+
+		oldLen, newLen := h.oldEnd-h.oldStart, h.newEnd-h.newStart
+		for k := range max(oldLen, newLen) {
+			var line diffViewLine
+			if k < oldLen {
+				line.left, line.hasLeft = expandTabs(a[h.oldStart+k]), true
+			}
+			if k < newLen {
+				line.right, line.hasRight = expandTabs(b[h.newStart+k]), true
+			}
+			line.changed = true
+			line.leftChange, line.rightChange = intraLineChange(line.left, line.right)
+			if !line.hasLeft || !line.hasRight {
+				line.leftChange = [2]int{0, len([]rune(line.left))}
+				line.rightChange = [2]int{0, len([]rune(line.right))}
+			}
+			v.lines = append(v.lines, line)
+		}
+
+		i, j = h.oldEnd, h.newEnd
+	}
+	same(len(a))
+
+	return v
+}
+
+// intraLineChange returns the ranges of runes in a and b which differ, after
+// removing their common prefix and suffix.
+func intraLineChange(a, b string) (aRange, bRange [2]int) {
+	ar, br := []rune(a), []rune(b)
+
+	prefix := 0
+	for prefix < len(ar) && prefix < len(br) && ar[prefix] == br[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ar)-prefix && suffix < len(br)-prefix && ar[len(ar)-1-suffix] == br[len(br)-1-suffix] {
+		suffix++
+	}
+
+	return [2]int{prefix, len(ar) - suffix}, [2]int{prefix, len(br) - suffix}
+}
+
+// editorDiffFiles shows a side-by-side diff of the files at the paths a and b.
+// It's used for lte --diff a b.
+func editorDiffFiles(a, b string) error {
+	aLines, err := readLines(a)
+	if err != nil {
+		return err
+	}
+	bLines, err := readLines(b)
+	if err != nil {
+		return err
+	}
+
+	runDiffView(newDiffView(a, aLines, b, bLines))
+	return nil
+}
+
+// readLines returns the lines of the file at path.
+func readLines(path string) ([]string, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for line := range strings.Lines(string(bb)) {
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	return lines, nil
+}
+
+// runDiffView displays v until it's closed. Both sides scroll together with
+// the arrow and page keys, and n and N move to the next and previous changes.
+func runDiffView(v *diffView) {
+	activeDiffView = v
+	defer func() { activeDiffView = nil }()
+
+	help := fmt.Sprintf("%d changes | n/N = next/previous change | q = close", len(v.hunks))
+	if len(v.hunks) == 0 {
+		help = "No differences | q = close"
+	}
+
+	for {
+		editorSetPromptMessage("%s", help)
+		editorRefreshScreen()
+
+		rows := diffViewRows()
+		switch editorReadKey() {
+		case arrowUp:
+			v.scroll(-1)
+		case arrowDown:
+			v.scroll(1)
+		case pageUp:
+			v.scroll(-rows)
+		case pageDown:
+			v.scroll(rows)
+		case arrowLeft:
+			v.colOffset = max(v.colOffset-diffViewSideScroll, 0)
+		case arrowRight:
+			v.colOffset += diffViewSideScroll
+		case 'n':
+			v.nextHunk(1)
+		case 'N':
+			v.nextHunk(-1)
+		case '\x1b', 'q', ctrl('q'):
+			editorSetStatusMessage("")
+			return
+		}
+	}
+}
+
+// diffViewSideScroll is the number of columns that the left and right arrow
+// keys scroll a diff by.
+const diffViewSideScroll = 8
+
+// diffViewRows returns the number of rows of the screen which show lines of a
+// diff. The first one shows the titles of the sides.
+func diffViewRows() int {
+	return max(e.screenRows-1, 1)
+}
+
+// scroll scrolls v by delta lines.
+func (v *diffView) scroll(delta int) {
+	v.offset = max(0, min(v.offset+delta, len(v.lines)-diffViewRows()))
+}
+
+// nextHunk scrolls v to the next change, or the previous one when dir is
+// negative.
+func (v *diffView) nextHunk(dir int) {
+	for k := range v.hunks {
+		if dir < 0 {
+			k = len(v.hunks) - 1 - k
+		}
+
+		if h := v.hunks[k]; (dir > 0 && h > v.offset) || (dir < 0 && h < v.offset) {
+			v.offset = h
+			v.scroll(0)
+			return
+		}
+	}
+}
+
+// editorDrawDiffView draws v in the area of the screen which normally contains
+// the file, with each side taking up half of the width.
+func editorDrawDiffView(w io.Writer, v *diffView) {
+	width := max((e.screenCols-1)/2, 1)
+
+	fmt.Fprint(w, "\x1b[7m")
+	fmt.Fprint(w, padRunes(truncateRunes(v.leftTitle, width), width))
+	fmt.Fprint(w, "│")
+	fmt.Fprint(w, truncateRunes(v.rightTitle, e.screenCols-width-1))
+	fmt.Fprint(w, "\x1b[m\x1b[K\r\n")
+
+	t := editorCurrentTheme()
+	for y := range diffViewRows() {
+		i := v.offset + y
+		if i < len(v.lines) {
+			line := v.lines[i]
+			drawDiffSide(w, line.left, line.hasLeft, line.changed, line.leftChange, t.diffDeleted, width, v.colOffset)
+			fmt.Fprint(w, "│")
+			drawDiffSide(w, line.right, line.hasRight, line.changed, line.rightChange, t.diffAdded, e.screenCols-width-1, v.colOffset)
+		}
+
+		fmt.Fprint(w, "\x1b[m\x1b[K\r\n")
+	}
+}
+
+// drawDiffSide draws one side of a line of a diff, padded to width columns.
+// Changed lines are given the background colour changed, with the runes in
+// the range change in a stronger one.
+func drawDiffSide(w io.Writer, text string, present, isChanged bool, change [2]int, changed colour, width, colOffset int) {
+	t := editorCurrentTheme()
+
+	runes := []rune(text)
+	var prev *style
+	for x := range width {
+		i := colOffset + x
+		s := style{}
+		switch {
+		case !present:
+			s.bg = t.diffFiller
+		case isChanged && i >= change[0] && i < change[1]:
+			s.bg = changed
+		case isChanged:
+			s.bg = t.diffChanged
+		}
+
+		r := ' '
+		if present && i < len(runes) {
+			r = runes[i]
+		}
+
+		if prev == nil || *prev != s {
+			fmt.Fprint(w, s.sgr())
+			prev = &s
+		}
+		fmt.Fprint(w, string(r))
+	}
+
+	fmt.Fprint(w, "\x1b[24;39;49m")
+}
+
+// truncateRunes returns the first n runes of s.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	return string(r[:min(len(r), max(n, 0))])
+}
+
+// padRunes pads s with spaces to n runes.
+func padRunes(s string, n int) string {
+	return s + strings.Repeat(" ", max(n-len([]rune(s)), 0))
+}
+
+// editorDiffSaved shows what would change if the file were saved, by
+// comparing the rows with the contents of the file on disk.
+func editorDiffSaved() {
+	if e.filename == "" {
+		editorSetStatusMessage("The file hasn't been saved")
+		return
+	}
+
+	saved, err := readLines(e.filename)
+	if err != nil {
+		editorSetStatusMessage("Can't read %s: %s", e.filename, err.Error())
+		return
+	}
+
+	rows := make([]string, len(e.row))
+	for i, row := range e.row {
+		rows[i] = row.raw
+	}
+
+	runDiffView(newDiffView(e.filename+" (saved)", saved, e.filename+" (unsaved)", rows))
+}
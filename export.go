@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// exportDefaultStyle is used for text which the theme displays in the
+// terminal's default colours, since those aren't known. Most terminals are
+// light text on a dark background.
+var exportDefaultStyle = style{fg: indexedColour(7), bg: indexedColour(0)}
+
+// editorExportHTML writes the file, coloured by its syntax highlighting in the
+// current theme, to a standalone HTML file. args is the path to write to,
+// which defaults to the name of the file with .html appended.
+func editorExportHTML(args string) {
+	path := args
+	if path == "" {
+		if e.filename == "" {
+			editorSetStatusMessage("No file name to export to")
+			return
+		}
+		path = e.filename + ".html"
+	}
+
+	title := filepath.Base(e.filename)
+	if e.filename == "" {
+		title = filepath.Base(path)
+	}
+	if err := os.WriteFile(path, []byte(exportHTML(title)), 0o644); err != nil {
+		editorSetStatusMessage("Can't export: %s", err)
+		return
+	}
+
+	editorSetStatusMessage("Exported to %s", path)
+}
+
+// exportHTML returns an HTML document with the given title which contains the
+// rendered rows of the file.
+func exportHTML(title string) string {
+	normal := editorSyntaxToStyle(highlightNormal)
+	if normal.fg == (colour{}) {
+		normal.fg = exportDefaultStyle.fg
+	}
+	if normal.bg == (colour{}) {
+		normal.bg = exportDefaultStyle.bg
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n</head>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "<body style=\"margin: 0; background: %s\">\n", normal.bg.css())
+	fmt.Fprintf(&b, "<pre style=\"margin: 0; padding: 1em; color: %s; background: %s\">", normal.fg.css(), normal.bg.css())
+
+	for i := range e.row {
+		exportRow(&b, &e.row[i])
+		b.WriteByte('\n')
+	}
+
+	b.WriteString("</pre>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// exportRow writes the render of row to b, with a span around each run of
+// characters which aren't displayed in the normal style.
+func exportRow(b *strings.Builder, row *editorRow) {
+	normal := editorSyntaxToStyle(highlightNormal)
+
+	for i := 0; i < len(row.render); {
+		s := editorSyntaxToStyle(row.highlight[i])
+
+		end := i
+		for end < len(row.render) && editorSyntaxToStyle(row.highlight[end]) == s {
+			_, size := utf8.DecodeRuneInString(row.render[end:])
+			end += size
+		}
+
+		text := html.EscapeString(row.render[i:end])
+		if s == normal {
+			b.WriteString(text)
+		} else {
+			fmt.Fprintf(b, "<span style=\"%s\">%s</span>", s.css(), text)
+		}
+		i = end
+	}
+}
+
+// css returns the declarations which display text in s.
+func (s style) css() string {
+	var declarations []string
+	if s.fg != (colour{}) {
+		declarations = append(declarations, "color: "+s.fg.css())
+	}
+	if s.bg != (colour{}) {
+		declarations = append(declarations, "background: "+s.bg.css())
+	}
+	if s.underline {
+		declarations = append(declarations, "text-decoration: underline")
+	}
+
+	return strings.Join(declarations, "; ")
+}
+
+// css returns c as a CSS colour. The terminal's default colour is returned as
+// inherit, since it isn't known.
+func (c colour) css() string {
+	switch c.kind {
+	case colourIndexed:
+		r, g, b := paletteToRGB(c.index)
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	case colourRGB:
+		return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+	}
+
+	return "inherit"
+}
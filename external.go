@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runShellCommand runs command with sh, passing input to its stdin, and
+// returns what it writes to stdout, even when it fails. Raw mode is disabled
+// while it runs in case it interacts with the terminal. When the command
+// fails, the error contains the first line that it wrote to stderr.
+func runShellCommand(command string, input string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = strings.NewReader(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	disableRawInput()
+	err := cmd.Run()
+	if rawErr := enableRawInput(); rawErr != nil {
+		die(rawErr.Error())
+	}
+
+	if err != nil {
+		message, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if message == "" {
+			return stdout.String(), err
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return stdout.String(), fmt.Errorf("exit status %d: %s", exitErr.ExitCode(), message)
+		}
+		return stdout.String(), fmt.Errorf("%w: %s", err, message)
+	}
+
+	return stdout.String(), nil
+}
+
+// editorPipeCommand replaces the selected text, or the whole file, with the
+// output of the shell command in args when given it as input.
+func editorPipeCommand(command string) {
+	if command == "" {
+		editorSetStatusMessage("Usage: pipe <command>")
+		return
+	}
+
+	start, end, selected := editorSelection()
+	if !selected {
+		start, end = bufferPos{0, 0}, bufferPos{len(e.row), 0}
+	}
+
+	input := bufferText(start, end)
+
+	output, err := runShellCommand(command, input)
+	if err != nil {
+		editorSetStatusMessage("%s: %s", command, err.Error())
+		return
+	}
+
+	// Commands usually end their output with a newline even when their input
+	// didn't, e.g. when part of a row is selected.
+	if selected && !strings.HasSuffix(input, "\n") {
+		output = strings.TrimSuffix(output, "\n")
+	}
+
+	editorClearSelection()
+	if output == input {
+		return
+	}
+
+	if selected {
+		end := bufferReplaceRange(start, end, output)
+		e.cy, e.cx = end.line, end.col
+	} else {
+		bufferReplaceRange(start, end, output)
+		e.cy = min(e.cy, max(len(e.row)-1, 0))
+		editorClampCursor()
+	}
+
+	editorSetStatusMessage("Piped through %s", command)
+}
+
+// editorRunCommand runs the shell command in args and shows its output in
+// place of the file. The exit status is shown in the status bar.
+func editorRunCommand(command string) {
+	if command == "" {
+		editorSetStatusMessage("Usage: !<command>")
+		return
+	}
+
+	output, err := runShellCommand(command, "")
+
+	status := "exit status 0"
+	if err != nil {
+		status = err.Error()
+	}
+
+	var lines []string
+	for line := range strings.Lines(output) {
+		lines = append(lines, expandTabs(strings.TrimSuffix(line, "\n")))
+	}
+	if len(lines) > 0 {
+		editorShowOverlay(fmt.Sprintf("!%s (%s)", command, status), lines)
+	}
+
+	editorSetStatusMessage("!%s: %s", command, status)
+}
+
+// editorReadCommand handles input like "r !command" from the command prompt,
+// which inserts the output of the shell command at the cursor.
+func editorReadCommand(args string) {
+	command, ok := strings.CutPrefix(args, "!")
+	command = strings.TrimSpace(command)
+	if !ok || command == "" {
+		editorSetStatusMessage("Usage: r !<command>")
+		return
+	}
+
+	output, err := runShellCommand(command, "")
+	if err != nil {
+		editorSetStatusMessage("!%s: %s", command, err.Error())
+		return
+	}
+
+	editorDeleteSelection()
+	editorInsertText(output)
+	editorSetStatusMessage("!%s: exit status 0", command)
+}
+
+// expandTabs replaces the tabs in s with spaces up to the next tab stop.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := e.tabStop - col%e.tabStop
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+
+		b.WriteRune(r)
+		col++
+	}
+
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// editorFormatter returns the shell command used to format the file, or ""
+// when there isn't one.
+func editorFormatter() string {
+	if e.formatter != "" {
+		return e.formatter
+	}
+	if e.syntax != nil {
+		return e.syntax.formatter
+	}
+
+	return ""
+}
+
+// editorFormat runs the file through its formatter and replaces the rows which
+// changed. Only those rows are touched so that the cursor, marks, etc. stay
+// where they were.
+func editorFormat() error {
+	formatter := editorFormatter()
+	if formatter == "" {
+		editorSetStatusMessage("No formatter for this file type")
+		return errors.New("no formatter")
+	}
+
+	output, err := runShellCommand(formatter, string(editorRowsToString()))
+	if err != nil {
+		err = fmt.Errorf("%s: %w", formatter, err)
+		editorSetStatusMessage("%s", err.Error())
+		return err
+	}
+
+	var lines []string
+	for line := range strings.Lines(output) {
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+
+	hunks := diffLines(rowsText(0, len(e.row)-1), lines)
+	if len(hunks) == 0 {
+		editorSetStatusMessage("Already formatted")
+		return nil
+	}
+
+	cursor := mapPosThroughHunks(hunks, rowsText(0, len(e.row)-1), lines, bufferPos{e.cy, e.cx})
+
+	// Going from the bottom keeps the row indices of earlier hunks valid.
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+
+		text := ""
+		if h.newEnd > h.newStart {
+			text = strings.Join(lines[h.newStart:h.newEnd], "\n") + "\n"
+		}
+		bufferReplaceRange(bufferPos{h.oldStart, 0}, bufferPos{h.oldEnd, 0}, text)
+	}
+
+	e.cy, e.cx = cursor.line, cursor.col
+	e.cy = min(e.cy, max(len(e.row)-1, 0))
+	editorClampCursor()
+
+	editorSetStatusMessage("Formatted with %s (%d changes)", formatter, len(hunks))
+	return nil
+}
+
+// mapPosThroughHunks returns the position in newLines which corresponds to pos
+// in oldLines, given the hunks which change one into the other. A position in
+// a row which was changed moves to the new row with the same text apart from
+// indentation, if there is one, keeping its place in the text.
+func mapPosThroughHunks(hunks []diffHunk, oldLines, newLines []string, pos bufferPos) bufferPos {
+	shift := 0
+	for _, h := range hunks {
+		if pos.line < h.oldStart {
+			break
+		}
+		if pos.line >= h.oldEnd {
+			shift = h.newEnd - h.oldEnd
+			continue
+		}
+
+		if h.newEnd == h.newStart {
+			return bufferPos{h.newStart, 0}
+		}
+
+		old := oldLines[pos.line]
+		oldIndent := len(leadingWhitespace(old))
+		for i := h.newStart; i < h.newEnd; i++ {
+			line := newLines[i]
+			indent := len(leadingWhitespace(line))
+			if line[indent:] == old[oldIndent:] {
+				return bufferPos{i, max(pos.col-oldIndent, 0) + indent}
+			}
+		}
+
+		// The row changed, so keep it at the same offset in the hunk.
+		return bufferPos{h.newStart + min(pos.line-h.oldStart, h.newEnd-h.newStart-1), pos.col}
+	}
+
+	return bufferPos{pos.line + shift, pos.col}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitIndexLines contains the lines of the open file as they are in the git
+// index, or nil when it isn't tracked by git.
+var gitIndexLines []string
+
+// gitHunks contains the differences between gitIndexLines and the rows. It's
+// brought up to date before drawing when gitHunksStale is set.
+var (
+	gitHunks      []diffHunk
+	gitHunksStale bool
+)
+
+// gitGeneration is incremented each time the index is read, so that results
+// which arrive after a newer read are ignored.
+var gitGeneration int
+
+func init() {
+	bufferOnChange(func(bufferChange) { gitHunksStale = true })
+}
+
+// editorRefreshGitSigns reads the open file from the git index in the
+// background, and updates the markers in the gutter which show the lines
+// that differ from it. It's done when the file is opened or saved.
+func editorRefreshGitSigns() {
+	if e.filename == "" {
+		return
+	}
+
+	gitGeneration++
+	generation := gitGeneration
+
+	dir, name := filepath.Split(e.filename)
+	cmd := exec.Command("git", "show", ":./"+name)
+	cmd.Dir = dir
+
+	go func() {
+		output, err := cmd.Output()
+
+		editorPostToMain(func() {
+			if generation != gitGeneration {
+				return
+			}
+
+			gitIndexLines = nil
+			if err == nil {
+				gitIndexLines = make([]string, 0)
+				for line := range strings.Lines(string(output)) {
+					gitIndexLines = append(gitIndexLines, strings.TrimSuffix(line, "\n"))
+				}
+			}
+			gitHunksStale = true
+		})
+	}()
+}
+
+// editorClearGitSigns removes the markers, e.g. when switching files.
+func editorClearGitSigns() {
+	gitGeneration++
+	gitIndexLines = nil
+	gitHunks = nil
+	gitHunksStale = false
+}
+
+// editorUpdateGitSigns brings gitHunks up to date with the rows. It's called
+// before drawing.
+func editorUpdateGitSigns() {
+	if !gitHunksStale {
+		return
+	}
+	gitHunksStale = false
+
+	if gitIndexLines == nil {
+		gitHunks = nil
+		return
+	}
+
+	lines := make([]string, len(e.row))
+	for i, row := range e.row {
+		lines[i] = row.raw
+	}
+	gitHunks = diffLines(gitIndexLines, lines)
+}
+
+// gitSignAt returns the marker to show in the gutter for the row at index at,
+// and its colour, or "" when the row is unchanged.
+func gitSignAt(at int) (string, colour) {
+	t := editorCurrentTheme()
+	for _, h := range gitHunks {
+		switch {
+		case h.newStart == h.newEnd:
+			// Deleted lines are shown on the row after them, or the last row
+			// when they were at the end.
+			if at == min(h.newStart, len(e.row)-1) {
+				return "-", t.gitDeleted
+			}
+		case at >= h.newStart && at < h.newEnd:
+			if h.oldStart == h.oldEnd {
+				return "+", t.gitAdded
+			}
+			return "~", t.gitChanged
+		}
+	}
+
+	return "", colour{}
+}
+
+// editorDrawGitSign draws the marker for the row at index at, and returns
+// whether there was one.
+func editorDrawGitSign(w io.Writer, at int) bool {
+	sign, c := gitSignAt(at)
+	if sign == "" {
+		return false
+	}
+
+	fmt.Fprint(w, c.fgSGR())
+	fmt.Fprint(w, sign)
+	fmt.Fprint(w, "\x1b[39m")
+	fmt.Fprint(w, " ")
+	return true
+}
+
+// editorNextHunk moves the cursor to the start of the next changed part of
+// the file, or the previous one when dir is negative.
+func editorNextHunk(dir int) {
+	editorUpdateGitSigns()
+	if len(gitHunks) == 0 {
+		editorSetStatusMessage("No changes")
+		return
+	}
+
+	target := -1
+	if dir > 0 {
+		for _, h := range gitHunks {
+			if h.newStart > e.cy {
+				target = h.newStart
+				break
+			}
+		}
+	} else {
+		for i := len(gitHunks) - 1; i >= 0; i-- {
+			// The cursor may be inside of the hunk rather than at its start.
+			if h := gitHunks[i]; h.newStart < e.cy {
+				target = h.newStart
+				break
+			}
+		}
+	}
+
+	if target < 0 {
+		editorSetStatusMessage("No more changes")
+		return
+	}
+
+	editorRecordJump()
+	editorGoToPos(bufferPos{min(target, max(len(e.row)-1, 0)), 0})
+}
+
+// editorGitDiffCommand reads the file from the git index again, e.g. after
+// staging changes outside of the editor.
+func editorGitDiffCommand(string) {
+	editorRefreshGitSigns()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const zeroWidthJoiner rune = '\u200d'
+
+// nextGraphemeEnd returns the index after the end of the grapheme cluster
+// (i.e. what the user sees as a single character) which starts at index i of
+// s.
+//
+// This is an approximation of the rules in Unicode Standard Annex #29 which
+// handles combining marks, variation selectors, emoji modifiers, emoji joined
+// with zero width joiners, and flags.
+func nextGraphemeEnd(s string, i int) int {
+	if i >= len(s) {
+		return len(s)
+	}
+
+	r, size := utf8.DecodeRuneInString(s[i:])
+	end := i + size
+
+	if isRegionalIndicator(r) {
+		// Flags are made up of a pair of regional indicators.
+		next, nextSize := utf8.DecodeRuneInString(s[end:])
+		if isRegionalIndicator(next) {
+			end += nextSize
+		}
+	}
+
+	prev := r
+	for end < len(s) {
+		next, nextSize := utf8.DecodeRuneInString(s[end:])
+		if !isGraphemeExtend(next) && prev != zeroWidthJoiner {
+			break
+		}
+
+		end += nextSize
+		prev = next
+	}
+
+	return end
+}
+
+// prevGraphemeStart returns the index of the start of the grapheme cluster
+// which ends at index i of s.
+func prevGraphemeStart(s string, i int) int {
+	// Grapheme clusters can't reliably be found by scanning backwards (e.g. for
+	// sequences of flags), so scan forwards from the start of the string
+	// instead. Rows are short enough for this to be fast.
+	start := 0
+	for start < i {
+		end := nextGraphemeEnd(s, start)
+		if end >= i {
+			break
+		}
+		start = end
+	}
+
+	return start
+}
+
+// graphemeStartAt returns the index of the start of the grapheme cluster which
+// contains index i of s. This is used to ensure that the cursor doesn't end up
+// in the middle of a character.
+func graphemeStartAt(s string, i int) int {
+	if i >= len(s) {
+		return i
+	}
+
+	return prevGraphemeStart(s, i+1)
+}
+
+// isGraphemeExtend returns whether r extends the preceding grapheme cluster
+// instead of starting a new one.
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zeroWidthJoiner ||
+		(r >= '\ufe00' && r <= '\ufe0f') || // variation selectors
+		(r >= '\U000e0100' && r <= '\U000e01ef') || // variation selectors supplement
+		(r >= '\U0001f3fb' && r <= '\U0001f3ff') // emoji skin tone modifiers
+}
+
+// graphemeWidth returns the number of columns of the terminal which the
+// grapheme cluster g takes up. Like nextGraphemeEnd, it's an approximation,
+// of the East Asian Width property in this case.
+func graphemeWidth(g string) int {
+	r, _ := utf8.DecodeRuneInString(g)
+	switch {
+	case g == "" || isGraphemeExtend(r):
+		return 0
+	case isWideRune(r) || isRegionalIndicator(r):
+		return 2
+	}
+
+	return 1
+}
+
+// isWideRune returns whether r is displayed across two columns, like CJK
+// characters and most emoji.
+func isWideRune(r rune) bool {
+	return (r >= '\u1100' && r <= '\u115f') || // Hangul Jamo
+		(r >= '\u2e80' && r <= '\u303e') || // CJK radicals and punctuation
+		(r >= '\u3041' && r <= '\u33ff') || // kana and CJK symbols
+		(r >= '\u3400' && r <= '\u4dbf') || // CJK extension A
+		(r >= '\u4e00' && r <= '\u9fff') || // CJK unified ideographs
+		(r >= '\ua000' && r <= '\ua4cf') || // Yi
+		(r >= '\uac00' && r <= '\ud7a3') || // Hangul syllables
+		(r >= '\uf900' && r <= '\ufaff') || // CJK compatibility ideographs
+		(r >= '\ufe30' && r <= '\ufe4f') || // CJK compatibility forms
+		(r >= '\uff00' && r <= '\uff60') || // fullwidth forms
+		(r >= '\uffe0' && r <= '\uffe6') ||
+		(r >= '\U0001f300' && r <= '\U0001f64f') || // emoji
+		(r >= '\U0001f900' && r <= '\U0001f9ff') ||
+		(r >= '\U00020000' && r <= '\U0003fffd') // CJK extensions
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= '\U0001f1e6' && r <= '\U0001f1ff'
+}
@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestNextGraphemeEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		i    int
+		want int
+	}{
+		{name: "ascii", s: "abc", i: 0, want: 1},
+		{name: "middle of ascii", s: "abc", i: 1, want: 2},
+		{name: "end of string", s: "abc", i: 3, want: 3},
+		{name: "past end of string", s: "abc", i: 5, want: 3},
+		{name: "two byte character", s: "éa", i: 0, want: 2},
+		{name: "combining mark", s: "e\u0301a", i: 0, want: 3},
+		{name: "several combining marks", s: "a\u0300\u0316b", i: 0, want: 5},
+		{name: "cjk", s: "日本語", i: 0, want: 3},
+		{name: "second cjk character", s: "日本語", i: 3, want: 6},
+		{name: "emoji", s: "😀a", i: 0, want: 4},
+		{name: "emoji with skin tone", s: "👍🏽a", i: 0, want: 8},
+		{name: "emoji with variation selector", s: "❤\ufe0fa", i: 0, want: 6},
+		{name: "zwj sequence", s: "👨\u200d👩\u200d👧a", i: 0, want: 18},
+		{name: "flag", s: "🇨🇦a", i: 0, want: 8},
+		{name: "adjacent flags", s: "🇨🇦🇯🇵", i: 0, want: 8},
+		{name: "second of adjacent flags", s: "🇨🇦🇯🇵", i: 8, want: 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextGraphemeEnd(tt.s, tt.i); got != tt.want {
+				t.Errorf("nextGraphemeEnd(%q, %d) = %d, want %d", tt.s, tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrevGraphemeStart(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		i    int
+		want int
+	}{
+		{name: "ascii", s: "abc", i: 3, want: 2},
+		{name: "start of string", s: "abc", i: 0, want: 0},
+		{name: "two byte character", s: "aé", i: 3, want: 1},
+		{name: "combining mark", s: "ae\u0301", i: 4, want: 1},
+		{name: "cjk", s: "日本語", i: 9, want: 6},
+		{name: "emoji with skin tone", s: "a👍🏽", i: 9, want: 1},
+		{name: "zwj sequence", s: "a👨\u200d👩\u200d👧", i: 19, want: 1},
+		{name: "flag", s: "a🇨🇦", i: 9, want: 1},
+		{name: "second of adjacent flags", s: "🇨🇦🇯🇵", i: 16, want: 8},
+		{name: "first of adjacent flags", s: "🇨🇦🇯🇵", i: 8, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prevGraphemeStart(tt.s, tt.i); got != tt.want {
+				t.Errorf("prevGraphemeStart(%q, %d) = %d, want %d", tt.s, tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphemeStartAt(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		i    int
+		want int
+	}{
+		{name: "ascii", s: "abc", i: 1, want: 1},
+		{name: "end of string", s: "abc", i: 3, want: 3},
+		{name: "inside two byte character", s: "aé", i: 2, want: 1},
+		{name: "on combining mark", s: "ae\u0301", i: 2, want: 1},
+		{name: "inside cjk character", s: "日本語", i: 4, want: 3},
+		{name: "on skin tone", s: "👍🏽", i: 5, want: 0},
+		{name: "inside zwj sequence", s: "a👨\u200d👩\u200d👧", i: 8, want: 1},
+		{name: "on second regional indicator", s: "🇨🇦🇯🇵", i: 4, want: 0},
+		{name: "on second flag", s: "🇨🇦🇯🇵", i: 12, want: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphemeStartAt(tt.s, tt.i); got != tt.want {
+				t.Errorf("graphemeStartAt(%q, %d) = %d, want %d", tt.s, tt.i, got, tt.want)
+			}
+		})
+	}
+}
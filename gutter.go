@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// gutterWidth is the number of columns used by the gutter when it's shown:
+// one for a marker, and one to separate it from the text.
+const gutterWidth = 2
+
+// editorGutterWidth returns the number of columns at the left of the screen
+// which show markers next to rows. The gutter is only shown when there are
+// markers, or there may be soon, so that it doesn't take up space otherwise.
+func editorGutterWidth() int {
+	if len(diagnostics) > 0 || lsp != nil || len(gitHunks) > 0 {
+		return gutterWidth
+	}
+
+	return 0
+}
+
+// editorTextCols returns the number of columns available to display the
+// contents of rows.
+func editorTextCols() int {
+	return max(e.screenCols-editorGutterWidth()-editorPreviewWidth(), 0)
+}
+
+// editorDrawGutter draws the gutter for the row at index at.
+func editorDrawGutter(w io.Writer, at int) {
+	if editorGutterWidth() == 0 {
+		return
+	}
+
+	if _, ok := editorDiagnosticAt(at); ok {
+		fmt.Fprint(w, editorCurrentTheme().diagnostic.fgSGR())
+		fmt.Fprint(w, "●")
+		fmt.Fprint(w, "\x1b[39m")
+		fmt.Fprint(w, " ")
+		return
+	}
+
+	if editorDrawGitSign(w, at) {
+		return
+	}
+
+	fmt.Fprint(w, "  ")
+}
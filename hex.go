@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// hexEditor is a view of the bytes of a file as a hex dump, which can be
+// edited by overwriting them. It's displayed in place of the rows.
+type hexEditor struct {
+	path string
+	data []byte
+	// cursor is the index of the byte the cursor is on, and lowNibble is set
+	// when the next hex digit typed replaces its low 4 bits.
+	cursor    int
+	lowNibble bool
+	// ascii is set when typing replaces bytes with characters, rather than
+	// hex digits.
+	ascii bool
+	// offset is the index of the line at the top of the screen.
+	offset int
+	dirty  bool
+}
+
+// activeHexEditor is the hex editor being displayed, if any.
+var activeHexEditor *hexEditor
+
+// editorHexCommand switches to editing the bytes of the open file. The file
+// is loaded again afterwards if they were saved.
+func editorHexCommand(string) {
+	if e.filename == "" {
+		editorSetStatusMessage("The file hasn't been saved")
+		return
+	}
+	if e.dirty {
+		editorSetStatusMessage("Save the file before switching to hex mode")
+		return
+	}
+
+	saved, err := editorHexEdit(e.filename)
+	if err != nil {
+		editorSetStatusMessage("Can't open %s: %s", e.filename, err.Error())
+		return
+	}
+
+	if saved {
+		editorReloadFile()
+	}
+}
+
+// editorHexEdit edits the bytes of the file at path until the hex editor is
+// closed. It returns whether changes were saved.
+func editorHexEdit(path string) (saved bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	h := &hexEditor{path: path, data: data}
+	activeHexEditor = h
+	defer func() { activeHexEditor = nil }()
+
+	editorSetPromptMessage("HEX: Tab = switch to text | Ctrl-S = save | Esc = close")
+	for {
+		editorRefreshScreen()
+
+		switch key := editorReadKey(); key {
+		case arrowLeft:
+			h.move(-1)
+		case arrowRight:
+			h.move(1)
+		case arrowUp:
+			h.move(-h.bytesPerLine())
+		case arrowDown:
+			h.move(h.bytesPerLine())
+		case pageUp:
+			h.move(-h.bytesPerLine() * e.screenRows)
+		case pageDown:
+			h.move(h.bytesPerLine() * e.screenRows)
+		case home, ctrl('a'):
+			h.move(-(h.cursor % h.bytesPerLine()))
+		case end, ctrl('e'):
+			h.move(h.bytesPerLine() - 1 - h.cursor%h.bytesPerLine())
+		case fileStart:
+			h.move(-h.cursor)
+		case fileEnd:
+			h.move(len(h.data))
+		case '\t':
+			h.ascii = !h.ascii
+			h.lowNibble = false
+		case ctrl('s'):
+			if err := os.WriteFile(h.path, h.data, 0o644); err != nil {
+				editorSetStatusMessage("Can't save! I/O error: %s", err.Error())
+				break
+			}
+			h.dirty = false
+			saved = true
+			editorSetStatusMessage("%d bytes written to disk", len(h.data))
+		case '\x1b', ctrl('q'):
+			if h.dirty && !editorConfirm("Discard unsaved changes? (y/n)") {
+				break
+			}
+			editorSetStatusMessage("")
+			return saved, nil
+		default:
+			h.overwrite(key)
+		}
+	}
+}
+
+// bytesPerLine returns the number of bytes shown on each line, which is the
+// most that fit on the screen, in groups of 4.
+func (h *hexEditor) bytesPerLine() int {
+	// Each byte takes up 3 columns in hex and 1 as text, after the offset and
+	// the spaces around the hex.
+	n := (e.screenCols - hexOffsetWidth - 2) / 4
+	return max(n-n%4, 4)
+}
+
+// hexOffsetWidth is the number of columns used to display the offset of each
+// line, including the space after it.
+const hexOffsetWidth = 9
+
+// move moves the cursor by delta bytes, keeping it inside of the data, and
+// scrolls to it.
+func (h *hexEditor) move(delta int) {
+	h.cursor = max(0, min(h.cursor+delta, len(h.data)-1))
+	h.lowNibble = false
+
+	line := h.cursor / h.bytesPerLine()
+	if line < h.offset {
+		h.offset = line
+	}
+	if line >= h.offset+e.screenRows {
+		h.offset = line - e.screenRows + 1
+	}
+}
+
+// overwrite replaces the byte at the cursor with the key which was typed,
+// either as a hex digit or a character.
+func (h *hexEditor) overwrite(key rune) {
+	if len(h.data) == 0 {
+		return
+	}
+
+	if h.ascii {
+		if key < ' ' || key > '~' {
+			return
+		}
+		h.data[h.cursor] = byte(key)
+		h.dirty = true
+		h.move(1)
+		return
+	}
+
+	digit := strings.IndexRune("0123456789abcdef", key)
+	if digit < 0 {
+		digit = strings.IndexRune("0123456789ABCDEF", key)
+	}
+	if digit < 0 {
+		return
+	}
+
+	b := h.data[h.cursor]
+	if h.lowNibble {
+		h.data[h.cursor] = b&0xf0 | byte(digit)
+		h.dirty = true
+		h.move(1)
+		return
+	}
+
+	h.data[h.cursor] = byte(digit)<<4 | b&0x0f
+	h.dirty = true
+	h.lowNibble = true
+}
+
+// editorDrawHexEditor draws h in the area of the screen which normally
+// contains the file. Each line shows the offset of its first byte, the bytes
+// in hex, and the bytes as text, with unprintable ones shown as dots.
+func editorDrawHexEditor(w io.Writer, h *hexEditor) {
+	n := h.bytesPerLine()
+	for y := range e.screenRows {
+		start := (h.offset + y) * n
+		if start < len(h.data) || (start == 0 && y == 0) {
+			line := h.data[start:min(start+n, len(h.data))]
+
+			fmt.Fprintf(w, "%08x ", start)
+			for i := range n {
+				if i < len(line) {
+					fmt.Fprintf(w, " %02x", line[i])
+				} else {
+					fmt.Fprint(w, "   ")
+				}
+			}
+
+			fmt.Fprint(w, "  ")
+			for _, b := range line {
+				if b < ' ' || b > '~' {
+					b = '.'
+				}
+				fmt.Fprintf(w, "%c", b)
+			}
+		}
+
+		fmt.Fprint(w, "\x1b[K")
+		fmt.Fprint(w, "\r\n")
+	}
+}
+
+// screenPos returns the row and column of the screen, starting at 1, which
+// the cursor is displayed at.
+func (h *hexEditor) screenPos() (row, col int) {
+	n := h.bytesPerLine()
+	row = h.cursor/n - h.offset + 1
+	i := h.cursor % n
+
+	if h.ascii {
+		return row, hexOffsetWidth + 3*n + 2 + i + 1
+	}
+
+	col = hexOffsetWidth + 3*i + 2
+	if h.lowNibble {
+		col++
+	}
+	return row, col
+}
@@ -11,19 +11,64 @@ type editorSyntax struct {
 	fileType string
 	// matchers contains patterns to match against the file name.
 	matchers []string
-	keywords []string
+	// interpreters contains the names of programs which, when given in the #!
+	// line at the start of a file, indicate that it's of this type.
+	interpreters []string
+	keywords     []string
 	// singleLineCommentStart contains the character(s) that a single-line
 	// comment starts with.
 	singleLineCommentStart string
 	multilineCommentStart  string
 	multilineCommentEnd    string
+	// multilineStringQuotes contains the characters which start strings that
+	// may span multiple lines, like template literals in JavaScript. These are
+	// in addition to the single and double quotes which always start strings.
+	multilineStringQuotes string
 
 	flags int
+
+	// indent contains the rules for adjusting the indentation of rows while
+	// typing.
+	indent indentRules
+
+	// formatter is the shell command used to format files of this type when
+	// the formatter option isn't set. It reads the file from stdin and writes
+	// the formatted version to stdout.
+	formatter string
+	// linter is the shell command used to check files of this type when the
+	// linter option isn't set. See editorRunLinter.
+	linter string
+	// buildCommand is the shell command run by the compile command for files
+	// of this type when the buildcommand option isn't set.
+	buildCommand string
+	// languageServer is the shell command which starts the language server for
+	// files of this type when the languageserver option isn't set.
+	languageServer string
+	// columnDelimiter is the character which separates the cells of each row
+	// in files of tabular data, whose columns are aligned when displayed.
+	columnDelimiter byte
+
+	// highlightRow, when set, highlights a row instead of the rules used for
+	// most programming languages. It's for file types with different structure,
+	// like Markdown.
+	highlightRow func(row *editorRow)
 }
 
 const (
 	enableNumberHighlight = 1 << iota
 	enableStringHighlight
+	// enableRegexHighlight highlights JavaScript style regular expression
+	// literals (e.g. /a+b/g) as strings.
+	enableRegexHighlight
+	// enableKeyHighlight highlights strings which are followed by a colon, like
+	// the keys of a JSON object, differently from other strings.
+	enableKeyHighlight
+	// requireHardTabs indicates that tabs are significant in the file type (e.g.
+	// recipes in Makefiles), so they must never be replaced with spaces.
+	requireHardTabs
+	// proseFile indicates that the file is mostly text, so all of it is spell
+	// checked rather than only comments and strings.
+	proseFile
 )
 
 var highlightDB = []editorSyntax{
@@ -49,7 +94,149 @@ var highlightDB = []editorSyntax{
 		multilineCommentStart:  "/*",
 		multilineCommentEnd:    "*/",
 		flags:                  enableNumberHighlight | enableStringHighlight,
+		indent: indentRules{
+			increaseAfter: []string{"{", "(", "["},
+			decreaseOn:    "})]",
+		},
+		formatter:      "gofmt",
+		linter:         "go vet",
+		buildCommand:   "go build ./...",
+		languageServer: "gopls",
+	},
+	{
+		fileType:     "javascript",
+		matchers:     []string{".js", ".jsx"},
+		interpreters: []string{"node", "nodejs"},
+		keywords: append(slices.Clone(javaScriptKeywords),
+			"true|", "false|", "null|", "undefined|", "NaN|", "Infinity|",
+		),
+		singleLineCommentStart: "//",
+		multilineCommentStart:  "/*",
+		multilineCommentEnd:    "*/",
+		multilineStringQuotes:  "`",
+		flags:                  enableNumberHighlight | enableStringHighlight | enableRegexHighlight,
+	},
+	{
+		fileType:     "typescript",
+		matchers:     []string{".ts", ".tsx"},
+		interpreters: []string{"ts-node", "deno"},
+		keywords: append(slices.Clone(javaScriptKeywords),
+			"interface", "type", "enum", "implements", "namespace", "declare",
+			"abstract", "readonly", "private", "protected", "public", "as", "keyof",
+
+			"true|", "false|", "null|", "undefined|", "NaN|", "Infinity|",
+			"string|", "number|", "boolean|", "bigint|", "symbol|", "object|",
+			"any|", "unknown|", "never|",
+		),
+		singleLineCommentStart: "//",
+		multilineCommentStart:  "/*",
+		multilineCommentEnd:    "*/",
+		multilineStringQuotes:  "`",
+		flags:                  enableNumberHighlight | enableStringHighlight | enableRegexHighlight,
+	},
+	{
+		fileType:     "python",
+		matchers:     []string{".py"},
+		interpreters: []string{"python", "pypy"},
+		keywords: []string{
+			"and", "as", "assert", "async", "await", "break", "class", "continue",
+			"def", "del", "elif", "else", "except", "finally", "for", "from",
+			"global", "if", "import", "in", "is", "lambda", "nonlocal", "not", "or",
+			"pass", "raise", "return", "try", "while", "with", "yield",
+
+			"True|", "False|", "None|", "self|",
+			"int|", "float|", "str|", "bytes|", "bool|", "list|", "dict|", "set|",
+			"tuple|", "object|",
+		},
+		singleLineCommentStart: "#",
+		flags:                  enableNumberHighlight | enableStringHighlight,
+	},
+	{
+		fileType: "json",
+		matchers: []string{".json"},
+		keywords: []string{"true|", "false|", "null|"},
+		flags:    enableNumberHighlight | enableStringHighlight | enableKeyHighlight,
+	},
+	{
+		fileType:     "markdown",
+		matchers:     []string{".md", ".markdown"},
+		flags:        proseFile,
+		highlightRow: highlightMarkdown,
+	},
+	{
+		fileType:     "yaml",
+		matchers:     []string{".yaml", ".yml"},
+		highlightRow: highlightYAML,
+	},
+	{
+		fileType:     "sh",
+		matchers:     []string{".sh", ".bash", ".bashrc", ".bash_profile", ".profile"},
+		interpreters: []string{"sh", "bash", "dash", "ksh", "zsh"},
+		keywords: []string{
+			"if", "then", "else", "elif", "fi", "case", "esac", "for", "while", "until",
+			"do", "done", "in", "function", "select", "return", "exit", "break",
+			"continue", "local", "export", "readonly", "declare", "unset", "shift",
+
+			"echo|", "printf|", "read|", "cd|", "test|", "source|", "eval|", "exec|",
+			"trap|", "set|", "true|", "false|",
+		},
+		highlightRow: highlightShell,
+		linter:       "shellcheck -f gcc %f",
+	},
+	{
+		fileType:              "html",
+		matchers:              []string{".html", ".htm"},
+		multilineCommentStart: "<!--",
+		multilineCommentEnd:   "-->",
+		highlightRow:          highlightHTML,
+	},
+	{
+		fileType:              "css",
+		matchers:              []string{".css"},
+		multilineCommentStart: "/*",
+		multilineCommentEnd:   "*/",
+		highlightRow:          highlightCSS,
+	},
+	{
+		fileType: "make",
+		matchers: []string{"Makefile", "makefile", "GNUmakefile", ".mk"},
+		keywords: []string{
+			"include", "-include", "sinclude", "ifeq", "ifneq", "ifdef", "ifndef",
+			"else", "endif", "define", "endef", "export", "unexport", "override",
+			"vpath",
+		},
+		flags:        requireHardTabs,
+		highlightRow: highlightMakefile,
+	},
+	{
+		fileType:        "csv",
+		matchers:        []string{".csv"},
+		flags:           enableNumberHighlight | enableStringHighlight,
+		columnDelimiter: ',',
 	},
+	{
+		fileType:        "tsv",
+		matchers:        []string{".tsv"},
+		flags:           enableNumberHighlight | requireHardTabs,
+		columnDelimiter: '\t',
+	},
+}
+
+// javaScriptKeywords contains the keywords shared by JavaScript and
+// TypeScript.
+var javaScriptKeywords = []string{
+	"break", "case", "catch", "class", "const", "continue", "debugger", "default",
+	"delete", "do", "else", "export", "extends", "finally", "for", "function",
+	"if", "import", "in", "instanceof", "let", "new", "of", "return", "super",
+	"switch", "this", "throw", "try", "typeof", "var", "void", "while", "with",
+	"yield", "async", "await", "static",
+}
+
+// regexPrecedingKeywords contains the keywords after which a / starts a regular
+// expression literal rather than being a division operator.
+var regexPrecedingKeywords = []string{
+	"return", "typeof", "instanceof", "in", "of", "new", "delete", "void",
+	"throw", "case", "do", "else", "yield", "await",
 }
 
 const (
@@ -61,39 +248,128 @@ const (
 	highlightString
 	highlightNumber
 	highlightMatch
+	highlightKey
+	highlightEmphasis
+	highlightLink
+	highlightVariable
+	highlightBracket1
+	highlightBracket2
+	highlightBracket3
 )
 
 type editorHighlight int
 
-var searchHighlightLine int
-var beforeSearchHighlights []editorHighlight
+// beforeSearchHighlights contains the highlights of the rows which contain
+// the current search result, keyed by their index, so that they can be
+// restored once it's no longer highlighted.
+var beforeSearchHighlights = make(map[int][]editorHighlight)
+
+func init() {
+	bufferOnChange(syntaxOnChange)
+}
 
 func editorSelectSyntaxHighlight() {
-	e.syntax = nil
-	if e.filename == "" {
+	e.syntax = syntaxForFilename(e.filename)
+	if e.syntax == nil && len(e.row) > 0 {
+		e.syntax = syntaxForInterpreter(shebangInterpreter(e.row[0].raw))
+	}
+
+	editorApplyFileTypeConfig()
+
+	for i := range e.row {
+		editorUpdateSyntax(&e.row[i])
+	}
+}
+
+// syntaxOnChange selects the syntax highlighting again when the first row
+// changes, since it may contain a #! line which indicates the file type.
+func syntaxOnChange(change bufferChange) {
+	if change.start.line != 0 || len(e.row) == 0 || syntaxForFilename(e.filename) != nil {
 		return
 	}
 
-	ext := filepath.Ext(e.filename)
+	detected := syntaxForInterpreter(shebangInterpreter(e.row[0].raw))
+	if detected == nil && e.syntax == nil {
+		return
+	}
+	if detected != nil && e.syntax != nil && detected.fileType == e.syntax.fileType {
+		return
+	}
+
+	editorSelectSyntaxHighlight()
+}
+
+// syntaxForFilename returns the syntax whose matchers match filename, or nil if
+// there isn't one.
+func syntaxForFilename(filename string) *editorSyntax {
+	if filename == "" {
+		return nil
+	}
+
+	ext := filepath.Ext(filename)
+	base := filepath.Base(filename)
 
-outer:
 	for _, syntax := range highlightDB {
 		for _, matcher := range syntax.matchers {
 			if matcher[0] == '.' {
 				if matcher == ext {
-					e.syntax = &syntax
-					break outer
+					return &syntax
 				}
-			} else if strings.Contains(e.filename, matcher) {
-				e.syntax = &syntax
-				break outer
+			} else if strings.Contains(base, matcher) {
+				return &syntax
 			}
 		}
 	}
 
-	for _, row := range e.row {
-		editorUpdateSyntax(&row)
+	return nil
+}
+
+// syntaxForInterpreter returns the syntax for scripts run by interpreter, or nil
+// if there isn't one.
+func syntaxForInterpreter(interpreter string) *editorSyntax {
+	if interpreter == "" {
+		return nil
+	}
+
+	for _, syntax := range highlightDB {
+		if slices.Contains(syntax.interpreters, interpreter) {
+			return &syntax
+		}
+	}
+
+	return nil
+}
+
+// shebangInterpreter returns the name of the program given in line if it's a
+// #! line (e.g. bash for #!/usr/bin/env bash), or an empty string otherwise.
+// Version numbers are removed from the name, so #!/usr/bin/python3.12 gives
+// python.
+func shebangInterpreter(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
 	}
+
+	program := filepath.Base(fields[0])
+	if program == "env" {
+		// Skip over options (e.g. -S) and environment variable assignments to
+		// find the program which env runs.
+		args := fields[1:]
+		for len(args) > 0 && (strings.HasPrefix(args[0], "-") || strings.Contains(args[0], "=")) {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			return ""
+		}
+
+		program = filepath.Base(args[0])
+	}
+
+	return strings.TrimRight(program, "0123456789.")
 }
 
 func editorUpdateSyntax(row *editorRow) {
@@ -101,12 +377,60 @@ func editorUpdateSyntax(row *editorRow) {
 	row.highlight = row.highlight[:len(row.render)]
 
 	if e.syntax == nil {
+		fillHighlight(row, 0, len(row.highlight), highlightNormal)
+		spellCheckRow(row)
+		return
+	}
+
+	// Remember the state which carries over to the next row to determine
+	// whether the next row needs to be highlighted again.
+	hasOpenComment := row.hasOpenComment
+	openString := row.openString
+	inCodeFence := row.inCodeFence
+	syntaxState := row.syntaxState
+	bracketDepth := row.bracketDepth
+
+	if e.syntax.highlightRow != nil {
+		e.syntax.highlightRow(row)
+	} else {
+		highlightCode(row)
+	}
+
+	if e.rainbowBrackets {
+		highlightRainbowBrackets(row)
+	}
+
+	spellCheckRow(row)
+
+	changed := hasOpenComment != row.hasOpenComment ||
+		openString != row.openString ||
+		inCodeFence != row.inCodeFence ||
+		syntaxState != row.syntaxState ||
+		bracketDepth != row.bracketDepth
+	if changed && row.idx+1 < len(e.row) {
+		editorUpdateSyntax(&e.row[row.idx+1])
+	}
+}
+
+// highlightCode highlights row using rules which work for most programming
+// languages, configured by the fields of e.syntax.
+func highlightCode(row *editorRow) {
+	if row.idx == 0 && shebangInterpreter(row.raw) != "" {
+		fillHighlight(row, 0, len(row.render), highlightComment)
+		row.hasOpenComment = false
+		row.openString = 0
 		return
 	}
 
 	isPrevSep := true
 	var stringStart rune = 0
-	isInComment := row.idx > 0 && e.row[row.idx-1].hasOpenComment
+	// stringStartIdx is the index of the opening quote of the current string.
+	stringStartIdx := 0
+	isInComment := false
+	if row.idx > 0 {
+		isInComment = e.row[row.idx-1].hasOpenComment
+		stringStart = e.row[row.idx-1].openString
+	}
 
 	i := 0
 outer:
@@ -118,6 +442,17 @@ outer:
 			prevHl = row.highlight[i-1]
 		}
 
+		if e.syntax.flags&enableRegexHighlight != 0 && stringStart == 0 && !isInComment {
+			if end := regexLiteralEnd(row.render, i); end > i {
+				for j := i; j < end; j++ {
+					row.highlight[j] = highlightString
+				}
+				i = end
+				isPrevSep = false
+				continue
+			}
+		}
+
 		lineCommentStart := e.syntax.singleLineCommentStart
 		if len(lineCommentStart) > 0 && stringStart == 0 && !isInComment {
 			if strings.HasPrefix(row.render[i:], lineCommentStart) {
@@ -165,13 +500,20 @@ outer:
 				}
 				if ch == stringStart { // this is the closing quote
 					stringStart = 0
+
+					if e.syntax.flags&enableKeyHighlight != 0 && isFollowedByColon(row.render[i+1:]) {
+						for j := stringStartIdx; j <= i; j++ {
+							row.highlight[j] = highlightKey
+						}
+					}
 				}
 				i++
 				isPrevSep = true
 				continue
 			} else {
-				if ch == '"' || ch == '\'' {
+				if ch == '"' || ch == '\'' || strings.ContainsRune(e.syntax.multilineStringQuotes, ch) {
 					stringStart = ch
+					stringStartIdx = i
 					row.highlight[i] = highlightString
 					i++
 					continue
@@ -229,47 +571,113 @@ outer:
 		i++
 	}
 
-	changed := isInComment != row.hasOpenComment
-	row.hasOpenComment = isInComment
-	if changed && row.idx+1 < len(e.row) {
-		editorUpdateSyntax(&e.row[row.idx+1])
+	// Only strings which are allowed to span multiple lines continue onto the
+	// next row.
+	if !strings.ContainsRune(e.syntax.multilineStringQuotes, stringStart) {
+		stringStart = 0
 	}
+
+	row.hasOpenComment = isInComment
+	row.openString = stringStart
 }
 
-func editorSyntaxToColour(hl editorHighlight) int {
-	switch hl {
-	case highlightComment, highlightMultiComment:
-		return 36 // cyan
-	case highlightKeyword1:
-		return 33 // yellow
-	case highlightKeyword2:
-		return 32 // green
-	case highlightString:
-		return 35 // magenta
-	case highlightNumber:
-		return 31 // red
-	case highlightMatch:
-		return 34 // blue
-	default:
-		return 37 // white
-	}
+func editorSyntaxToStyle(hl editorHighlight) style {
+	return editorCurrentTheme().highlights[hl]
 }
 
 func isSeparator(ch rune) bool {
 	return ch == ' ' || ch == 0 || strings.Contains(",.()+-/*=~%<>[];", string(ch))
 }
 
-func highlightSearchResult(row editorRow, query string, offset int) {
-	searchHighlightLine = row.idx
-	beforeSearchHighlights = slices.Clone(row.highlight)
-	for i := range len(query) {
-		row.highlight[i+offset] = highlightMatch
+// regexLiteralEnd returns the index after the end of the regular expression
+// literal starting at index i of s, including any flags. i is returned when
+// there isn't a regular expression literal at i, such as when the / is a
+// division operator or starts a comment.
+func regexLiteralEnd(s string, i int) int {
+	if s[i] != '/' || i+1 >= len(s) || s[i+1] == '/' || s[i+1] == '*' {
+		return i
+	}
+
+	// Determine whether a regular expression can appear here based on the
+	// preceding token.
+	prev := strings.TrimRight(s[:i], " \t")
+	if prev != "" {
+		last := prev[len(prev)-1]
+		if isIdentifierByte(last) {
+			start := len(prev)
+			for start > 0 && isIdentifierByte(prev[start-1]) {
+				start--
+			}
+			if !slices.Contains(regexPrecedingKeywords, prev[start:]) {
+				return i
+			}
+		} else if !strings.ContainsRune("(,=:[!&|?{};+-*%<>~^", rune(last)) {
+			return i
+		}
+	}
+
+	inClass := false
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if inClass {
+				continue
+			}
+
+			// Include the flags, e.g. the g in /a/g
+			j++
+			for j < len(s) && isIdentifierByte(s[j]) {
+				j++
+			}
+			return j
+		}
+	}
+
+	// The literal isn't terminated on this line, so this likely isn't a
+	// regular expression.
+	return i
+}
+
+// isFollowedByColon returns whether the first non-space character of s is a
+// colon.
+func isFollowedByColon(s string) bool {
+	return strings.HasPrefix(strings.TrimLeft(s, " "), ":")
+}
+
+func isIdentifierByte(b byte) bool {
+	return b == '_' || b == '$' ||
+		(b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z') ||
+		(b >= '0' && b <= '9')
+}
+
+// highlightSearchResult highlights the text from start to end, which can span
+// multiple rows.
+func highlightSearchResult(rows []editorRow, start, end bufferPos) {
+	for line := start.line; line <= end.line && line < len(rows); line++ {
+		row := &rows[line]
+		beforeSearchHighlights[line] = slices.Clone(row.highlight)
+
+		from, to := 0, len(row.render)
+		if line == start.line {
+			from = editorRowCxToRx(*row, start.col)
+		}
+		if line == end.line {
+			to = editorRowCxToRx(*row, end.col)
+		}
+		fillHighlight(row, from, to, highlightMatch)
 	}
 }
 
 func clearSearchHighlight(rows []editorRow) {
-	if len(beforeSearchHighlights) > 0 {
-		copy(rows[searchHighlightLine].highlight, beforeSearchHighlights)
-		beforeSearchHighlights = nil
+	for line, highlights := range beforeSearchHighlights {
+		copy(rows[line].highlight, highlights)
 	}
+	clear(beforeSearchHighlights)
 }
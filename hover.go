@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// editorHover asks the language server for the documentation of the symbol
+// under the cursor, and shows it in place of the file.
+func editorHover() {
+	if !editorLSPReady() {
+		return
+	}
+
+	result, err := lsp.request("textDocument/hover", editorLSPTextDocumentPosition())
+	if err != nil {
+		editorSetStatusMessage("Hover: %s", err.Error())
+		return
+	}
+
+	text := parseHoverResult(result)
+	if strings.TrimSpace(text) == "" {
+		editorSetStatusMessage("No information")
+		return
+	}
+
+	var lines []string
+	for line := range strings.Lines(text) {
+		line = strings.TrimRight(line, "\n")
+
+		// Markdown code fences only get in the way when displaying plain text.
+		if strings.HasPrefix(line, "```") {
+			continue
+		}
+
+		lines = append(lines, wrapLine(expandTabs(line), e.screenCols)...)
+	}
+
+	editorShowOverlay("Hover", lines)
+}
+
+// parseHoverResult returns the text of the response to a hover request, which
+// is either markup content, or one or more strings which may be marked with a
+// language.
+func parseHoverResult(result json.RawMessage) string {
+	var hover struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := json.Unmarshal(result, &hover); err != nil || hover.Contents == nil {
+		return ""
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(hover.Contents, &list); err != nil {
+		list = []json.RawMessage{hover.Contents}
+	}
+
+	var parts []string
+	for _, raw := range list {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			parts = append(parts, s)
+			continue
+		}
+
+		var content struct {
+			Value string `json:"value"`
+		}
+		json.Unmarshal(raw, &content)
+		parts = append(parts, content.Value)
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// wrapLine splits line into lines which are at most width characters long,
+// breaking at spaces where possible.
+func wrapLine(line string, width int) []string {
+	if width <= 0 || utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	var lines []string
+	for utf8.RuneCountInString(line) > width {
+		runes := []rune(line)
+
+		cut := width
+		for i := width; i > 0; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		line = strings.TrimLeft(string(runes[cut:]), " ")
+	}
+
+	return append(lines, line)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"strings"
+)
+
+// The values of syntaxState for HTML.
+const (
+	htmlStateText = iota
+	// htmlStateTag is used when the row ends inside of a tag, e.g. when its
+	// attributes are split across multiple lines.
+	htmlStateTag
+)
+
+// highlightHTML highlights tags, attributes, entity references and comments
+// in a row of an HTML file.
+func highlightHTML(row *editorRow) {
+	fillHighlight(row, 0, len(row.render), highlightNormal)
+
+	s := row.render
+
+	isInComment := false
+	state := htmlStateText
+	if row.idx > 0 {
+		isInComment = e.row[row.idx-1].hasOpenComment
+		state = e.row[row.idx-1].syntaxState
+	}
+
+	i := 0
+	for i < len(s) {
+		if isInComment {
+			end := strings.Index(s[i:], "-->")
+			if end < 0 {
+				fillHighlight(row, i, len(s), highlightMultiComment)
+				break
+			}
+			end += i + len("-->")
+			fillHighlight(row, i, end, highlightMultiComment)
+			isInComment = false
+			i = end
+			continue
+		}
+
+		c := s[i]
+
+		if state == htmlStateText {
+			switch {
+			case strings.HasPrefix(s[i:], "<!--"):
+				isInComment = true
+			case c == '<' && i+1 < len(s) && (isIdentifierByte(s[i+1]) || s[i+1] == '/' || s[i+1] == '!'):
+				end := i + 2
+				for end < len(s) && (isIdentifierByte(s[end]) || s[end] == '-') {
+					end++
+				}
+				fillHighlight(row, i, end, highlightKeyword1)
+				state = htmlStateTag
+				i = end
+			case c == '&' && htmlEntityEnd(s, i) > i:
+				end := htmlEntityEnd(s, i)
+				fillHighlight(row, i, end, highlightVariable)
+				i = end
+			default:
+				i++
+			}
+			continue
+		}
+
+		switch {
+		case c == '>':
+			row.highlight[i] = highlightKeyword1
+			state = htmlStateText
+			i++
+		case strings.HasPrefix(s[i:], "/>"):
+			fillHighlight(row, i, i+2, highlightKeyword1)
+			state = htmlStateText
+			i += 2
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 2
+			}
+			fillHighlight(row, i, end, highlightString)
+			i = end
+		case isIdentifierByte(c):
+			end := i
+			for end < len(s) && (isIdentifierByte(s[end]) || s[end] == '-' || s[end] == ':') {
+				end++
+			}
+			fillHighlight(row, i, end, highlightKey)
+			i = end
+		default:
+			i++
+		}
+	}
+
+	row.hasOpenComment = isInComment
+	row.syntaxState = state
+}
+
+// htmlEntityEnd returns the index after the end of the entity reference (e.g.
+// &amp; or &#39;) starting at index i of s, or i if there isn't a valid one.
+func htmlEntityEnd(s string, i int) int {
+	for j := i + 1; j < len(s) && j-i <= 32; j++ {
+		if s[j] == ';' {
+			if j == i+1 {
+				break
+			}
+			return j + 1
+		}
+		if !isIdentifierByte(s[j]) && s[j] != '#' {
+			break
+		}
+	}
+
+	return i
+}
+
+// highlightCSS highlights selectors, properties, values and comments in a row
+// of a CSS file. syntaxState contains the nesting depth of braces at the end
+// of the row.
+func highlightCSS(row *editorRow) {
+	fillHighlight(row, 0, len(row.render), highlightNormal)
+
+	s := row.render
+
+	isInComment := false
+	depth := 0
+	if row.idx > 0 {
+		isInComment = e.row[row.idx-1].hasOpenComment
+		depth = e.row[row.idx-1].syntaxState
+	}
+
+	inValue := false
+
+	i := 0
+	for i < len(s) {
+		if isInComment {
+			end := strings.Index(s[i:], "*/")
+			if end < 0 {
+				fillHighlight(row, i, len(s), highlightMultiComment)
+				break
+			}
+			end += i + len("*/")
+			fillHighlight(row, i, end, highlightMultiComment)
+			isInComment = false
+			i = end
+			continue
+		}
+
+		c := s[i]
+		switch {
+		case strings.HasPrefix(s[i:], "/*"):
+			isInComment = true
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 2
+			}
+			fillHighlight(row, i, end, highlightString)
+			i = end
+		case c == '{':
+			depth++
+			inValue = false
+			i++
+		case c == '}':
+			depth = max(depth-1, 0)
+			inValue = false
+			i++
+		case c == ';':
+			inValue = false
+			i++
+		case c == '@':
+			end := cssWordEnd(s, i+1)
+			fillHighlight(row, i, end, highlightKeyword2)
+			i = end
+		case inValue:
+			i = highlightCSSValue(row, i)
+		case depth > 0 && cssIsDeclaration(s[i:]):
+			colon := i + strings.IndexByte(s[i:], ':')
+			fillHighlight(row, i, colon, highlightKey)
+			inValue = true
+			i = colon + 1
+		case c == ':':
+			// Pseudo-classes and pseudo-elements, like :hover or ::before
+			end := i + 1
+			if end < len(s) && s[end] == ':' {
+				end++
+			}
+			end = cssWordEnd(s, end)
+			fillHighlight(row, i, end, highlightKeyword2)
+			i = end
+		case c == '.' || c == '#' || c == '-' || isIdentifierByte(c):
+			end := cssWordEnd(s, i+1)
+			fillHighlight(row, i, end, highlightKeyword1)
+			i = end
+		default:
+			i++
+		}
+	}
+
+	row.hasOpenComment = isInComment
+	row.syntaxState = depth
+}
+
+// highlightCSSValue highlights the part of a property value starting at index
+// i of row, and returns the index after it.
+func highlightCSSValue(row *editorRow, i int) int {
+	s := row.render
+	c := s[i]
+
+	switch {
+	case c == '#' || (c >= '0' && c <= '9') || (c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+		// Numbers with units (e.g. 1.5em or 100%) and colours (e.g. #fff)
+		end := i + 1
+		for end < len(s) && (isIdentifierByte(s[end]) || s[end] == '.' || s[end] == '%') {
+			end++
+		}
+		fillHighlight(row, i, end, highlightNumber)
+		return end
+	case c == '!':
+		end := cssWordEnd(s, i+1)
+		fillHighlight(row, i, end, highlightKeyword2)
+		return end
+	case c == '-' || isIdentifierByte(c):
+		// Skip over keywords so that digits within them aren't highlighted as
+		// numbers.
+		return cssWordEnd(s, i+1)
+	}
+
+	return i
+}
+
+// cssIsDeclaration returns whether s starts with a declaration, like
+// "color: red", rather than a nested selector, like "a:hover {".
+func cssIsDeclaration(s string) bool {
+	end := strings.IndexAny(s, ";{}")
+	if end >= 0 && s[end] == '{' {
+		return false
+	}
+	if end < 0 {
+		end = len(s)
+	}
+
+	colon := strings.IndexByte(s[:end], ':')
+	return colon > 0 && cssWordEnd(s, 0) == colon
+}
+
+// cssWordEnd returns the index of the first character at or after index i of s
+// which can't be part of an identifier.
+func cssWordEnd(s string, i int) int {
+	for i < len(s) && (isIdentifierByte(s[i]) || s[i] == '-') {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// indentRules describe how the indentation of rows is adjusted while typing
+// for a file type.
+type indentRules struct {
+	// increaseAfter contains the endings of rows which cause the row after them
+	// to be indented one level further, e.g. "{".
+	increaseAfter []string
+	// decreaseOn contains the characters which reduce the indentation of a row
+	// by one level when typed at the start of it, e.g. "}".
+	decreaseOn string
+}
+
+// editorIndentWidth returns the number of columns that each level of
+// indentation takes up.
+func editorIndentWidth() int {
+	if e.indentWidth > 0 {
+		return e.indentWidth
+	}
+
+	return e.tabStop
+}
+
+// editorIndentUnit returns the text which is inserted for one level of
+// indentation.
+func editorIndentUnit() string {
+	if editorUseSpaces() {
+		return strings.Repeat(" ", editorIndentWidth())
+	}
+
+	return "\t"
+}
+
+// editorUseSpaces returns whether spaces should be inserted instead of tabs.
+// File types where tabs are significant always use tabs.
+func editorUseSpaces() bool {
+	return e.expandTab && (e.syntax == nil || e.syntax.flags&requireHardTabs == 0)
+}
+
+// editorInsertTab inserts a tab at the cursor, or spaces up to the next tab
+// stop when expandtab is on.
+func editorInsertTab() {
+	if !editorUseSpaces() {
+		editorInsertChar('\t')
+		return
+	}
+
+	rx := 0
+	if e.cy < len(e.row) {
+		rx = editorRowCxToRx(e.row[e.cy], e.cx)
+	}
+
+	spaces := strings.Repeat(" ", e.tabStop-rx%e.tabStop)
+	bufferReplaceRange(bufferPos{e.cy, e.cx}, bufferPos{e.cy, e.cx}, spaces)
+	e.cx += len(spaces)
+}
+
+// editorDetectIndent guesses whether the file is indented with tabs or spaces,
+// and how many spaces make up a level of indentation, and sets the options so
+// that new rows match. Nothing changes when the file has no indentation.
+func editorDetectIndent() {
+	if !e.detectIndent {
+		return
+	}
+
+	tabRows := 0
+	spaceRows := 0
+	// increases counts how often the indentation of a row is more than the
+	// previous one by each number of spaces.
+	increases := make(map[int]int)
+	prevIndent := 0
+	for _, row := range e.row {
+		if strings.TrimSpace(row.raw) == "" {
+			continue
+		}
+
+		ws := leadingWhitespace(row.raw)
+		if strings.HasPrefix(ws, "\t") {
+			tabRows++
+			continue
+		}
+		if strings.Contains(ws, "\t") {
+			continue
+		}
+
+		if ws != "" {
+			spaceRows++
+		}
+
+		// Increases of one space are usually alignment, like the * at the start
+		// of rows in block comments, rather than indentation.
+		if delta := len(ws) - prevIndent; delta > 1 {
+			increases[delta]++
+		}
+		prevIndent = len(ws)
+	}
+
+	if tabRows == 0 && spaceRows == 0 {
+		return
+	}
+
+	if tabRows >= spaceRows {
+		e.expandTab = false
+		e.indentWidth = 0
+		return
+	}
+
+	width, count := 0, 0
+	for delta, n := range increases {
+		if n > count || (n == count && delta < width) {
+			width, count = delta, n
+		}
+	}
+	if width == 0 {
+		return
+	}
+
+	e.expandTab = true
+	e.indentWidth = width
+}
+
+func tabStopOption() editorOption {
+	return editorOption{
+		name: "tabstop",
+		set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 32 {
+				return fmt.Errorf("expected a number from 1 to 32, given %q", value)
+			}
+
+			e.tabStop = n
+
+			// Tabs are replaced with spaces when rendering rows, so they need to be
+			// rendered again.
+			for i := range e.row {
+				editorUpdateRow(&e.row[i])
+			}
+
+			return nil
+		},
+		get: func() string {
+			return strconv.Itoa(e.tabStop)
+		},
+	}
+}
+
+func indentWidthOption() editorOption {
+	return editorOption{
+		name: "indentwidth",
+		set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 32 {
+				return fmt.Errorf("expected a number from 0 to 32, given %q", value)
+			}
+
+			e.indentWidth = n
+			return nil
+		},
+		get: func() string {
+			return strconv.Itoa(editorIndentWidth())
+		},
+	}
+}
+
+// leadingWhitespace returns the spaces and tabs at the start of s.
+func leadingWhitespace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// editorNewlineIndent returns the indentation for a row inserted by splitting
+// the row at the cursor. It's the same as the current row, plus one level if
+// the text before the cursor ends with something that opens a block. increased
+// indicates whether the extra level was added.
+func editorNewlineIndent() (indent string, increased bool) {
+	if !e.autoIndent || e.cy >= len(e.row) {
+		return "", false
+	}
+
+	before := e.row[e.cy].raw[:e.cx]
+	indent = leadingWhitespace(before)
+
+	if e.syntax == nil {
+		return indent, false
+	}
+
+	trimmed := strings.TrimRight(before, " \t")
+	for _, opener := range e.syntax.indent.increaseAfter {
+		if strings.HasSuffix(trimmed, opener) {
+			return indent + editorIndentUnit(), true
+		}
+	}
+
+	return indent, false
+}
+
+// editorDedentForChar removes one level of indentation from the current row
+// when c is typed at the start of it and closes a block, e.g. } in Go.
+func editorDedentForChar(c rune) {
+	if !e.autoIndent || e.syntax == nil || e.cy >= len(e.row) {
+		return
+	}
+	if !strings.ContainsRune(e.syntax.indent.decreaseOn, c) {
+		return
+	}
+
+	before := e.row[e.cy].raw[:e.cx]
+	if before == "" || leadingWhitespace(before) != before {
+		return
+	}
+
+	start := len(before) - 1
+	if before[start] == ' ' {
+		// Remove up to a level's worth of spaces.
+		for start > 0 && before[start-1] == ' ' && len(before)-start < editorIndentWidth() {
+			start--
+		}
+	}
+
+	bufferDeleteRange(bufferPos{e.cy, start}, bufferPos{e.cy, e.cx})
+	e.cx = start
+}
+
+// editorIndentSelectedRows indents each selected row, or the row containing the
+// cursor, by one level. Blank rows are left as they are.
+func editorIndentSelectedRows() {
+	first, last, ok := editorSelectedRows()
+	if !ok {
+		return
+	}
+
+	for i := first; i <= last; i++ {
+		if strings.TrimSpace(e.row[i].raw) == "" {
+			continue
+		}
+
+		editorReplaceKeepingCursor(bufferPos{i, 0}, bufferPos{i, 0}, editorIndentUnit())
+	}
+}
+
+// editorDedentSelectedRows removes one level of indentation from each selected
+// row, or the row containing the cursor.
+func editorDedentSelectedRows() {
+	first, last, ok := editorSelectedRows()
+	if !ok {
+		return
+	}
+
+	for i := first; i <= last; i++ {
+		ws := leadingWhitespace(e.row[i].raw)
+
+		n := 0
+		if strings.HasPrefix(ws, "\t") {
+			n = 1
+		} else {
+			for n < len(ws) && n < editorIndentWidth() && ws[n] == ' ' {
+				n++
+			}
+		}
+
+		if n > 0 {
+			editorReplaceKeepingCursor(bufferPos{i, 0}, bufferPos{i, n}, "")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// editorInsertFile inserts the contents of the file at path at the cursor. It
+// prompts for the path when none is given.
+func editorInsertFile(path string) {
+	if path == "" {
+		path = editorPromptCompleting("Insert file: %s", "", func(string, rune) {}, completePath)
+		if path == "" {
+			return
+		}
+	}
+
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		editorSetStatusMessage("Can't insert file: %s", err.Error())
+		return
+	}
+
+	editorDeleteSelection()
+	editorInsertText(strings.ReplaceAll(string(bb), "\r\n", "\n"))
+	editorSetStatusMessage("Inserted %s", path)
+}
+
+// completePath returns the paths of the files which start with path. Those of
+// directories end with a separator, so that they can be completed further.
+func completePath(path string) []string {
+	dir, base := filepath.Split(path)
+
+	listDir := dir
+	if listDir == "" {
+		listDir = "."
+	}
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, dir+name)
+	}
+
+	return matches
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// editorCheckJSON parses the contents of the file as JSON, and moves the
+// cursor to the location of the first syntax error, if there is one.
+func editorCheckJSON(string) {
+	var v any
+	err := json.Unmarshal(editorRowsToString(), &v)
+	if err == nil {
+		editorSetStatusMessage("Valid JSON")
+		return
+	}
+
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		editorSetStatusMessage("Invalid JSON: %s", err.Error())
+		return
+	}
+
+	// Offset is the number of bytes which were successfully read before the
+	// error, so the byte which caused it is the one before.
+	e.cy, e.cx = editorOffsetToPosition(max(int(syntaxErr.Offset)-1, 0))
+
+	editorSetStatusMessage("Invalid JSON at %d:%d: %s", e.cy+1, e.cx+1, syntaxErr.Error())
+}
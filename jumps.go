@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// jumpListSize is the maximum number of positions kept in jumpList.
+const jumpListSize = 100
+
+// jump is a position in a file which can be jumped back to.
+type jump struct {
+	filename string
+	pos      bufferPos
+}
+
+// jumpList contains the positions of the cursor before large jumps, like
+// searching, so that they can be returned to, oldest first.
+var jumpList []jump
+
+// jumpIndex is the index in jumpList of the position which was last jumped
+// back to. It's len(jumpList) when not going through the list.
+var jumpIndex int
+
+func init() {
+	bufferOnChange(jumpListOnChange)
+}
+
+// jumpListOnChange keeps the positions in jumpList on the same text when the
+// buffer changes.
+func jumpListOnChange(change bufferChange) {
+	for i, j := range jumpList {
+		if j.filename == e.filename || sameFile(j.filename, e.filename) {
+			jumpList[i].pos = j.pos.adjust(change)
+		}
+	}
+}
+
+// editorCurrentJump returns the position of the cursor as a jump.
+func editorCurrentJump() jump {
+	return jump{e.filename, bufferPos{e.cy, e.cx}}
+}
+
+// editorRecordJump adds the position of the cursor to the jump list. It's
+// called before moving the cursor somewhere that's possibly far away. Any
+// positions which were jumped back from are discarded.
+func editorRecordJump() {
+	editorPushJump(editorCurrentJump())
+}
+
+// editorPushJump adds j to the jump list, like editorRecordJump. It's used
+// when the cursor has already moved to another file.
+func editorPushJump(j jump) {
+	jumpList = append(jumpList[:min(jumpIndex, len(jumpList))], j)
+	if len(jumpList) > jumpListSize {
+		jumpList = jumpList[1:]
+	}
+
+	jumpIndex = len(jumpList)
+}
+
+// editorJumpBack moves the cursor to the previous position in the jump list.
+func editorJumpBack() {
+	if jumpIndex == 0 {
+		editorSetStatusMessage("Already at the oldest position")
+		return
+	}
+
+	// Remember where the cursor is so that jumping forward returns to it.
+	if jumpIndex == len(jumpList) {
+		jumpList = append(jumpList, editorCurrentJump())
+	}
+
+	if editorGoToJump(jumpList[jumpIndex-1]) {
+		jumpIndex--
+	}
+}
+
+// editorJumpForward moves the cursor to the next position in the jump list,
+// after jumping back.
+func editorJumpForward() {
+	if jumpIndex >= len(jumpList)-1 {
+		editorSetStatusMessage("Already at the newest position")
+		return
+	}
+
+	if editorGoToJump(jumpList[jumpIndex+1]) {
+		jumpIndex++
+	}
+}
+
+// editorGoToJump opens the file of j, and moves the cursor to its position.
+// It returns false when the file couldn't be opened.
+func editorGoToJump(j jump) bool {
+	if j.filename != e.filename && !editorSwitchFile(j.filename) {
+		return false
+	}
+
+	editorGoToPos(j.pos)
+	return true
+}
+
+// editorGoToPos moves the cursor to pos, keeping it inside of the buffer, and
+// scrolls to it when it's off of the screen.
+func editorGoToPos(pos bufferPos) {
+	e.cy = max(min(pos.line, len(e.row)), 0)
+	e.cx = pos.col
+	editorClampCursor()
+	editorScrollTo(e.cy, e.cx, scrollCenterIfHidden)
+}
+
+// editorGoToLine prompts for a line number and moves the cursor to the start of
+// that line.
+func editorGoToLine() {
+	input := editorPrompt("Go to line: %s", func(string, rune) {})
+	if input == "" {
+		return
+	}
+
+	line, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || line < 1 {
+		editorSetStatusMessage("Invalid line number: %s", input)
+		return
+	}
+
+	editorGoToLineNumber(line)
+}
+
+// editorGoToLineNumber moves the cursor to the start of line, which counts
+// from 1, or to the last line when there aren't that many.
+func editorGoToLineNumber(line int) {
+	editorRecordJump()
+	editorGoToPos(bufferPos{max(min(line, len(e.row))-1, 0), 0})
+}
@@ -0,0 +1,93 @@
+package main
+
+// killRingSize is the maximum number of pieces of text kept in killRing.
+const killRingSize = 32
+
+// killRing contains the text which was killed (cut), most recent last.
+var killRing []string
+
+// killPrevKey is the key that was pressed before the one being handled.
+var killPrevKey rune
+
+// killAppend indicates whether the next kill should be added to the most
+// recent text in killRing, rather than being kept separately. It's set when
+// Ctrl-K is pressed repeatedly, so that the lines can be yanked back at once.
+var killAppend bool
+
+// editorKillBoundary is called with every key before it's handled to determine
+// whether kills continue from the previous one.
+func editorKillBoundary(key rune) {
+	killAppend = key == ctrl('k') && killPrevKey == ctrl('k')
+	killPrevKey = key
+}
+
+// editorKill adds text to the kill ring.
+func editorKill(text string) {
+	if killAppend && len(killRing) > 0 {
+		killRing[len(killRing)-1] += text
+		return
+	}
+
+	killRing = append(killRing, text)
+	if len(killRing) > killRingSize {
+		killRing = killRing[1:]
+	}
+}
+
+// editorKillLine deletes the text from the cursor to the end of the row, or
+// the newline at the end of the row when there isn't any, and adds it to the
+// kill ring.
+func editorKillLine() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	raw := e.row[e.cy].raw
+
+	end := bufferPos{e.cy, len(raw)}
+	if e.cx == len(raw) {
+		// The last row can only be removed when it's empty, since every row ends
+		// with a newline.
+		if e.cy+1 == len(e.row) && raw != "" {
+			return
+		}
+		end = bufferPos{e.cy + 1, 0}
+	}
+
+	start := bufferPos{e.cy, e.cx}
+	editorKill(bufferText(start, end))
+	bufferDeleteRange(start, end)
+}
+
+// editorKillLines deletes count rows, starting from the cursor, along with
+// the newlines at the end of them, and adds them to the kill ring.
+func editorKillLines(count int) {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	start := bufferPos{e.cy, e.cx}
+	end := bufferPos{e.cy + count, 0}
+	if end.line >= len(e.row) {
+		// The last row can't be removed since every row ends with a newline.
+		end = bufferPos{len(e.row) - 1, len(e.row[len(e.row)-1].raw)}
+	}
+	if start == end {
+		return
+	}
+
+	editorKill(bufferText(start, end))
+	bufferDeleteRange(start, end)
+}
+
+// editorYank inserts the most recently killed text at the cursor.
+func editorYank() {
+	if len(killRing) == 0 {
+		editorSetStatusMessage("Nothing to yank")
+		return
+	}
+
+	editorDeleteSelection()
+
+	editorInsertText(killRing[len(killRing)-1])
+}
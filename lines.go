@@ -0,0 +1,295 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// editorDuplicateRows inserts a copy of the selected rows, or the row
+// containing the cursor, after them. The cursor and selection move to the
+// copy.
+func editorDuplicateRows() {
+	first, last, ok := editorSelectedRows()
+	if !ok {
+		return
+	}
+
+	var lines []string
+	for i := first; i <= last; i++ {
+		lines = append(lines, e.row[i].raw)
+	}
+
+	anchor := e.anchor
+	bufferInsertLines(last+1, lines)
+
+	n := len(lines)
+	e.cy += n
+	if e.selecting {
+		e.anchor = bufferPos{anchor.line + n, anchor.col}
+	}
+}
+
+// editorMoveRows swaps the selected rows, or the row containing the cursor,
+// with the row above them (dir < 0) or below them (dir > 0). The cursor and
+// selection move along with the rows.
+func editorMoveRows(dir int) {
+	first, last, ok := editorSelectedRows()
+	if !ok {
+		return
+	}
+	if (dir < 0 && first == 0) || (dir > 0 && last+1 >= len(e.row)) {
+		return
+	}
+
+	// The range of rows which are rearranged, including the neighbour.
+	from, to := first, last+1
+	if dir < 0 {
+		from--
+	} else {
+		to++
+	}
+
+	moved := bufferText(bufferPos{first, 0}, bufferPos{last + 1, 0})
+	var text string
+	if dir < 0 {
+		text = moved + bufferText(bufferPos{from, 0}, bufferPos{first, 0})
+	} else {
+		text = bufferText(bufferPos{last + 1, 0}, bufferPos{to, 0}) + moved
+	}
+
+	anchor := e.anchor
+	bufferReplaceRange(bufferPos{from, 0}, bufferPos{to, 0}, text)
+
+	e.cy += dir
+	if e.selecting {
+		e.anchor = bufferPos{anchor.line + dir, anchor.col}
+	}
+}
+
+// editorJoinRows joins the row containing the cursor with the count rows after
+// it, or joins all of the selected rows. Unless keepWhitespace is set, the
+// whitespace around each join is collapsed to a single space.
+func editorJoinRows(count int, keepWhitespace bool) {
+	if editorSelectionSpansRows() {
+		first, last, _ := editorSelectedRows()
+		e.cy = first
+		// A selection which ends at the start of the row after the one it's on
+		// joins that row with the next, as when there isn't a selection.
+		count = max(last-first, 1)
+		editorClearSelection()
+	}
+
+	for range count {
+		if e.cy+1 >= len(e.row) {
+			break
+		}
+
+		raw := e.row[e.cy].raw
+		next := e.row[e.cy+1].raw
+
+		if keepWhitespace {
+			e.cx = len(raw)
+			bufferDeleteRange(bufferPos{e.cy, len(raw)}, bufferPos{e.cy + 1, 0})
+			continue
+		}
+
+		trimmed := strings.TrimRight(raw, " \t")
+		ws := leadingWhitespace(next)
+
+		sep := " "
+		if trimmed == "" || len(ws) == len(next) || strings.HasPrefix(next[len(ws):], ")") {
+			sep = ""
+		}
+
+		e.cx = len(trimmed)
+		bufferReplaceRange(bufferPos{e.cy, len(trimmed)}, bufferPos{e.cy + 1, len(ws)}, sep)
+	}
+}
+
+// editorJoinCommand handles the join commands from the command prompt, which
+// take the number of rows to join as an optional argument.
+func editorJoinCommand(args string, keepWhitespace bool) {
+	count := 1
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n < 1 {
+			editorSetStatusMessage("Invalid count: %s", args)
+			return
+		}
+		count = n
+	}
+
+	editorJoinRows(count, keepWhitespace)
+}
+
+// editorCommandRows returns the range of rows which commands like sort act
+// on: the selected rows, or all of them when nothing is selected.
+func editorCommandRows() (first, last int, ok bool) {
+	if _, _, selected := editorSelection(); selected {
+		return editorSelectedRows()
+	}
+
+	return 0, len(e.row) - 1, len(e.row) > 0
+}
+
+// rowsText returns the text of the rows from first to last.
+func rowsText(first, last int) []string {
+	var lines []string
+	for i := first; i <= last; i++ {
+		lines = append(lines, e.row[i].raw)
+	}
+
+	return lines
+}
+
+// parseFlags parses command arguments like "-a -b" or "-ab", where each letter
+// must be one of allowed. It returns all of the letters which were given.
+func parseFlags(args string, allowed string) (string, bool) {
+	var flags string
+	for _, arg := range strings.Fields(args) {
+		letters, ok := strings.CutPrefix(arg, "-")
+		if !ok || letters == "" || strings.Trim(letters, allowed) != "" {
+			return "", false
+		}
+		flags += letters
+	}
+
+	return flags, true
+}
+
+// editorReplaceRows replaces the rows from first to last with lines in a
+// single change. When there's a selection, it's moved to cover the new rows.
+func editorReplaceRows(first, last int, lines []string) {
+	end := bufferPos{last, len(e.row[last].raw)}
+	text := strings.Join(lines, "\n")
+	if text == bufferText(bufferPos{first, 0}, end) {
+		return
+	}
+
+	bufferReplaceRange(bufferPos{first, 0}, end, text)
+
+	if e.selecting {
+		e.anchor = bufferPos{first, 0}
+		e.cy = first + len(lines) - 1
+		e.cx = len(e.row[e.cy].raw)
+	} else {
+		e.cy = min(e.cy, len(e.row)-1)
+		editorClampCursor()
+	}
+}
+
+// editorSortCommand sorts the selected rows, or the whole file. The flags in
+// args are -n to compare the numbers at the start of rows rather than their
+// text, and -r to reverse the order.
+func editorSortCommand(args string) {
+	flags, ok := parseFlags(args, "nr")
+	if !ok {
+		editorSetStatusMessage("Usage: sort [-n] [-r]")
+		return
+	}
+	numeric := strings.Contains(flags, "n")
+	reverse := strings.Contains(flags, "r")
+
+	first, last, ok := editorCommandRows()
+	if !ok {
+		return
+	}
+
+	lines := rowsText(first, last)
+	compare := strings.Compare
+	if numeric {
+		compare = compareLeadingNumbers
+	}
+	slices.SortStableFunc(lines, func(a, b string) int {
+		if reverse {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+
+	editorReplaceRows(first, last, lines)
+	editorSetStatusMessage("Sorted %d lines", len(lines))
+}
+
+// compareLeadingNumbers compares the numbers at the start of a and b, ignoring
+// leading whitespace. Lines which don't start with a number come before those
+// which do, and are compared by their text.
+func compareLeadingNumbers(a, b string) int {
+	x, aOk := leadingNumber(a)
+	y, bOk := leadingNumber(b)
+
+	switch {
+	case aOk && bOk:
+		return cmp.Compare(x, y)
+	case aOk:
+		return 1
+	case bOk:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// leadingNumber parses the decimal number at the start of s, ignoring leading
+// whitespace.
+func leadingNumber(s string) (float64, bool) {
+	s = strings.TrimLeft(s, " \t")
+
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	for end < len(s) && ((s[end] >= '0' && s[end] <= '9') || s[end] == '.') {
+		end++
+	}
+
+	n, err := strconv.ParseFloat(s[:end], 64)
+	return n, err == nil
+}
+
+// editorUniqCommand removes duplicate rows from the selection, or the whole
+// file, keeping the first copy of each. The flags in args are -a to only
+// remove copies which are next to each other, and -l to keep the last copy
+// instead of the first.
+func editorUniqCommand(args string) {
+	flags, ok := parseFlags(args, "al")
+	if !ok {
+		editorSetStatusMessage("Usage: uniq [-a] [-l]")
+		return
+	}
+	adjacent := strings.Contains(flags, "a")
+	keepLast := strings.Contains(flags, "l")
+
+	first, last, ok := editorCommandRows()
+	if !ok {
+		return
+	}
+
+	lines := rowsText(first, last)
+	if keepLast {
+		slices.Reverse(lines)
+	}
+
+	var kept []string
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		if adjacent && i > 0 && line == lines[i-1] {
+			continue
+		}
+		if !adjacent && seen[line] {
+			continue
+		}
+
+		seen[line] = true
+		kept = append(kept, line)
+	}
+
+	if keepLast {
+		slices.Reverse(kept)
+	}
+
+	editorReplaceRows(first, last, kept)
+	editorSetStatusMessage("Removed %d duplicate lines", len(lines)-len(kept))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lintGeneration is incremented each time the linter is run so that results
+// from earlier runs which finish late are ignored.
+var lintGeneration int
+
+// location is a position in a file given in the output of a program, like
+// file.go:12:5: message.
+type location struct {
+	path      string
+	line, col int
+	message   string
+}
+
+// locationPattern matches lines of the form path:line:col: message, where the
+// column is optional.
+var locationPattern = regexp.MustCompile(`^([^:\s][^:]*):(\d+):(?:(\d+):)?\s*(.*)$`)
+
+// parseLocation parses a line of output which refers to a position in a file.
+// Lines and columns in it are 1-based, as they're given by most programs.
+func parseLocation(line string) (location, bool) {
+	// go vet prefixes some of its messages.
+	line = strings.TrimPrefix(line, "vet: ")
+
+	m := locationPattern.FindStringSubmatch(line)
+	if m == nil {
+		return location{}, false
+	}
+
+	loc := location{path: m[1], message: m[4]}
+	loc.line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		loc.col, _ = strconv.Atoi(m[3])
+	}
+
+	return loc, true
+}
+
+// editorLinter returns the shell command used to check the file, or "" when
+// there isn't one.
+func editorLinter() string {
+	if e.linter != "" {
+		return e.linter
+	}
+	if e.syntax != nil {
+		return e.syntax.linter
+	}
+
+	return ""
+}
+
+// editorRunLinter checks the file with its linter in the background. The
+// command is run in the directory containing the file, with %f replaced by
+// its name. Lines of its output like file:line:col: message which refer to the
+// file become diagnostics.
+func editorRunLinter() {
+	linter := editorLinter()
+	if linter == "" || e.filename == "" {
+		return
+	}
+
+	lintGeneration++
+	generation := lintGeneration
+
+	dir, name := filepath.Split(e.filename)
+	path, err := filepath.Abs(e.filename)
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(linter, "%f", shellQuote(name)))
+	cmd.Dir = dir
+	editorPostProgress("Running %s...", linter)
+
+	go func() {
+		// Linters exit with an error when they find problems, so that isn't a
+		// failure.
+		output, _ := cmd.CombinedOutput()
+
+		var found []diagnostic
+		for line := range strings.Lines(string(output)) {
+			loc, ok := parseLocation(strings.TrimSpace(line))
+			if !ok || loc.line < 1 {
+				continue
+			}
+
+			locPath := loc.path
+			if !filepath.IsAbs(locPath) {
+				locPath = filepath.Join(cmd.Dir, locPath)
+			}
+			if abs, err := filepath.Abs(locPath); err != nil || abs != path {
+				continue
+			}
+
+			pos := bufferPos{loc.line - 1, max(loc.col-1, 0)}
+			found = append(found, diagnostic{
+				pos:     pos,
+				end:     pos,
+				message: loc.message,
+			})
+		}
+
+		editorPostToMain(func() {
+			if generation != lintGeneration {
+				return
+			}
+
+			editorSetDiagnostics(diagnosticSourceLint, found)
+
+			if len(found) == 0 {
+				editorSetStatusMessage("%s: no problems", linter)
+			} else {
+				editorSetStatusMessage("%s: %d problems", linter, len(found))
+			}
+		})
+	}()
+}
+
+// shellQuote quotes s so that sh treats it as a single word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
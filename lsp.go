@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// lspTimeout is how long to wait for the language server to respond to a
+// request before giving up.
+const lspTimeout = 3 * time.Second
+
+// lspShutdownTimeout is how long to wait for the language server to respond to
+// the shutdown request, and then to exit, when stopping it.
+const lspShutdownTimeout = time.Second
+
+// lspStopping tracks the language servers which are being shut down, so that
+// quitting can wait for them.
+var lspStopping sync.WaitGroup
+
+// lspClient is a connection to a language server, which provides information
+// about the file being edited using the Language Server Protocol.
+type lspClient struct {
+	// command is the shell command which started the server.
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+
+	writeMu sync.Mutex
+
+	// mu guards the fields used to match responses to requests, which are
+	// accessed by the goroutine reading from the server.
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]func(result json.RawMessage, err error)
+
+	// The remaining fields are only accessed on the main goroutine.
+
+	// ready indicates whether the server has been initialized.
+	ready bool
+	// utf8 indicates whether positions sent to and from the server count bytes
+	// rather than UTF-16 code units.
+	utf8 bool
+	// capabilities is the part of the server's response to the initialize
+	// request which describes the features it supports.
+	capabilities json.RawMessage
+
+	// uri identifies the file which the server was told is open, and version
+	// is incremented each time it's sent new contents.
+	uri     string
+	version int
+	// changed indicates whether the file was edited since the server was last
+	// sent its contents.
+	changed bool
+}
+
+// lsp is the connection to the language server for the file, or nil when
+// there isn't one.
+var lsp *lspClient
+
+type lspMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+func init() {
+	bufferOnChange(func(bufferChange) {
+		if lsp != nil {
+			lsp.changed = true
+		}
+	})
+}
+
+// editorLanguageServer returns the shell command which starts the language
+// server for the file, or "" when there isn't one.
+func editorLanguageServer() string {
+	if e.languageServer != "" {
+		return e.languageServer
+	}
+	if e.syntax != nil {
+		return e.syntax.languageServer
+	}
+
+	return ""
+}
+
+// editorStartLanguageServer starts the language server for the file, if there
+// is one and it's installed, and tells it that the file is open. The server is
+// reused when it's already running for the previous file.
+func editorStartLanguageServer() {
+	command := editorLanguageServer()
+	if lsp != nil && lsp.command == command {
+		lsp.open()
+		return
+	}
+
+	editorStopLanguageServer()
+
+	if command == "" || e.filename == "" {
+		return
+	}
+	if _, err := exec.LookPath(strings.Fields(command)[0]); err != nil {
+		// Language servers are optional, so don't complain about it not being
+		// installed.
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		editorSetStatusMessage("Can't start %s: %s", command, err.Error())
+		return
+	}
+	editorPostProgress("Starting %s...", command)
+
+	c := &lspClient{
+		command: command,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int]func(json.RawMessage, error)),
+	}
+	lsp = c
+	go c.readLoop(stdout)
+
+	rootURI := ""
+	if dir, err := filepath.Abs("."); err == nil {
+		rootURI = fileURI(dir)
+	}
+
+	params := map[string]any{
+		"processId": nil,
+		"rootUri":   rootURI,
+		"capabilities": map[string]any{
+			"general": map[string]any{
+				"positionEncodings": []string{"utf-8", "utf-16"},
+			},
+			"textDocument": map[string]any{
+				"publishDiagnostics": map[string]any{},
+			},
+		},
+	}
+	c.requestAsync("initialize", params, func(result json.RawMessage, err error) {
+		if err != nil {
+			editorSetStatusMessage("%s: %s", command, err.Error())
+			return
+		}
+
+		var init struct {
+			Capabilities struct {
+				PositionEncoding string `json:"positionEncoding"`
+			} `json:"capabilities"`
+		}
+		json.Unmarshal(result, &init)
+
+		var raw struct {
+			Capabilities json.RawMessage `json:"capabilities"`
+		}
+		json.Unmarshal(result, &raw)
+
+		c.utf8 = init.Capabilities.PositionEncoding == "utf-8"
+		c.capabilities = raw.Capabilities
+		c.ready = true
+
+		c.notify("initialized", map[string]any{})
+		c.open()
+		editorPostProgress("%s is ready", command)
+	})
+}
+
+// editorStopLanguageServer disconnects from the language server, if there is
+// one.
+func editorStopLanguageServer() {
+	if lsp == nil {
+		return
+	}
+
+	c := lsp
+	lsp = nil
+	editorSetDiagnostics(diagnosticSourceLSP, nil)
+
+	ready := c.ready
+	lspStopping.Add(1)
+	go func() {
+		defer lspStopping.Done()
+		c.shutdown(ready)
+	}()
+}
+
+// shutdown asks the server to shut down, as the protocol requires before
+// telling it to exit, and then waits for it to exit. The server is killed
+// when it doesn't respond in time. ready is whether the server was
+// initialized, since it can't be asked to shut down before then.
+func (c *lspClient) shutdown(ready bool) {
+	if ready {
+		done := make(chan struct{})
+		id := c.send("shutdown", nil, func(json.RawMessage, error) {
+			close(done)
+		})
+
+		select {
+		case <-done:
+		case <-time.After(lspShutdownTimeout):
+			c.forget(id)
+		}
+	}
+
+	c.notify("exit", nil)
+	c.stdin.Close()
+
+	exited := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-time.After(lspShutdownTimeout):
+		c.cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// editorWaitForLanguageServers stops the language server, and waits for it and
+// any others which are stopping to exit. It's called before quitting.
+func editorWaitForLanguageServers() {
+	editorStopLanguageServer()
+	lspStopping.Wait()
+}
+
+// open tells the server that the file being edited is open, and that the one
+// which was open before, if any, is closed.
+func (c *lspClient) open() {
+	if !c.ready {
+		// This is done once the server is initialized.
+		return
+	}
+
+	if c.uri != "" {
+		c.notify("textDocument/didClose", map[string]any{
+			"textDocument": map[string]any{"uri": c.uri},
+		})
+	}
+	editorSetDiagnostics(diagnosticSourceLSP, nil)
+
+	c.uri = fileURI(e.filename)
+	c.version = 1
+	c.changed = false
+
+	languageID := ""
+	if e.syntax != nil {
+		languageID = e.syntax.fileType
+	}
+
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{
+			"uri":        c.uri,
+			"languageId": languageID,
+			"version":    c.version,
+			"text":       string(editorRowsToString()),
+		},
+	})
+}
+
+// editorLSPFlush sends the contents of the file to the language server if it
+// was edited since they were last sent. It's called while waiting for input,
+// so that edits in quick succession are sent together, and before requests
+// which depend on the contents.
+func editorLSPFlush() {
+	if lsp == nil || !lsp.ready || !lsp.changed {
+		return
+	}
+
+	lsp.changed = false
+	lsp.version++
+	lsp.notify("textDocument/didChange", map[string]any{
+		"textDocument": map[string]any{
+			"uri":     lsp.uri,
+			"version": lsp.version,
+		},
+		"contentChanges": []any{
+			map[string]any{"text": string(editorRowsToString())},
+		},
+	})
+}
+
+// request sends a request to the server and waits for the response.
+func (c *lspClient) request(method string, params any) (json.RawMessage, error) {
+	type response struct {
+		result json.RawMessage
+		err    error
+	}
+	ch := make(chan response, 1)
+
+	id := c.send(method, params, func(result json.RawMessage, err error) {
+		ch <- response{result, err}
+	})
+
+	select {
+	case r := <-ch:
+		return r.result, r.err
+	case <-time.After(lspTimeout):
+		c.forget(id)
+		return nil, fmt.Errorf("%s timed out", method)
+	}
+}
+
+// requestAsync sends a request to the server, and calls callback on the main
+// goroutine once the response is received.
+func (c *lspClient) requestAsync(method string, params any, callback func(result json.RawMessage, err error)) {
+	c.send(method, params, func(result json.RawMessage, err error) {
+		editorPostToMain(func() {
+			if lsp == c {
+				callback(result, err)
+			}
+		})
+	})
+}
+
+// send sends a request, and arranges for handle to be called on the
+// goroutine reading from the server when the response is received. It returns
+// the ID of the request.
+func (c *lspClient) send(method string, params any, handle func(json.RawMessage, error)) int {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = handle
+	c.mu.Unlock()
+
+	msg := map[string]any{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+
+	c.write(msg)
+	return id
+}
+
+// forget stops waiting for the response to the request with the given ID.
+func (c *lspClient) forget(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, id)
+}
+
+// notify sends a notification, which the server doesn't respond to.
+func (c *lspClient) notify(method string, params any) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+
+	c.write(msg)
+}
+
+// write sends a message to the server. Errors are ignored since they mean
+// that the server exited, which readLoop reports.
+func (c *lspClient) write(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n", len(body))
+	c.stdin.Write(body)
+}
+
+// readLoop reads messages from the server until it exits.
+func (c *lspClient) readLoop(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		body, err := readLSPMessage(br)
+		if err != nil {
+			break
+		}
+
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+
+		c.handle(msg)
+	}
+
+	editorPostToMain(func() {
+		if lsp == c {
+			editorStopLanguageServer()
+			editorSetStatusMessage("%s exited", c.command)
+		}
+	})
+}
+
+// readLSPMessage reads the headers and body of a message.
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		if value, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, errors.New("missing Content-Length")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+// handle handles a message from the server. It's called on the goroutine
+// reading from the server.
+func (c *lspClient) handle(msg lspMessage) {
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		// The server is making a request. None of them are supported, but some
+		// servers wait for a response before continuing.
+		var result any
+		if msg.Method == "workspace/configuration" {
+			var params struct {
+				Items []any `json:"items"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			result = make([]any, len(params.Items))
+		}
+		c.write(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+
+	case msg.Method == "textDocument/publishDiagnostics":
+		editorPostToMain(func() {
+			if lsp == c {
+				c.handleDiagnostics(msg.Params)
+			}
+		})
+
+	case msg.Method == "window/showMessage":
+		var params struct {
+			Type    int    `json:"type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		// Only show errors and warnings, since the others are mostly noise.
+		if params.Type == 1 || params.Type == 2 {
+			editorPostToMain(func() {
+				editorSetStatusMessage("%s", params.Message)
+			})
+		}
+
+	case msg.Method == "":
+		id, err := strconv.Atoi(string(msg.ID))
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		handle, ok := c.pending[id]
+		c.mu.Unlock()
+		if !ok {
+			return
+		}
+		c.forget(id)
+
+		if msg.Error != nil {
+			handle(nil, errors.New(msg.Error.Message))
+		} else {
+			handle(msg.Result, nil)
+		}
+	}
+}
+
+// handleDiagnostics replaces the diagnostics from the server with the ones in
+// a publishDiagnostics notification.
+func (c *lspClient) handleDiagnostics(raw json.RawMessage) {
+	var params struct {
+		URI         string `json:"uri"`
+		Diagnostics []struct {
+			Range   lspRange `json:"range"`
+			Message string   `json:"message"`
+		} `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil || params.URI != c.uri {
+		return
+	}
+
+	var found []diagnostic
+	for _, d := range params.Diagnostics {
+		found = append(found, diagnostic{
+			pos:     c.fromLSP(d.Range.Start, editorRowText),
+			end:     c.fromLSP(d.Range.End, editorRowText),
+			message: d.Message,
+		})
+	}
+
+	editorSetDiagnostics(diagnosticSourceLSP, found)
+}
+
+// editorRowText returns the text of the row at index at, or "" if there isn't
+// one.
+func editorRowText(at int) string {
+	if at < 0 || at >= len(e.row) {
+		return ""
+	}
+
+	return e.row[at].raw
+}
+
+// toLSP converts a position in the file to the form used by the server.
+func (c *lspClient) toLSP(pos bufferPos) lspPosition {
+	line := editorRowText(pos.line)
+	col := min(pos.col, len(line))
+	if c.utf8 {
+		return lspPosition{pos.line, col}
+	}
+
+	return lspPosition{pos.line, utf16Len(line[:col])}
+}
+
+// fromLSP converts a position from the server to a position in a file, where
+// lineText returns the text of each line of the file.
+func (c *lspClient) fromLSP(p lspPosition, lineText func(int) string) bufferPos {
+	line := lineText(p.Line)
+	if c.utf8 {
+		return bufferPos{p.Line, min(p.Character, len(line))}
+	}
+
+	units := 0
+	for i, r := range line {
+		if units >= p.Character {
+			return bufferPos{p.Line, i}
+		}
+		units += utf16RuneLen(r)
+	}
+
+	return bufferPos{p.Line, len(line)}
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// fileURI returns the file:// URI of the file at path.
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
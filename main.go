@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime/debug"
 	"slices"
 	"strings"
@@ -16,15 +17,39 @@ import (
 
 const version string = "0.0.1"
 
-const tabStop int = 8
-
 const requiredQuitTimes int = 3
 
 var quitTimes = requiredQuitTimes
 
-var lastMatchLine = -1
+// inPrompt indicates whether editorPrompt is currently displayed in the
+// message bar.
+var inPrompt = false
+
+// promptCursor is the column of the message bar which the cursor is displayed
+// in while input is being typed into a prompt, or -1 when there isn't one.
+var promptCursor = -1
+
+// promptSelection is the range of columns of the message bar which contain
+// the initial input of a prompt, until it's edited. They're highlighted to show
+// that typing replaces them.
+var promptSelection [2]int
+
+// pendingKeys are handled before reading any more input, e.g. when repeating
+// an edit.
+var pendingKeys []rune
+
+// lastMatch is the start of the match that the search prompt is at, or noMatch
+// when there isn't one.
+var lastMatch = noMatch
 var searchForward = true
 
+// searchStatus is shown in the status bar while searching, e.g. to tell that
+// the search wrapped around the end of the file.
+var searchStatus string
+
+// noMatch is before every position in the buffer.
+var noMatch = bufferPos{-1, 0}
+
 type editorRow struct {
 	idx    int
 	raw    string
@@ -33,6 +58,37 @@ type editorRow struct {
 	// with information which indicates how the character should be highlighted.
 	highlight      []editorHighlight
 	hasOpenComment bool
+	// openString is the quote character of a string which is still open at the
+	// end of the row, or 0 if there isn't one.
+	openString rune
+	// inCodeFence indicates whether the end of the row is inside of a fenced
+	// code block in Markdown.
+	inCodeFence bool
+	// syntaxState is used by the highlightRow function of e.syntax to keep
+	// track of constructs which continue onto the next row.
+	syntaxState int
+	// bracketDepth is how deeply nested the end of the row is inside of
+	// brackets. It's only kept track of when e.rainbowBrackets is set.
+	bracketDepth int
+
+	// tabs contains the indices in render where tabs start.
+	tabs []int
+	// padding contains the indices in raw of the delimiters which are followed
+	// by spaces to align columns, and the number of spaces. See csvPadding.
+	padding [][2]int
+	// trailingWhitespace is the index in render where the whitespace at the end
+	// of the row starts.
+	trailingWhitespace int
+	// indent is the number of spaces at the start of render.
+	indent int
+
+	// misspelled contains the ranges of render which contain misspelled
+	// words. See spellCheckRow.
+	misspelled [][2]int
+
+	// virtualText is displayed dimmed after the contents of the row, but isn't
+	// part of the file. See editorSetVirtualText.
+	virtualText string
 }
 
 type editorConfig struct {
@@ -57,26 +113,200 @@ type editorConfig struct {
 	// syntax indicates what syntax highlighting should be applied to the loaded
 	// file. nil means that there was no file type detected.
 	syntax *editorSyntax
+
+	// virtualTextCursorLineOnly limits the display of virtual text to the row
+	// containing the cursor.
+	virtualTextCursorLineOnly bool
+	// cursorLine enables highlighting the row containing the cursor.
+	cursorLine bool
+	// matchBrackets enables highlighting the bracket which matches the one next
+	// to the cursor.
+	matchBrackets bool
+	// rainbowBrackets enables highlighting brackets based on how deeply they're
+	// nested.
+	rainbowBrackets bool
+	// showWhitespace enables making tabs and trailing whitespace visible.
+	showWhitespace bool
+	// trimTrailingWhitespace enables removing whitespace from the end of rows
+	// when saving.
+	trimTrailingWhitespace bool
+	// fixFinalNewline enables removing empty rows from the end of the file
+	// when saving.
+	fixFinalNewline bool
+	// colourColumns are the columns, starting from 1, which are tinted to show
+	// where lines become too long.
+	colourColumns []int
+	// indentGuides enables drawing lines at each level of indentation.
+	indentGuides bool
+	// autoIndent enables indenting new rows to match the previous one, and
+	// adjusting the indentation based on the syntax of the file.
+	autoIndent bool
+	// tabStop is the number of columns between tab stops.
+	tabStop int
+	// expandTab enables inserting spaces instead of tabs.
+	expandTab bool
+	// indentWidth is the number of columns in a level of indentation, or 0 to
+	// use tabStop.
+	indentWidth int
+	// detectIndent enables guessing the indentation style of files when
+	// they're opened.
+	detectIndent bool
+	// formatOnSave enables running the formatter before saving.
+	formatOnSave bool
+	// formatter is the shell command used to format the file, overriding the
+	// default for its file type.
+	formatter string
+	// languageServer is the shell command which starts the language server,
+	// overriding the default for the file type.
+	languageServer string
+	// buildCommand is the shell command run by the compile command,
+	// overriding the default for the file type.
+	buildCommand string
+	// linter is the shell command used to check the file after it's saved,
+	// overriding the default for its file type.
+	linter string
+	// timestampFormat is the layout, in the format used by Go's time package,
+	// of the timestamps inserted by the timestamp command.
+	timestampFormat string
+	// spellCheck enables highlighting misspelled words, using the words in the
+	// file at dictionary.
+	spellCheck bool
+	dictionary string
+
+	// alignColumns enables padding the cells of CSV and TSV files so that
+	// their columns line up.
+	alignColumns bool
+
+	// readOnly prevents the file from being changed, e.g. when it's binary.
+	readOnly bool
+
+	// statusFormat is the layout of the status bar. See editorStatusLine.
+	statusFormat string
+	// undoFile enables saving the undo history of files, so that changes can
+	// be undone after they're opened again.
+	undoFile bool
+	// wrapSearch lets searches continue from the other end of the file after
+	// the last match in the direction of the search.
+	wrapSearch bool
+
+	// scrollOff is the number of rows to keep visible above and below the
+	// cursor when scrolling.
+	scrollOff int
+	// sideScrollOff is the number of columns to keep visible to the left and
+	// right of the cursor, and sideScroll is the least number of columns to
+	// scroll by. See editorScrollToColumn.
+	sideScrollOff int
+	sideScroll    int
+
+	// showWordCount adds the number of words in the file, or the selection,
+	// to the status bar.
+	showWordCount bool
+
+	// selecting indicates whether there's a selection, which is the text
+	// between anchor and the cursor.
+	selecting bool
+	anchor    bufferPos
+	// blockSelecting indicates whether the selection is a block of columns,
+	// rather than a range of text.
+	blockSelecting bool
+
+	// cursors contains the secondary cursors, which edits are made at along
+	// with the cursor at cx and cy.
+	cursors []cursor
+	// sticky is the column which vertical movement is keeping the cursor at
+	// cx and cy in. Each of the secondary cursors has its own.
+	sticky stickyColumn
+
+	colourDepth colourDepth
+	// theme is the theme in use, or nil to use the default one.
+	theme *theme
 }
 
 var e editorConfig
 
+const backspace rune = 127 // Technically DEL in ASCII
+
+// specialKey is set in the keys below, which don't type a character. Like
+// altModifier, it's outside of the range of Unicode so that they don't clash
+// with any characters which are typed.
+const specialKey rune = 1 << 29
+
 const (
-	backspace rune = 127 // Technically DEL in ASCII
+	arrowUp rune = specialKey + iota
+	arrowDown
+	arrowLeft
+	arrowRight
 
-	arrowUp    rune = '↑'
-	arrowDown  rune = '↓'
-	arrowLeft  rune = '←'
-	arrowRight rune = '→'
+	pageUp
+	pageDown
+	home
+	end
 
-	pageUp   rune = '↟'
-	pageDown rune = '↡'
-	home     rune = '↞'
-	end      rune = '↠'
+	deleteKey
 
-	delete rune = '⌫'
+	shiftUp
+	shiftDown
+	shiftLeft
+	shiftRight
+
+	shiftTab
+
+	blockUp
+	blockDown
+	blockLeft
+	blockRight
+
+	altUp
+	altDown
+
+	wordLeft
+	wordRight
+
+	fileStart
+	fileEnd
+
+	scrollUp
+	scrollDown
+
+	findNext
+	findPrev
 )
 
+// isSpecialKey returns whether c is one of the keys above which don't type a
+// character.
+func isSpecialKey(c rune) bool {
+	return c&specialKey != 0 && !isAltKey(c)
+}
+
+// modifiedKeys maps the modifier and final character of sequences like
+// ESC [ 1 ; 2 A (Shift-Up) to the keys that they represent.
+var modifiedKeys = map[[2]byte]rune{
+	{'2', 'A'}: shiftUp,
+	{'2', 'B'}: shiftDown,
+	{'2', 'C'}: shiftRight,
+	{'2', 'D'}: shiftLeft,
+
+	{'4', 'A'}: blockUp,
+	{'4', 'B'}: blockDown,
+	{'4', 'C'}: blockRight,
+	{'4', 'D'}: blockLeft,
+
+	{'3', 'A'}: altUp,
+	{'3', 'B'}: altDown,
+	{'3', 'C'}: wordRight,
+	{'3', 'D'}: wordLeft,
+
+	{'5', 'A'}: scrollUp,
+	{'5', 'B'}: scrollDown,
+	{'5', 'C'}: wordRight,
+	{'5', 'D'}: wordLeft,
+	{'5', 'H'}: fileStart,
+	{'5', 'F'}: fileEnd,
+
+	// Shift-F3
+	{'2', 'R'}: findPrev,
+}
+
 func main() {
 	defer func() {
 		if err := recover(); err != nil {
@@ -94,11 +324,43 @@ func main() {
 		die(err.Error())
 	}
 
+	// The config is applied once it's been read, which is usually before the
+	// first key is pressed.
+	editorLoadConfig()
+
+	if len(os.Args) == 4 && os.Args[1] == "--diff" {
+		if err := editorDiffFiles(os.Args[2], os.Args[3]); err != nil {
+			die(err.Error())
+		}
+
+		fmt.Print("\x1b[2J")
+		fmt.Print("\x1b[H")
+		os.Exit(0)
+	}
+
+	if len(os.Args) == 2 && os.Args[1] == "--tutor" {
+		path, err := tutorFile()
+		if err != nil {
+			die(err.Error())
+		}
+
+		// Open the copy as if it had been given on the command line.
+		os.Args[1] = path
+	}
+
 	if len(os.Args) >= 2 {
+		readOnly, ok := editorCheckBinary(os.Args[1])
+		if !ok {
+			fmt.Print("\x1b[2J")
+			fmt.Print("\x1b[H")
+			os.Exit(0)
+		}
+
 		editorOpen(os.Args[1])
+		e.readOnly = readOnly
 	}
 
-	editorSetStatusMessage("HELP: Ctrl-S = save | Ctrl-Q = quit | Ctrl-F = find")
+	editorSetPromptMessage("HELP: Ctrl-S = save | Ctrl-Q = quit | Ctrl-F = find | Ctrl-P = command")
 
 	for {
 		editorRefreshScreen()
@@ -107,24 +369,17 @@ func main() {
 }
 
 func enableRawInput() error {
-	err := exec.Command("stty", "-F", "/dev/tty", "raw").Run()
-	if err != nil {
-		return err
-	}
-
-	// Do not display entered characters on the screen.
-	err = exec.Command("stty", "-F", "/dev/tty", "-echo").Run()
-	if err != nil {
-		return err
-	}
-
-	// Time out read call after 100 ms of no input.
-	err = exec.Command("stty", "-F", "/dev/tty", "min", "0", "time", "1").Run()
-	if err != nil {
-		return err
-	}
+	// Do everything in a single invocation since spawning stty is the most
+	// expensive part of startup. In addition to raw mode, this disables echoing
+	// entered characters on the screen and makes reads time out after 100 ms of
+	// no input.
+	return exec.Command("stty", "-F", "/dev/tty", "raw", "-echo", "min", "0", "time", "1").Run()
+}
 
-	return nil
+// disableRawInput restores the terminal to the state that programs expect to
+// start in, e.g. before running an external command.
+func disableRawInput() error {
+	return exec.Command("stty", "-F", "/dev/tty", "-raw", "echo").Run()
 }
 
 func initEditor() (editorConfig, error) {
@@ -134,6 +389,21 @@ func initEditor() (editorConfig, error) {
 		rx:        0,
 		rowOffset: 0,
 		colOffset: 0,
+
+		matchBrackets: true,
+		autoIndent:    true,
+		tabStop:       8,
+		detectIndent:  true,
+		alignColumns:  true,
+		undoFile:      true,
+		wrapSearch:    true,
+		sideScroll:    1,
+
+		statusFormat:    defaultStatusFormat,
+		timestampFormat: "2006-01-02 15:04",
+		dictionary:      "/usr/share/dict/words",
+
+		colourDepth: detectColourDepth(),
 	}
 
 	// Move to end of screen
@@ -142,15 +412,13 @@ func initEditor() (editorConfig, error) {
 	// Query terminal for status information
 	fmt.Print("\x1b[6n\r\n")
 
-	// bb will contain the values in the format (for 80x24):
+	// The reply will be in the format (for 80x24):
 	// \x1b[24;80R
-	bb, err := io.ReadAll(os.Stdin)
+	output, err := readCursorPositionReply()
 	if err != nil {
 		die(err.Error())
 	}
 
-	output := string(bb)
-
 	var rows int
 	var cols int
 	n, err := fmt.Sscanf(output, "\x1b[%d;%dR", &rows, &cols)
@@ -168,9 +436,39 @@ func initEditor() (editorConfig, error) {
 	return config, nil
 }
 
+// readCursorPositionReply reads the terminal's reply to a cursor position
+// query. Reading stops at the terminating 'R' instead of waiting for the read
+// to time out so that startup isn't delayed.
+func readCursorPositionReply() (string, error) {
+	var reply []byte
+	c := []byte{0}
+	for len(reply) < 32 {
+		n, err := os.Stdin.Read(c)
+		if err == io.EOF || n == 0 {
+			// Timed out without a complete reply.
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		reply = append(reply, c[0])
+		if c[0] == 'R' {
+			break
+		}
+	}
+
+	return string(reply), nil
+}
+
 func editorSave() {
+	if e.readOnly {
+		editorSetStatusMessage("Can't save: the file is read-only")
+		return
+	}
+
 	if e.filename == "" {
-		e.filename = editorPrompt("Save as: %s", func(string, rune) {})
+		e.filename = editorPromptCompleting("Save as: %s", "", func(string, rune) {}, completePath)
 		if e.filename == "" {
 			editorSetStatusMessage("Save aborted")
 			return
@@ -179,6 +477,13 @@ func editorSave() {
 		editorSelectSyntaxHighlight()
 	}
 
+	if err := editorRunSaveHooks(); err != nil {
+		if !editorConfirm(fmt.Sprintf("%s. Save anyway? (y/n)", err.Error())) {
+			editorSetStatusMessage("Save aborted")
+			return
+		}
+	}
+
 	toSave := editorRowsToString()
 
 	// TODO: Write to temp file then rename to e.filename
@@ -187,6 +492,11 @@ func editorSave() {
 	} else {
 		e.dirty = false
 		editorSetStatusMessage("%d bytes written to disk", len(toSave))
+		if err := editorWriteUndoFile(); err != nil {
+			editorSetStatusMessage("Can't save undo history: %s", err.Error())
+		}
+		editorRunLinter()
+		editorRefreshGitSigns()
 	}
 }
 
@@ -200,13 +510,53 @@ func editorRowsToString() []byte {
 	return out.Bytes()
 }
 
+// editorOffsetToPosition converts a byte offset into the contents of the file,
+// as returned by editorRowsToString, into a row index and an index into the
+// raw field of that row.
+func editorOffsetToPosition(offset int) (cy, cx int) {
+	for i, r := range e.row {
+		if offset <= len(r.raw) {
+			return i, offset
+		}
+
+		// Account for the newline at the end of the row.
+		offset -= len(r.raw) + 1
+	}
+
+	return len(e.row), 0
+}
+
+// editorPositionToOffset is the inverse of editorOffsetToPosition.
+func editorPositionToOffset(cy, cx int) int {
+	offset := cx
+	for _, r := range e.row[:min(cy, len(e.row))] {
+		offset += len(r.raw) + 1
+	}
+
+	return offset
+}
+
+// editorSearchDefault returns the text which searches start with: the
+// selection when it's within a row, or else the word under the cursor.
+func editorSearchDefault() string {
+	start, end, ok := editorSelection()
+	if !ok || start.line != end.line {
+		start, end, ok = editorWordUnderCursor()
+	}
+	if !ok {
+		return ""
+	}
+
+	return bufferText(start, end)
+}
+
 func editorFind() {
 	savedCx := e.cx
 	savedCy := e.cy
 	savedColOffset := e.colOffset
 	savedRowOffset := e.rowOffset
 
-	query := editorPrompt("Search: %s (Use ESC/Arrows/Enter)", editorFindCallback)
+	query := editorPromptDefault("Search: %s (Use ESC/Up/Down/Enter)", editorSearchDefault(), editorFindCallback)
 
 	if query == "" { // cancelled search
 		e.cx = savedCx
@@ -214,64 +564,90 @@ func editorFind() {
 		e.colOffset = savedColOffset
 		e.rowOffset = savedRowOffset
 	}
+	if query != "" {
+		lastSearch = query
+	}
+	if query != "" && (e.cx != savedCx || e.cy != savedCy) {
+		// Record where the search started so that it can be jumped back to.
+		found := bufferPos{e.cy, e.cx}
+		e.cx, e.cy = savedCx, savedCy
+		editorRecordJump()
+		editorGoToPos(found)
+	}
 }
 
 func editorFindCallback(query string, key rune) {
 	clearSearchHighlight(e.row)
+	searchStatus = ""
 
 	if key == '\r' || key == '\x1b' {
-		lastMatchLine = -1
+		lastMatch = noMatch
 		searchForward = true
 		return
-	} else if key == arrowRight || key == arrowDown {
+	} else if key == arrowDown {
 		searchForward = true
-	} else if key == arrowLeft || key == arrowUp {
+	} else if key == arrowUp {
 		searchForward = false
 	} else {
-		lastMatchLine = -1
+		lastMatch = noMatch
 		searchForward = true
 	}
 
-	if lastMatchLine == -1 {
+	if lastMatch == noMatch {
 		searchForward = true
 	}
 
-	currentSearchLine := lastMatchLine
+	matches := bufferFindAll(query)
+	if len(matches) == 0 {
+		return
+	}
 
-	for range len(e.row) {
-		if searchForward {
-			currentSearchLine++
-		} else {
-			currentSearchLine--
+	var match [2]bufferPos
+	wrapped := true
+	direction := "forward"
+	if searchForward {
+		match = matches[0]
+		for _, m := range matches {
+			if lastMatch.before(m[0]) {
+				match, wrapped = m, false
+				break
+			}
 		}
-
-		if currentSearchLine == -1 {
-			currentSearchLine = len(e.row) - 1
-		} else if currentSearchLine == len(e.row) {
-			currentSearchLine = 0
+	} else {
+		direction = "backward"
+		match = matches[len(matches)-1]
+		for _, m := range slices.Backward(matches) {
+			if m[0].before(lastMatch) {
+				match, wrapped = m, false
+				break
+			}
 		}
+	}
 
-		row := e.row[currentSearchLine]
-		idx := strings.Index(row.render, query)
-		if idx >= 0 {
-			lastMatchLine = currentSearchLine
-			e.cy = currentSearchLine
-			e.cx = editorRowRxToCx(row, idx)
-			// Hack. Scroll to the bottom of the file so that the next refresh will
-			// scroll the match into view.
-			e.rowOffset = len(e.row)
-
-			highlightSearchResult(row, query, idx)
-			break
+	if wrapped && !e.wrapSearch {
+		searchStatus = "no more matches " + direction
+		// Stay at the current match.
+		for _, m := range matches {
+			if m[0] == lastMatch {
+				highlightSearchResult(e.row, m[0], m[1])
+			}
 		}
+		return
+	}
+	if wrapped {
+		searchStatus = "search wrapped " + direction
 	}
+
+	lastMatch = match[0]
+	e.cy, e.cx = match[0].line, match[0].col
+	editorScrollTo(e.cy, e.cx, scrollCenterIfHidden)
+
+	highlightSearchResult(e.row, match[0], match[1])
 }
 
 func editorOpen(path string) {
 	e.filename = path
 
-	editorSelectSyntaxHighlight()
-
 	bb, err := os.ReadFile(path)
 	if err != nil {
 		die("ReadFile")
@@ -279,142 +655,207 @@ func editorOpen(path string) {
 
 	text := string(bb)
 
+	var lines []string
 	for line := range strings.Lines(text) {
-		editorInsertRow(len(e.row), strings.TrimSuffix(line, "\n"))
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
 	}
+	bufferInsertLines(len(e.row), lines)
+
+	// This is done after loading the contents of the file since they may
+	// indicate the file type.
+	editorSelectSyntaxHighlight()
+	editorDetectIndent()
+	editorStartLanguageServer()
+	editorRefreshGitSigns()
 
 	e.dirty = false
+	editorUndoReset()
+	editorReadUndoFile()
 }
 
-func editorInsertNewline() {
-	if e.cx == 0 {
-		editorInsertRow(e.cy, "")
-	} else {
-		row := &e.row[e.cy]
-		editorInsertRow(e.cy+1, row.raw[e.cx:])
-
-		row = &e.row[e.cy]
-		row.raw = row.raw[:e.cx]
+// editorSwitchFile replaces the file being edited with the one at path, unless
+// there are unsaved changes. State which refers to positions in the old file,
+// like marks, is discarded. It returns whether the file at path is open.
+func editorSwitchFile(path string) bool {
+	if sameFile(path, e.filename) {
+		return true
+	}
+	if e.dirty {
+		editorSetStatusMessage("Can't open %s: there are unsaved changes", path)
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		editorSetStatusMessage("Can't open %s: %s", path, err.Error())
+		return false
+	}
 
-		editorUpdateRow(row)
+	readOnly, ok := editorCheckBinary(path)
+	if !ok {
+		return false
 	}
 
-	e.cy++
-	e.cx = 0
+	editorLoadFile(path)
+	e.readOnly = readOnly
+	return true
 }
 
-func editorInsertRow(at int, line string) {
-	e.row = slices.Insert(e.row, at, editorRow{idx: at, raw: line, render: ""})
-
-	for i := range e.row[at+1:] {
-		e.row[at+1+i].idx++
+// editorOpenCommand opens the file at path, prompting for it when it's empty.
+func editorOpenCommand(path string) {
+	if path == "" {
+		path = editorPromptCompleting("Open: %s", "", func(string, rune) {}, completePath)
+		if path == "" {
+			return
+		}
 	}
 
-	editorUpdateRow(&e.row[at])
-
-	e.dirty = true
+	editorSwitchFile(path)
 }
 
-func editorDelRow(at int) {
-	if at < 0 || at >= len(e.row) {
-		return
-	}
+// editorReloadFile loads the open file again, e.g. after it was changed in
+// hex mode, keeping the cursor on the same row.
+func editorReloadFile() {
+	cy, cx := e.cy, e.cx
+	readOnly := e.readOnly
+	editorLoadFile(e.filename)
+	e.readOnly = readOnly
 
-	e.row = slices.Delete(e.row, at, at+1)
-	for i := range e.row[at:] {
-		e.row[at+i].idx--
-	}
+	e.cy, e.cx = min(cy, len(e.row)), cx
+	editorClampCursor()
+}
 
-	e.dirty = true
+// editorLoadFile replaces the rows with the contents of the file at path.
+// State which refers to positions in the old rows, like marks, is discarded.
+func editorLoadFile(path string) {
+	editorWriteUndoFile()
+
+	e.readOnly = false
+	e.row = nil
+	e.cx, e.cy = 0, 0
+	e.rowOffset, e.colOffset = 0, 0
+	editorClearSelection()
+	editorClearCursors()
+
+	clear(marks)
+	activeSnippet = nil
+	editorClearGitSigns()
+	blameAnnotations = false
+	diagnostics = nil
+	lastDiagnosticRow = -1
+
+	editorOpen(path)
 }
 
-func editorInsertChar(c rune) {
-	if e.cy == len(e.row) {
-		editorInsertRow(len(e.row), "")
+// sameFile returns whether the paths a and b refer to the same file.
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
 	}
-	editorRowInsertChar(&e.row[e.cy], e.cx, c)
 
-	e.cx++
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
 }
 
-func editorRowInsertChar(row *editorRow, at int, c rune) {
-	if at < 0 || at > len(row.raw) {
-		at = len(row.raw)
+func editorInsertNewline() {
+	indent, increased := editorNewlineIndent()
+	text := "\n" + indent
+
+	// When splitting a block like {} put the end of it on its own row, with the
+	// original indentation.
+	if increased {
+		after := strings.TrimLeft(e.row[e.cy].raw[e.cx:], " \t")
+		if after != "" && strings.ContainsRune(e.syntax.indent.decreaseOn, rune(after[0])) {
+			text += "\n" + strings.TrimSuffix(indent, editorIndentUnit())
+		}
 	}
 
-	var newRaw strings.Builder
-	newRaw.Grow(len(row.raw) + utf8.RuneLen(c))
+	bufferReplaceRange(bufferPos{e.cy, e.cx}, bufferPos{e.cy, e.cx}, text)
 
-	newRaw.WriteString(row.raw[:at])
-	newRaw.WriteRune(c)
-	newRaw.WriteString(row.raw[at:])
+	e.cy++
+	e.cx = len(indent)
+}
 
-	row.raw = newRaw.String()
-	editorUpdateRow(row)
+func editorInsertChar(c rune) {
+	editorExpandAbbreviation(c)
+	editorDedentForChar(c)
+
+	bufferReplaceRange(bufferPos{e.cy, e.cx}, bufferPos{e.cy, e.cx}, string(c))
+
+	e.cx += utf8.RuneLen(c)
+}
 
-	e.dirty = true
+// editorInsertText inserts text, which may contain newlines, at the cursor and
+// moves the cursor to the end of it.
+func editorInsertText(text string) {
+	end := bufferReplaceRange(bufferPos{e.cy, e.cx}, bufferPos{e.cy, e.cx}, text)
+	e.cy, e.cx = end.line, end.col
 }
 
-func editorRowAppendString(row *editorRow, s string) {
-	row.raw = row.raw + s
-	editorUpdateRow(row)
+// editorReplaceKeepingCursor replaces the text from start up to end, moving the
+// cursor so that it stays on the same text.
+func editorReplaceKeepingCursor(start, end bufferPos, text string) {
+	cursor := bufferPos{e.cy, e.cx}
+	bufferReplaceRange(start, end, text)
 
-	e.dirty = true
+	cursor = cursor.adjust(bufferChange{start: start, end: end, newText: text})
+	e.cy, e.cx = cursor.line, cursor.col
 }
 
+// editorDelChar deletes the character before the cursor. Characters made up of
+// multiple code points, like emoji with modifiers, are deleted in their
+// entirety.
 func editorDelChar() {
 	if e.cy == len(e.row) || (e.cx == 0 && e.cy == 0) {
 		return
 	}
 
-	row := &e.row[e.cy]
 	if e.cx > 0 {
-		editorRowDelChar(row, e.cx-1)
-		e.cx--
+		start := prevGraphemeStart(e.row[e.cy].raw, e.cx)
+		bufferDeleteRange(bufferPos{e.cy, start}, bufferPos{e.cy, e.cx})
+		e.cx = start
 	} else {
 		// Deleting at the beginning of the line. Join the current line with the
 		// previous one.
 		e.cx = len(e.row[e.cy-1].raw)
-
-		editorRowAppendString(&e.row[e.cy-1], row.raw)
-		editorDelRow(e.cy)
+		bufferDeleteRange(bufferPos{e.cy - 1, e.cx}, bufferPos{e.cy, 0})
 
 		e.cy--
 	}
 }
 
-func editorRowDelChar(row *editorRow, at int) {
-	if at < 0 || at >= len(row.raw) {
-		return
-	}
-
-	// TODO: What about deleting a multi-byte character?
-	var newRaw strings.Builder
-	newRaw.Grow(len(row.raw) - 1)
-
-	newRaw.WriteString(row.raw[:at])
-	newRaw.WriteString(row.raw[at+1:])
-
-	row.raw = newRaw.String()
-
-	editorUpdateRow(row)
-
-	e.dirty = true
-}
-
 func editorUpdateRow(row *editorRow) {
 	var render strings.Builder
 	render.Grow(len(row.raw))
 
+	trailingStart := len(strings.TrimRight(row.raw, " \t"))
+	row.trailingWhitespace = -1
+	row.tabs = row.tabs[:0]
+	row.padding = csvPadding(row.raw)
+
 	// Replace tabs with spaces for rendering
 	var idx int
-	for _, ch := range row.raw {
-		if ch == '\t' {
+	padding := row.padding
+	for i, ch := range row.raw {
+		if i == trailingStart {
+			row.trailingWhitespace = render.Len()
+		}
+
+		if len(padding) > 0 && padding[0][0] == i {
+			if ch == '\t' {
+				ch = ' '
+			}
+			render.WriteRune(ch)
+			render.WriteString(strings.Repeat(" ", padding[0][1]))
+			idx += 1 + padding[0][1]
+			padding = padding[1:]
+		} else if ch == '\t' {
+			row.tabs = append(row.tabs, render.Len())
+
 			render.WriteRune(' ')
 			idx++
 
 			// Append spaces until the next tab stop
-			for ; idx%tabStop != 0; idx++ {
+			for ; idx%e.tabStop != 0; idx++ {
 				render.WriteRune(' ')
 			}
 		} else {
@@ -424,16 +865,53 @@ func editorUpdateRow(row *editorRow) {
 	}
 
 	row.render = render.String()
+	if row.trailingWhitespace < 0 {
+		row.trailingWhitespace = len(row.render)
+	}
+	row.indent = len(row.render) - len(strings.TrimLeft(row.render, " "))
 
 	editorUpdateSyntax(row)
 }
 
 func editorProcessKeypress() {
+	keyLog = keyLog[:0]
+	changes := bufferChangeCount
+
 	c := editorReadKey()
 
+	if editorCountKey(c) {
+		return
+	}
+	if count := editorTakeCount(c); count > 1 {
+		editorRepeatKey(c, count)
+		// The count is recorded with the key so that repeating the edit
+		// repeats the key as many times.
+		keyLog = append(countKeys(count), keyLog...)
+		editorRecordEdit(c, changes)
+		return
+	}
+
+	editorHandleKey(c, changes)
+}
+
+// editorHandleKey handles the key press c. changes is the value of
+// bufferChangeCount before it was read.
+func editorHandleKey(c rune, changes int) {
+	// Repeating an edit is undone all at once.
+	if !replayingEdit {
+		editorUndoBoundary(c)
+	}
+	editorKillBoundary(c)
+	editorWordCompletionBoundary(c)
+
+	if editorHandleCompletionKey(c) {
+		return
+	}
+
 	switch c {
 	case '\r': // enter
-		editorInsertNewline()
+		editorDeleteSelection()
+		editorForEachCursor(editorInsertNewline)
 		break
 	case ctrl('q'):
 		if e.dirty && quitTimes > 0 {
@@ -445,6 +923,11 @@ func editorProcessKeypress() {
 			return
 		}
 
+		// The history is saved again in case the file was changed back to
+		// how it was saved, e.g. by undoing and redoing.
+		editorWriteUndoFile()
+		editorWaitForLanguageServers()
+
 		// Clear out any partial output
 		fmt.Print("\x1b[2J")
 		fmt.Print("\x1b[H")
@@ -453,9 +936,110 @@ func editorProcessKeypress() {
 		editorSave()
 	case ctrl('f'):
 		editorFind()
-	case arrowUp, arrowDown, arrowLeft, arrowRight:
-		editorMoveCursor(c)
+	case findNext:
+		editorClearSelection()
+		editorFindNext(1)
+	case findPrev:
+		editorClearSelection()
+		editorFindNext(-1)
+	case alt('%'):
+		editorReplace(false)
+	case ctrl('p'):
+		editorCommandPrompt()
+	case ctrl('z'):
+		editorUndo()
+	case ctrl('r'):
+		editorRedo()
+	case ctrl('_'):
+		editorToggleComment()
+	case ctrl('d'):
+		editorDuplicateRows()
+	case ctrl('j'):
+		editorJoinRows(1, false)
+	case ctrl('k'):
+		editorKillLine()
+	case ctrl('y'):
+		editorYank()
+	case ctrl('n'):
+		editorClearSelection()
+		editorCompleteWord()
+	case ctrl('t'):
+		editorClearSelection()
+		editorForEachCursor(editorTransposeChars)
+	case alt('t'):
+		editorClearSelection()
+		editorForEachCursor(editorTransposeWords)
+	case alt('+'), alt('='):
+		editorClearSelection()
+		editorForEachCursor(func() { editorIncrementNumber(1) })
+	case alt('-'):
+		editorClearSelection()
+		editorForEachCursor(func() { editorIncrementNumber(-1) })
+	case alt('.'):
+		editorClearSelection()
+		editorGoToDefinition()
+	case alt('h'):
+		editorHover()
+	case alt('s'):
+		editorClearSelection()
+		editorSpellSuggest()
+	case alt('e'):
+		editorClearSelection()
+		editorNextError(1)
+	case alt('E'):
+		editorClearSelection()
+		editorNextError(-1)
+	case alt('g'):
+		editorClearSelection()
+		editorNextHunk(1)
+	case alt('G'):
+		editorClearSelection()
+		editorNextHunk(-1)
+	case alt('{'):
+		editorClearSelection()
+		editorMoveParagraph(-1)
+	case alt('}'):
+		editorClearSelection()
+		editorMoveParagraph(1)
+	case alt('a'):
+		editorClearSelection()
+		editorMoveToBlockStart()
+	case alt('m'):
+		editorSetMark()
+	case alt('\''):
+		editorClearSelection()
+		editorJumpToMark()
+	case ctrl(']'):
+		editorClearSelection()
+		editorJumpToMatchingBracket()
+	case altUp:
+		editorMoveRows(-1)
+	case altDown:
+		editorMoveRows(1)
+	case '\t':
+		if editorMoveToSnippetField(1) || editorExpandSnippet() {
+			break
+		}
+		if editorSelectionSpansRows() {
+			editorIndentSelectedRows()
+		} else {
+			editorDeleteSelection()
+			editorForEachCursor(editorInsertTab)
+		}
+	case shiftTab:
+		if editorMoveToSnippetField(-1) {
+			break
+		}
+		editorDedentSelectedRows()
+	case arrowUp, arrowDown, arrowLeft, arrowRight, wordLeft, wordRight:
+		editorClearSelection()
+		editorForEachCursor(func() { editorMoveCursor(c) })
+	case shiftUp, shiftDown, shiftLeft, shiftRight:
+		editorExtendSelection(c)
+	case blockUp, blockDown, blockLeft, blockRight:
+		editorExtendBlockSelection(c)
 	case pageUp, pageDown:
+		editorClearSelection()
 		if c == pageUp {
 			e.cy = e.rowOffset
 		} else if c == pageDown {
@@ -470,33 +1054,131 @@ func editorProcessKeypress() {
 				editorMoveCursor(arrowDown)
 			}
 		}
-	case home, ctrl('a'):
+	case scrollUp:
+		editorScrollViewport(-1)
+	case scrollDown:
+		editorScrollViewport(1)
+	case alt('r'):
+		editorRepeatEdit()
+	case ctrl('o'):
+		editorClearSelection()
+		editorJumpBack()
+	case alt('o'):
+		editorClearSelection()
+		editorJumpForward()
+	case ctrl('g'):
+		editorClearSelection()
+		editorGoToLine()
+	case fileStart:
+		editorClearSelection()
+		editorRecordJump()
+		e.cy = 0
 		e.cx = 0
-	case end, ctrl('e'):
-		if e.cy < len(e.row) {
+	case fileEnd:
+		editorClearSelection()
+		editorRecordJump()
+		if len(e.row) > 0 {
+			e.cy = len(e.row) - 1
 			e.cx = len(e.row[e.cy].raw)
 		}
-	case backspace, ctrl('h'), delete:
-		if c == delete {
-			editorMoveCursor(arrowRight)
+	case home, ctrl('a'):
+		editorClearSelection()
+		editorForEachCursor(editorMoveHome)
+	case end, ctrl('e'):
+		editorClearSelection()
+		editorForEachCursor(func() {
+			if e.cy < len(e.row) {
+				e.cx = len(e.row[e.cy].raw)
+			}
+		})
+	case ctrl('w'):
+		if editorDeleteSelection() {
+			break
+		}
+		editorForEachCursor(editorDeleteWordBackward)
+	case alt('d'):
+		if editorDeleteSelection() {
+			break
 		}
-		editorDelChar()
-		break
-	case '\x1b', ctrl('l'): // escape
+		editorForEachCursor(editorDeleteWordForward)
+	case alt('n'):
+		editorAddCursorAtNextMatch()
+	case backspace, ctrl('h'), deleteKey:
+		if editorDeleteSelection() {
+			break
+		}
+		editorForEachCursor(func() {
+			if c == deleteKey {
+				editorMoveCursor(arrowRight)
+			}
+			editorDelChar()
+		})
 		break
+	case ctrl('l'):
+		editorRecenter()
+	case '\x1b': // escape
+		editorClearSelection()
+		editorClearCursors()
+		activeSnippet = nil
 	default:
-		editorInsertChar(c)
+		if isAltKey(c) || isSpecialKey(c) {
+			// Unbound
+			break
+		}
+
+		editorDeleteSelection()
+		editorForEachCursor(func() { editorInsertChar(c) })
 	}
 
+	editorUpdateSnippetMirrors()
+	editorRecordEdit(c, changes)
+	editorUpdateCompletion(c)
+
 	quitTimes = requiredQuitTimes
 }
 
 func editorReadKey() rune {
+	if len(pendingKeys) > 0 {
+		key := pendingKeys[0]
+		pendingKeys = pendingKeys[1:]
+		return key
+	}
+
+	key := editorReadTerminalKey()
+	keyLog = append(keyLog, key)
+	return key
+}
+
+// editorReadUTF8 returns the character whose UTF-8 encoding starts with first,
+// by reading the rest of the encoding. utf8.RuneError is returned when the
+// encoding isn't valid.
+func editorReadUTF8(first byte) rune {
+	encoded := []byte{first}
+	for !utf8.FullRune(encoded) {
+		c := []byte{0}
+		if n, _ := os.Stdin.Read(c); n == 0 {
+			return utf8.RuneError
+		}
+		encoded = append(encoded, c[0])
+	}
+
+	r, _ := utf8.DecodeRune(encoded)
+	return r
+}
+
+// editorReadTerminalKey waits for a key to be pressed and returns it.
+func editorReadTerminalKey() rune {
 	c := []byte{0}
 	for {
 		_, err := os.Stdin.Read(c)
 		if err == io.EOF {
-			// This likely happened due to read timing out.
+			// This likely happened due to read timing out. Use the time to display
+			// updates from background jobs.
+			if editorRunPosted() {
+				editorRefreshScreen()
+			}
+			editorFlushProgress()
+			editorLSPFlush()
 			continue
 		}
 		if err != nil {
@@ -508,16 +1190,23 @@ func editorReadKey() rune {
 
 	ch := rune(c[0])
 
+	if ch >= utf8.RuneSelf {
+		return editorReadUTF8(c[0])
+	}
 	if ch != '\x1b' {
 		return ch
 	}
 
 	// Read escape sequence
-	seq := []byte{0, 0, 0}
+	seq := []byte{0, 0, 0, 0, 0}
 	_, err := os.Stdin.Read(seq[0:1])
 	if err != nil {
 		return '\x1b'
 	}
+	if seq[0] != '[' && seq[0] != 'O' {
+		// Terminals send ESC followed by the key when Alt is held.
+		return alt(rune(seq[0]))
+	}
 	_, err = os.Stdin.Read(seq[1:2])
 	if err != nil {
 		return '\x1b'
@@ -530,6 +1219,23 @@ func editorReadKey() rune {
 				return '\x1b'
 			}
 
+			if seq[2] == ';' {
+				// The key was pressed with a modifier, e.g. ESC [ 1 ; 2 A
+				_, err = os.Stdin.Read(seq[3:4])
+				if err != nil {
+					return '\x1b'
+				}
+				_, err = os.Stdin.Read(seq[4:5])
+				if err != nil {
+					return '\x1b'
+				}
+
+				if key, ok := modifiedKeys[[2]byte{seq[3], seq[4]}]; ok {
+					return key
+				}
+				return '\x1b'
+			}
+
 			if seq[2] == '~' {
 				switch seq[1] {
 				case '1', '7':
@@ -537,7 +1243,7 @@ func editorReadKey() rune {
 				case '4', '8':
 					return end
 				case '3':
-					return delete
+					return deleteKey
 				case '5':
 					return pageUp
 				case '6':
@@ -558,6 +1264,8 @@ func editorReadKey() rune {
 				return home
 			case 'F':
 				return end
+			case 'Z':
+				return shiftTab
 			}
 		}
 	} else if seq[0] == 'O' {
@@ -566,50 +1274,172 @@ func editorReadKey() rune {
 			return home
 		case 'F':
 			return end
+		case 'R':
+			return findNext
 		}
 	}
 
 	return '\x1b'
 }
 
+// editorConfirm shows a question in the message bar, and returns whether it
+// was answered with y. n or Escape answers no.
+func editorConfirm(question string) bool {
+	inPrompt = true
+	defer func() { inPrompt = false }()
+
+	for {
+		editorSetPromptMessage("%s", question)
+		editorRefreshScreen()
+
+		switch editorReadKey() {
+		case 'y', 'Y':
+			editorSetStatusMessage("")
+			return true
+		case 'n', 'N', '\x1b':
+			editorSetStatusMessage("")
+			return false
+		}
+	}
+}
+
 func editorPrompt(prompt string, callback func(query string, key rune)) string {
-	var buf strings.Builder
+	return editorPromptCompleting(prompt, "", callback, nil)
+}
+
+// editorPromptDefault prompts for input like editorPrompt, starting with
+// initial as the input so that it can be accepted or edited.
+func editorPromptDefault(prompt, initial string, callback func(query string, key rune)) string {
+	return editorPromptCompleting(prompt, initial, callback, nil)
+}
+
+// editorPromptCompleting prompts for input like editorPromptDefault, and
+// completes it with the inputs returned by complete when Tab is pressed. See
+// promptCompletion.
+func editorPromptCompleting(prompt, initial string, callback func(query string, key rune), complete func(input string) []string) string {
+	input := initial
+	// cursor is the index in input where typed characters are inserted.
+	cursor := len(input)
+	completion := promptCompletion{complete: complete}
+
+	inPrompt = true
+	defer func() {
+		inPrompt = false
+		promptCursor = -1
+		promptSelection = [2]int{}
+	}()
+
+	// The initial input is replaced by typing, rather than added to, until
+	// another key is pressed.
+	replaceInput := initial != ""
 
 	for {
-		editorSetStatusMessage(prompt, buf.String())
+		// Newlines are shown as a symbol so that they don't break the message
+		// bar.
+		shown := strings.ReplaceAll(input, "\n", "↵")
+		editorSetPromptMessage("%s%s", fmt.Sprintf(prompt, shown), completion.String())
+		promptCursor = promptColumn(prompt, input[:cursor])
+		promptSelection = [2]int{}
+		if replaceInput {
+			promptSelection = [2]int{promptColumn(prompt, ""), promptColumn(prompt, input)}
+		}
 		editorRefreshScreen()
 
 		c := editorReadKey()
-		if c == backspace || c == 'h'&0b0001_1111 || c == delete {
-			if buf.Len() > 0 {
-				old := buf.String()
-				buf.Reset()
-				buf.WriteString(old[:len(old)-1])
+		if complete != nil && (c == '\t' || c == shiftTab) {
+			replaceInput = false
+			dir := 1
+			if c == shiftTab {
+				dir = -1
+			}
+			input = completion.next(input, dir)
+			cursor = len(input)
+			continue
+		}
+		completion.reset()
+
+		if replaceInput && c >= ' ' && c != backspace && !isAltKey(c) && !isSpecialKey(c) {
+			input, cursor = "", 0
+		}
+		if c != '\r' && c != '\x1b' {
+			replaceInput = false
+		}
+
+		switch {
+		case c == backspace || c == ctrl('h'):
+			if cursor > 0 {
+				start := prevGraphemeStart(input, cursor)
+				input = input[:start] + input[cursor:]
+				cursor = start
 			}
-		} else if c == '\x1b' { // escape
+		case c == deleteKey:
+			input = input[:cursor] + input[nextGraphemeEnd(input, cursor):]
+		case c == arrowLeft:
+			cursor = prevGraphemeStart(input, cursor)
+			continue
+		case c == arrowRight:
+			cursor = nextGraphemeEnd(input, cursor)
+			continue
+		case c == home || c == ctrl('a'):
+			cursor = 0
+			continue
+		case c == end || c == ctrl('e'):
+			cursor = len(input)
+			continue
+		case c == ctrl('u'):
+			// Clear the input before the cursor, e.g. to replace the
+			// initial input.
+			input = input[cursor:]
+			cursor = 0
+		case c == '\x1b': // escape
 			editorSetStatusMessage("")
-			callback(buf.String(), c)
+			callback(input, c)
 			return ""
-		} else if c == '\r' {
-			if buf.Len() > 0 {
-				editorSetStatusMessage("")
-				callback(buf.String(), c)
-				return buf.String()
-			}
-		} else if c >= ' ' && c <= '~' { // if printable
-			buf.WriteRune(c)
+		case c == '\r':
+			editorSetStatusMessage("")
+			callback(input, c)
+			return input
+		case c == ctrl('j'):
+			// Typing a newline lets searches match text which spans rows.
+			input = input[:cursor] + "\n" + input[cursor:]
+			cursor++
+		case c >= ' ' && c != backspace && !isAltKey(c) && !isSpecialKey(c):
+			input = input[:cursor] + string(c) + input[cursor:]
+			cursor += utf8.RuneLen(c)
 		}
 
-		callback(buf.String(), c)
+		callback(input, c)
 	}
 }
 
+// promptColumn returns the column of the message bar where the cursor is
+// displayed, when before is the input which precedes it in prompt.
+func promptColumn(prompt, before string) int {
+	label, _, _ := strings.Cut(prompt, "%s")
+	label = strings.ReplaceAll(label, "%%", "%")
+
+	return min(utf8.RuneCountInString(label)+utf8.RuneCountInString(before), e.screenCols-1)
+}
+
 func editorMoveCursor(key rune) {
 	var row string
 	if e.cy < len(e.row) {
 		row = e.row[e.cy].raw
 	}
 
+	// Moving up or down keeps the cursor in the column it was in before the
+	// vertical movement started, even after passing through shorter rows.
+	if key == arrowUp || key == arrowDown {
+		rx := editorStickyColumn()
+		defer func() {
+			if e.cy < len(e.row) {
+				e.cx = editorRowRxToCx(e.row[e.cy], rx)
+			}
+			editorClampCursor()
+			e.sticky = stickyColumn{bufferPos{e.cy, e.cx}, rx}
+		}()
+	}
+
 	switch key {
 	case arrowUp:
 		if e.cy != 0 {
@@ -617,7 +1447,7 @@ func editorMoveCursor(key rune) {
 		}
 	case arrowLeft:
 		if e.cx != 0 {
-			e.cx--
+			e.cx = prevGraphemeStart(row, e.cx)
 		} else if e.cy > 0 {
 			e.cy--
 			e.cx = len(e.row[e.cy].raw)
@@ -628,24 +1458,80 @@ func editorMoveCursor(key rune) {
 		}
 	case arrowRight:
 		if e.cx < len(row) {
-			e.cx++
+			e.cx = nextGraphemeEnd(row, e.cx)
 		} else if e.cy < len(e.row) && e.cx == len(row) {
 			e.cy++
 			e.cx = 0
 		}
+	case wordLeft:
+		if e.cx != 0 {
+			e.cx = prevWordStart(row, e.cx)
+		} else if e.cy > 0 {
+			e.cy--
+			e.cx = len(e.row[e.cy].raw)
+		}
+	case wordRight:
+		if e.cx < len(row) {
+			e.cx = nextWordEnd(row, e.cx)
+		} else if e.cy < len(e.row) {
+			e.cy++
+			e.cx = 0
+		}
+	}
+
+	editorClampCursor()
+}
+
+// stickyColumn is the render column that vertical movement is trying to keep
+// a cursor in. It applies while the cursor is still at pos, where the last
+// vertical movement left it.
+type stickyColumn struct {
+	pos bufferPos
+	rx  int
+}
+
+// editorStickyColumn returns the render column to move up or down in, which
+// is the cursor's current one unless it's still where the last vertical
+// movement left it.
+func editorStickyColumn() int {
+	if e.sticky.pos == (bufferPos{e.cy, e.cx}) {
+		return e.sticky.rx
 	}
 
-	// Ensure the cursor isn't past the end of the line after moving up / down to
-	// a shorter line.
 	if e.cy < len(e.row) {
-		e.cx = min(e.cx, len(e.row[e.cy].raw))
+		return editorRowCxToRx(e.row[e.cy], e.cx)
+	}
+	return 0
+}
+
+// editorClampCursor ensures that the cursor isn't past the end of the line, or
+// in the middle of a character, after moving up / down to a different line.
+func editorClampCursor() {
+	e.cy = max(e.cy, 0)
+	if e.cy < len(e.row) {
+		raw := e.row[e.cy].raw
+		e.cx = graphemeStartAt(raw, min(e.cx, len(raw)))
 	} else {
 		e.cx = 0
 	}
 }
 
 func editorRefreshScreen() {
+	// The gutter, which takes up some of the width, is only shown when there
+	// are git signs, so they're updated before scrolling.
+	editorUpdateGitSigns()
+	editorUpdateColumnWidths()
 	editorScroll()
+	editorUpdatePreview()
+	editorUpdateConflicts()
+	editorUpdateBracketMatch()
+	editorUpdateSelectionRender()
+	editorUpdateCursorsRender()
+	editorUpdateDiagnostics()
+
+	if !inPrompt {
+		editorTakeProgress()
+	}
 
 	buf := bufio.NewWriter(os.Stdout)
 
@@ -654,12 +1540,34 @@ func editorRefreshScreen() {
 	// Move cursor to top left
 	fmt.Fprint(buf, "\x1b[H")
 
-	editorDrawRows(buf)
+	if activeOverlay != nil {
+		editorDrawOverlay(buf, activeOverlay)
+	} else if activeHexEditor != nil {
+		editorDrawHexEditor(buf, activeHexEditor)
+	} else if activeDiffView != nil {
+		editorDrawDiffView(buf, activeDiffView)
+	} else {
+		editorDrawRows(buf)
+	}
 	editorDrawStatusBar(buf)
 	editorDrawMessageBar(buf)
+	if activeOverlay == nil && activeDiffView == nil && activeHexEditor == nil {
+		editorDrawCompletions(buf)
+	}
 
 	// Move the cursor to the correct position
-	fmt.Fprintf(buf, "\x1b[%d;%dH", (e.cy-e.rowOffset)+1, (e.rx-e.colOffset)+1)
+	if promptCursor >= 0 {
+		fmt.Fprintf(buf, "\x1b[%d;%dH", e.screenRows+2, promptCursor+1)
+	} else if activeOverlay == nil && activeHexEditor != nil {
+		row, col := activeHexEditor.screenPos()
+		fmt.Fprintf(buf, "\x1b[%d;%dH", row, col)
+	} else if activeDiffView != nil {
+		fmt.Fprint(buf, "\x1b[2;1H")
+	} else if activeOverlay != nil {
+		fmt.Fprintf(buf, "\x1b[%d;1H", max(activeOverlay.selected-activeOverlay.offset, 0)+1)
+	} else {
+		fmt.Fprintf(buf, "\x1b[%d;%dH", (e.cy-e.rowOffset)+1, editorGutterWidth()+(e.rx-e.colOffset)+1)
+	}
 
 	// Show cursor again
 	fmt.Fprint(buf, "\x1b[?25h")
@@ -673,25 +1581,31 @@ func editorScroll() {
 		e.rx = editorRowCxToRx(e.row[e.cy], e.cx)
 	}
 
-	if e.cy < e.rowOffset {
-		e.rowOffset = e.cy
+	// Keep the rows around the cursor on the screen too, but don't scroll
+	// past the end of the file to do so.
+	margin := editorScrollMargin()
+	if e.cy < e.rowOffset+margin {
+		e.rowOffset = max(e.cy-margin, 0)
 	}
-	if e.cy >= e.rowOffset+e.screenRows {
-		e.rowOffset = e.cy - e.screenRows + 1
+	if e.cy >= e.rowOffset+e.screenRows-margin {
+		e.rowOffset = min(e.cy-e.screenRows+1+margin, max(e.cy-e.screenRows+1, len(e.row)+1-e.screenRows))
 	}
-	if e.rx < e.colOffset {
-		e.colOffset = e.rx
-	}
-	if e.rx >= e.colOffset+e.screenCols {
-		e.colOffset = e.rx - e.screenCols + 1
+	// The row at the top is hidden by the header when it's pinned.
+	if editorHeaderPinned() && e.cy == e.rowOffset {
+		e.rowOffset = max(e.cy-1, 0)
 	}
+	editorScrollToColumn(e.rx)
 }
 
 func editorRowCxToRx(row editorRow, cx int) int {
 	rx := 0
+	padding := row.padding
 	for i := range cx {
-		if row.raw[i] == '\t' {
-			rx += (tabStop - 1) - (rx % tabStop)
+		if len(padding) > 0 && padding[0][0] == i {
+			rx += padding[0][1]
+			padding = padding[1:]
+		} else if row.raw[i] == '\t' {
+			rx += (e.tabStop - 1) - (rx % e.tabStop)
 		}
 		rx++
 	}
@@ -701,9 +1615,15 @@ func editorRowCxToRx(row editorRow, cx int) int {
 
 func editorRowRxToCx(row editorRow, rx int) int {
 	curRx := 0
-	for cx, ch := range row.raw {
-		if ch == '\t' {
-			curRx += (tabStop - 1) - (curRx % tabStop)
+	padding := row.padding
+	// Like editorRowCxToRx, this goes byte by byte, since each byte of the
+	// render has its own index.
+	for cx := range len(row.raw) {
+		if len(padding) > 0 && padding[0][0] == cx {
+			curRx += padding[0][1]
+			padding = padding[1:]
+		} else if row.raw[cx] == '\t' {
+			curRx += (e.tabStop - 1) - (curRx % e.tabStop)
 		}
 		curRx++
 
@@ -718,6 +1638,9 @@ func editorRowRxToCx(row editorRow, rx int) int {
 func editorDrawRows(w io.Writer) {
 	for y := range e.screenRows {
 		fileRow := y + e.rowOffset
+		if y == 0 && editorHeaderPinned() {
+			fileRow = 0
+		}
 		if fileRow >= len(e.row) {
 			if len(e.row) == 0 && y == e.screenRows/3 {
 				welcomeLabel := fmt.Sprintf("lte -- version %s", version)
@@ -733,18 +1656,20 @@ func editorDrawRows(w io.Writer) {
 			} else {
 				fmt.Fprint(w, "~")
 			}
+
+			fmt.Fprint(w, "\x1b[K")
 		} else {
 			rowToDraw := e.row[fileRow].render
-			highlights := e.row[fileRow].highlight
 			if e.colOffset <= len(rowToDraw) {
 				rowToDraw = rowToDraw[e.colOffset:]
-				highlights = highlights[e.colOffset:]
 			} else {
 				rowToDraw = ""
 			}
-			rowToDraw = rowToDraw[:min(len(rowToDraw), e.screenCols)]
+			rowToDraw = rowToDraw[:min(len(rowToDraw), editorTextCols())]
 
-			currentColour := -1
+			editorDrawGutter(w, fileRow)
+
+			currentStyle := style{}
 			for i, ch := range rowToDraw {
 				// TODO: Need a check that handles multi-byte characters
 				if ch < ' ' || ch > '~' { // is non-printable
@@ -756,56 +1681,85 @@ func editorDrawRows(w io.Writer) {
 					fmt.Fprint(w, "\x1b[7m")
 					fmt.Fprint(w, sym)
 					fmt.Fprint(w, "\x1b[m")
-					if currentColour != -1 {
-						fmt.Fprintf(w, "\x1b[%dm", currentColour)
-					}
-				} else if highlights[i] == highlightNormal {
-					if currentColour != -1 {
-						fmt.Fprint(w, "\x1b[39m")
-						currentColour = -1
-					}
-				} else {
-					colour := editorSyntaxToColour(highlights[i])
-					if colour != currentColour {
-						fmt.Fprintf(w, "\x1b[%dm", colour)
-						currentColour = colour
-					}
+					currentStyle = style{}
+				}
+
+				// Tabs are shown with an arrow in their first cell.
+				if e.showWhitespace && isTabStart(&e.row[fileRow], e.colOffset+i) {
+					ch = '→'
+				} else if isIndentGuide(&e.row[fileRow], e.colOffset+i) {
+					ch = '│'
+				}
+
+				s := editorCellStyle(&e.row[fileRow], e.colOffset+i)
+				if s != currentStyle {
+					fmt.Fprint(w, s.sgr())
+					currentStyle = s
 				}
 				fmt.Fprint(w, string(ch))
 			}
 
-			fmt.Fprint(w, "\x1b[39m")
+			fmt.Fprint(w, "\x1b[24;39;49m")
+
+			// The background of the cursor line extends across the whole width of
+			// the screen.
+			if editorIsCursorLine(fileRow) {
+				fmt.Fprint(w, editorCurrentTheme().cursorLine.bgSGR())
+			}
+
+			drawn := len(rowToDraw)
+			drawn += editorDrawVirtualText(w, e.row[fileRow], editorTextCols()-len(rowToDraw))
+
+			fmt.Fprint(w, "\x1b[K")
+			fmt.Fprint(w, "\x1b[49m")
+
+			editorDrawColourColumns(w, drawn)
+			editorDrawSecondaryCursors(w, fileRow, drawn)
 		}
 
-		fmt.Fprint(w, "\x1b[K")
+		editorDrawPreviewLine(w, y)
 		fmt.Fprint(w, "\r\n")
 	}
 }
 
 func editorDrawStatusBar(w io.Writer) {
-	fmt.Fprint(w, "\x1b[7m")
-
-	name := e.filename
-	if e.filename == "" {
-		name = "[No Name]"
+	t := editorCurrentTheme()
+	if t.statusBar == (colour{}) && t.statusBarBackground == (colour{}) {
+		fmt.Fprint(w, "\x1b[7m")
+	} else {
+		fmt.Fprint(w, t.statusBar.fgSGR())
+		fmt.Fprint(w, t.statusBarBackground.bgSGR())
 	}
 
-	isModified := ""
-	if e.dirty {
-		isModified = "(modified)"
+	status, rightStatus := editorStatusLine()
+	if pendingCount > 0 {
+		rightStatus = fmt.Sprintf("count %d | %s", pendingCount, rightStatus)
+	}
+	if inPrompt && searchStatus != "" {
+		rightStatus = fmt.Sprintf("%s | %s", searchStatus, rightStatus)
+	}
+	if e.showWordCount {
+		rightStatus = fmt.Sprintf("%d words | %s", editorCountWords(), rightStatus)
 	}
 
-	status := fmt.Sprintf("%.20s - %d lines %s", name, len(e.row), isModified)
-	status = status[:min(len(status), e.screenCols)]
-
-	fileType := "no ft"
-	if e.syntax != nil {
-		fileType = e.syntax.fileType
+	if v := activeDiffView; v != nil {
+		status = fmt.Sprintf("diff - %d lines", len(v.lines))
+		rightStatus = fmt.Sprintf("%d/%d", v.offset+1, len(v.lines))
 	}
-	rightStatus := fmt.Sprintf("%s | %d/%d", fileType, e.cy+1, len(e.row))
+	if h := activeHexEditor; h != nil {
+		isModified := ""
+		if h.dirty {
+			isModified = "(modified)"
+		}
+		status = fmt.Sprintf("%.20s - %d bytes %s", h.path, len(h.data), isModified)
+		rightStatus = fmt.Sprintf("hex | 0x%x/0x%x", h.cursor, len(h.data))
+	}
+	// Leave room for the right status, so that it's still shown when the
+	// screen is narrow.
+	status = status[:min(len(status), max(e.screenCols-len(rightStatus)-1, 0))]
 
 	fmt.Fprint(w, status)
-	fmt.Fprint(w, strings.Repeat(" ", e.screenCols-len(status)-len(rightStatus)))
+	fmt.Fprint(w, strings.Repeat(" ", max(e.screenCols-len(status)-len(rightStatus), 0)))
 	fmt.Fprint(w, rightStatus)
 
 	fmt.Fprint(w, "\x1b[m")
@@ -816,14 +1770,30 @@ func editorDrawMessageBar(w io.Writer) {
 	fmt.Fprint(w, "\x1b[K")
 
 	if e.statusTime.Add(time.Second * 5).After(time.Now()) {
-		message := e.statusMessage
+		message := []rune(e.statusMessage)
 		message = message[:min(len(message), e.screenCols)]
 
-		fmt.Fprint(w, message)
+		start, end := min(promptSelection[0], len(message)), min(promptSelection[1], len(message))
+		fmt.Fprint(w, string(message[:start]))
+		fmt.Fprint(w, "\x1b[7m")
+		fmt.Fprint(w, string(message[start:end]))
+		fmt.Fprint(w, "\x1b[m")
+		fmt.Fprint(w, string(message[end:]))
 	}
 }
 
+// editorSetStatusMessage displays a message in the message bar, and adds it to
+// the message log.
 func editorSetStatusMessage(format string, a ...any) {
+	editorSetPromptMessage(format, a...)
+	logMessage(e.statusMessage, e.statusTime)
+	editorDiscardProgress()
+}
+
+// editorSetPromptMessage displays a message in the message bar without adding
+// it to the message log. It's used for prompts and help, which are displayed
+// again after each key press, and progress.
+func editorSetPromptMessage(format string, a ...any) {
 	e.statusMessage = fmt.Sprintf(format, a...)
 	e.statusTime = time.Now()
 }
@@ -836,13 +1806,26 @@ func ctrl(c rune) rune {
 	return c & 0b0001_1111
 }
 
+// altModifier is set in the keys returned by alt. It's outside of the range of
+// Unicode so that they don't clash with any characters.
+const altModifier rune = 1 << 30
+
+// alt returns the key for c pressed while holding Alt.
+func alt(c rune) rune {
+	return c | altModifier
+}
+
+func isAltKey(c rune) bool {
+	return c&altModifier != 0
+}
+
 func die(s any) {
 	// Clear out any partial output
 	fmt.Print("\x1b[2J")
 	fmt.Print("\x1b[H")
 
 	// Restore normal printing
-	exec.Command("stty", "-F", "/dev/tty", "-raw").Run()
+	disableRawInput()
 
 	fmt.Fprintln(os.Stderr, s)
 	os.Exit(1)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+)
+
+// highlightMakefile highlights targets, variables, directives and comments in
+// a row of a Makefile.
+func highlightMakefile(row *editorRow) {
+	fillHighlight(row, 0, len(row.render), highlightNormal)
+
+	s := row.render
+
+	// Recipes must start with a tab, which is only visible in raw since tabs
+	// are expanded in render. They're passed to the shell, so only variable
+	// references are highlighted.
+	if strings.HasPrefix(row.raw, "\t") {
+		highlightMakeVariables(row, 0, len(s))
+		return
+	}
+
+	end := len(s)
+	if comment := strings.IndexByte(s, '#'); comment >= 0 {
+		fillHighlight(row, comment, len(s), highlightComment)
+		end = comment
+	}
+
+	trimmed := strings.TrimLeft(s[:end], " ")
+	start := end - len(trimmed)
+
+	word := cssWordEnd(s, start)
+	if hl, ok := shellKeywordHighlight(s[start:word]); ok && (word == end || s[word] == ' ') {
+		// Directives, like ifeq or export
+		fillHighlight(row, start, word, hl)
+		start = word
+		for start < end && s[start] == ' ' {
+			start++
+		}
+	}
+
+	if assign := strings.IndexByte(s[start:end], '='); assign > 0 {
+		// Variable assignment, e.g. CC := gcc
+		name := strings.TrimRight(strings.TrimRight(s[start:start+assign], ":?+!"), " ")
+		fillHighlight(row, start, start+len(name), highlightKey)
+		highlightMakeVariables(row, start+assign, end)
+		return
+	}
+
+	if colon := strings.IndexByte(s[start:end], ':'); colon > 0 {
+		// Rule, e.g. all: build test
+		fillHighlight(row, start, start+colon, highlightKeyword1)
+		highlightMakeVariables(row, start, start+colon)
+		highlightMakeVariables(row, start+colon+1, end)
+		return
+	}
+
+	highlightMakeVariables(row, start, end)
+}
+
+// highlightMakeVariables highlights references to variables, like $(CC) or $@,
+// in row from start up to end.
+func highlightMakeVariables(row *editorRow, start, end int) {
+	s := row.render
+
+	for i := start; i < end; i++ {
+		if s[i] != '$' || i+1 >= end {
+			continue
+		}
+
+		varEnd := i + 2
+		switch s[i+1] {
+		case '$':
+			// Escaped dollar sign
+			i++
+			continue
+		case '(', '{':
+			closingChar := byte(')')
+			if s[i+1] == '{' {
+				closingChar = '}'
+			}
+
+			closing := strings.IndexByte(s[i:end], closingChar)
+			if closing < 0 {
+				varEnd = end
+			} else {
+				varEnd = i + closing + 1
+			}
+		}
+
+		fillHighlight(row, i, varEnd, highlightVariable)
+		i = varEnd - 1
+	}
+}
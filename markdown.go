@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+)
+
+// highlightMarkdown highlights headings, emphasis, code, links and list bullets
+// in a row of a Markdown file.
+func highlightMarkdown(row *editorRow) {
+	for i := range row.highlight {
+		row.highlight[i] = highlightNormal
+	}
+
+	inCodeFence := row.idx > 0 && e.row[row.idx-1].inCodeFence
+
+	trimmed := strings.TrimLeft(row.render, " ")
+	indent := len(row.render) - len(trimmed)
+
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		fillHighlight(row, 0, len(row.render), highlightString)
+		row.inCodeFence = !inCodeFence
+		return
+	}
+
+	row.inCodeFence = inCodeFence
+	if inCodeFence {
+		fillHighlight(row, 0, len(row.render), highlightString)
+		return
+	}
+
+	if isMarkdownHeading(trimmed) {
+		fillHighlight(row, 0, len(row.render), highlightKeyword1)
+		return
+	}
+
+	start := indent
+	if n := markdownBulletLen(trimmed); n > 0 {
+		fillHighlight(row, indent, indent+n, highlightKeyword2)
+		start += n
+	}
+
+	highlightMarkdownInline(row, start)
+}
+
+// highlightMarkdownInline highlights the inline elements, like emphasis, in
+// row starting at index start.
+func highlightMarkdownInline(row *editorRow, start int) {
+	s := row.render
+
+	i := start
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			// Escaped character
+			i += 2
+			continue
+		case '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				end += i + 2
+				fillHighlight(row, i, end, highlightString)
+				i = end
+				continue
+			}
+		case '*', '_':
+			if end := markdownEmphasisEnd(s, i); end > i {
+				fillHighlight(row, i, end, highlightEmphasis)
+				i = end
+				continue
+			}
+		case '[':
+			if end := markdownLinkEnd(s, i); end > i {
+				fillHighlight(row, i, end, highlightLink)
+				i = end
+				continue
+			}
+		}
+
+		i++
+	}
+}
+
+// isMarkdownHeading returns whether s, with its indentation removed, is an ATX
+// heading like "## Title".
+func isMarkdownHeading(s string) bool {
+	level := 0
+	for level < len(s) && s[level] == '#' {
+		level++
+	}
+
+	return level >= 1 && level <= 6 && (level == len(s) || s[level] == ' ')
+}
+
+// markdownBulletLen returns the length of the list marker (including the
+// following space) at the start of s, or 0 if s isn't a list item.
+func markdownBulletLen(s string) int {
+	if len(s) >= 2 && strings.IndexByte("-*+", s[0]) >= 0 && s[1] == ' ' {
+		return 2
+	}
+
+	digits := 0
+	for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits+1 < len(s) && (s[digits] == '.' || s[digits] == ')') && s[digits+1] == ' ' {
+		return digits + 2
+	}
+
+	return 0
+}
+
+// markdownEmphasisEnd returns the index after the end of the emphasized text
+// (e.g. *a* or __b__) starting at index i of s, or i if there isn't any.
+func markdownEmphasisEnd(s string, i int) int {
+	marker := s[i : i+1]
+	if strings.HasPrefix(s[i:], marker+marker) {
+		marker += marker
+	}
+
+	// Underscores within words, like snake_case, don't cause emphasis.
+	if marker[0] == '_' && i > 0 && isIdentifierByte(s[i-1]) {
+		return i
+	}
+
+	contentStart := i + len(marker)
+	if contentStart >= len(s) || s[contentStart] == ' ' {
+		return i
+	}
+
+	closing := strings.Index(s[contentStart:], marker)
+	if closing <= 0 {
+		return i
+	}
+	closing += contentStart
+	if s[closing-1] == ' ' {
+		return i
+	}
+
+	return closing + len(marker)
+}
+
+// markdownLinkEnd returns the index after the end of the link (e.g.
+// [text](url) or [text][ref]) starting at index i of s, or i if there isn't
+// one.
+func markdownLinkEnd(s string, i int) int {
+	textEnd := strings.IndexByte(s[i:], ']')
+	if textEnd < 0 {
+		return i
+	}
+	textEnd += i + 1
+
+	if textEnd >= len(s) {
+		return i
+	}
+
+	var closing byte
+	switch s[textEnd] {
+	case '(':
+		closing = ')'
+	case '[':
+		closing = ']'
+	default:
+		return i
+	}
+
+	end := strings.IndexByte(s[textEnd:], closing)
+	if end < 0 {
+		return i
+	}
+
+	return textEnd + end + 1
+}
+
+// fillHighlight sets the highlight of the characters in row from start up to
+// end.
+func fillHighlight(row *editorRow, start, end int, hl editorHighlight) {
+	for i := start; i < end; i++ {
+		row.highlight[i] = hl
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// marks contains the positions which were marked, keyed by the letter they
+// were given.
+var marks = make(map[rune]bufferPos)
+
+func init() {
+	bufferOnChange(marksOnChange)
+}
+
+// marksOnChange keeps marks on the same text when the buffer changes, e.g.
+// when rows are inserted above them.
+func marksOnChange(change bufferChange) {
+	for name, pos := range marks {
+		marks[name] = pos.adjust(change)
+	}
+}
+
+func isMarkName(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// editorSetMark reads a letter and marks the position of the cursor with it.
+func editorSetMark() {
+	editorSetPromptMessage("Set mark: press a letter")
+	editorRefreshScreen()
+
+	name := editorReadKey()
+	if !isMarkName(name) {
+		editorSetStatusMessage("Marks are named with a letter")
+		return
+	}
+
+	marks[name] = bufferPos{e.cy, e.cx}
+	editorSetStatusMessage("Set mark %c", name)
+}
+
+// editorJumpToMark reads the letter of a mark and moves the cursor to it.
+// Pressing ' instead lists the marks.
+func editorJumpToMark() {
+	editorSetPromptMessage("Jump to mark: press a letter, or ' to list them")
+	editorRefreshScreen()
+
+	name := editorReadKey()
+	if name == '\'' {
+		editorListMarks("")
+		return
+	}
+
+	editorGoToMark(name)
+}
+
+// editorGoToMark moves the cursor to the mark with the given name.
+func editorGoToMark(name rune) {
+	pos, ok := marks[name]
+	if !ok {
+		editorSetStatusMessage("Mark %c isn't set", name)
+		return
+	}
+
+	editorRecordJump()
+	editorGoToPos(pos)
+}
+
+// editorListMarks shows the marks which are set so that one can be jumped to.
+func editorListMarks(string) {
+	names := make([]rune, 0, len(marks))
+	for name := range marks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	if len(names) == 0 {
+		editorSetStatusMessage("No marks are set")
+		return
+	}
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		pos := marks[name]
+
+		text := ""
+		if pos.line < len(e.row) {
+			text = strings.TrimSpace(e.row[pos.line].raw)
+		}
+
+		lines[i] = fmt.Sprintf("%c  %5d:%-4d %s", name, pos.line+1, pos.col+1, text)
+	}
+
+	if i, ok := editorPickFromOverlay("Marks", lines); ok {
+		editorGoToMark(names[i])
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// maxLoggedMessages is the number of messages kept in the message log. The
+// oldest ones are dropped once there are more.
+const maxLoggedMessages = 1000
+
+type loggedMessage struct {
+	text string
+	time time.Time
+}
+
+// messageLog contains the messages which have been displayed in the message
+// bar, oldest first, so that they can be read after they've disappeared.
+var messageLog []loggedMessage
+
+// logMessage adds a message to the message log. Empty messages, which clear the
+// message bar, aren't logged.
+func logMessage(text string, t time.Time) {
+	if text == "" {
+		return
+	}
+
+	if len(messageLog) == maxLoggedMessages {
+		messageLog = slices.Delete(messageLog, 0, 1)
+	}
+	messageLog = append(messageLog, loggedMessage{text: text, time: t})
+}
+
+// editorShowMessageLog displays the logged messages, newest first. Long ones
+// are wrapped so that they can be read in full.
+func editorShowMessageLog() {
+	if len(messageLog) == 0 {
+		editorSetStatusMessage("No messages")
+		return
+	}
+
+	var lines []string
+	for _, m := range slices.Backward(messageLog) {
+		text := fmt.Sprintf("%s %s", m.time.Format("15:04:05"), m.text)
+		lines = append(lines, wrapLine(expandTabs(text), e.screenCols)...)
+	}
+
+	editorShowOverlay("Messages", lines)
+}
@@ -0,0 +1,112 @@
+package main
+
+import "strings"
+
+// editorMoveHome moves the cursor to the first non-blank character of the row,
+// or to the start of the row when it's already there.
+func editorMoveHome() {
+	if e.cy >= len(e.row) {
+		e.cx = 0
+		return
+	}
+
+	indent := len(leadingWhitespace(e.row[e.cy].raw))
+	if e.cx == indent {
+		e.cx = 0
+	} else {
+		e.cx = indent
+	}
+}
+
+// editorMoveParagraph moves the cursor to the previous (dir < 0) or next
+// (dir > 0) blank row which follows a non-blank one, or to the start or end of
+// the file when there isn't one.
+func editorMoveParagraph(dir int) {
+	isBlank := func(y int) bool {
+		return y >= len(e.row) || strings.TrimSpace(e.row[y].raw) == ""
+	}
+
+	y := e.cy + dir
+	// Skip over the blank rows next to the cursor.
+	for y >= 0 && y < len(e.row) && isBlank(y) {
+		y += dir
+	}
+	for y >= 0 && y < len(e.row) && !isBlank(y) {
+		y += dir
+	}
+
+	e.cy = max(0, min(y, len(e.row)))
+	e.cx = 0
+}
+
+// editorMoveToBlockStart moves the cursor to the start of the row which opens
+// the block containing it. Blocks are delimited by braces, or by indentation
+// for file types without them.
+func editorMoveToBlockStart() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	y, ok := enclosingBraceRow()
+	if !ok {
+		y, ok = enclosingIndentRow()
+	}
+	if !ok {
+		editorSetStatusMessage("Not inside of a block")
+		return
+	}
+
+	e.cy = y
+	e.cx = len(leadingWhitespace(e.row[y].raw))
+}
+
+// enclosingBraceRow returns the index of the row containing the { which opens
+// the innermost block that the cursor is in. Braces inside of strings and
+// comments are ignored.
+func enclosingBraceRow() (int, bool) {
+	depth := 0
+	for y := e.cy; y >= 0; y-- {
+		row := &e.row[y]
+
+		x := len(row.render) - 1
+		if y == e.cy {
+			x = editorRowCxToRx(*row, e.cx) - 1
+		}
+
+		for ; x >= 0; x-- {
+			if !isCodeHighlight(row.highlight[x]) {
+				continue
+			}
+
+			switch row.render[x] {
+			case '}':
+				depth++
+			case '{':
+				if depth == 0 {
+					return y, true
+				}
+				depth--
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// enclosingIndentRow returns the index of the closest row before the cursor
+// which is indented less than the row containing the cursor.
+func enclosingIndentRow() (int, bool) {
+	indent := e.row[e.cy].indent
+
+	for y := e.cy - 1; y >= 0; y-- {
+		if strings.TrimSpace(e.row[y].raw) == "" {
+			continue
+		}
+
+		if e.row[y].indent < indent {
+			return y, true
+		}
+	}
+
+	return 0, false
+}
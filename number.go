@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// editorIncrementNumber adds delta to the number under the cursor, or the
+// first one after it on the row. Decimal and hexadecimal (0x) numbers are
+// supported, and zero padding is kept. The cursor moves to the end of the
+// number.
+func editorIncrementNumber(delta int) {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	raw := e.row[e.cy].raw
+	start, end, ok := numberAtOrAfter(raw, e.cx)
+	if !ok {
+		editorSetStatusMessage("No number under the cursor")
+		return
+	}
+
+	text, err := addToNumber(raw[start:end], delta)
+	if err != nil {
+		editorSetStatusMessage("Can't change number: %s", err.Error())
+		return
+	}
+
+	bufferReplaceRange(bufferPos{e.cy, start}, bufferPos{e.cy, end}, text)
+	e.cx = start + len(text) - 1
+}
+
+// numberAtOrAfter returns the range of the number in s which contains index i,
+// or the first one which starts after it. A minus sign is included when it
+// isn't part of a word, e.g. the one in x-1 isn't.
+func numberAtOrAfter(s string, i int) (start, end int, ok bool) {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	isHexDigit := func(c byte) bool {
+		return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	}
+
+	for j := 0; j < len(s); {
+		if !isDigit(s[j]) {
+			j++
+			continue
+		}
+
+		start, end = j, j
+		if s[j] == '0' && j+2 < len(s) && (s[j+1] == 'x' || s[j+1] == 'X') && isHexDigit(s[j+2]) {
+			end = j + 2
+			for end < len(s) && isHexDigit(s[end]) {
+				end++
+			}
+		} else {
+			for end < len(s) && isDigit(s[end]) {
+				end++
+			}
+			if start > 0 && s[start-1] == '-' && (start == 1 || isWordSeparator(s[start-2])) {
+				start--
+			}
+		}
+
+		if end > i {
+			return start, end, true
+		}
+		j = end
+	}
+
+	return 0, 0, false
+}
+
+// addToNumber adds delta to the decimal or hexadecimal number in s, keeping
+// its zero padding and the case of its hex digits.
+func addToNumber(s string, delta int) (string, error) {
+	if digits, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		n, err := strconv.ParseUint(digits, 16, 64)
+		if err != nil {
+			return "", err
+		}
+
+		width := 0
+		if digits[0] == '0' {
+			width = len(digits)
+		}
+		format := "%0*x"
+		if strings.ToLower(s[2:]) != s[2:] {
+			format = "%0*X"
+		}
+
+		return s[:2] + fmt.Sprintf(format, width, n+uint64(delta)), nil
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return "", err
+	}
+
+	digits := strings.TrimPrefix(s, "-")
+	width := 0
+	if len(digits) > 1 && digits[0] == '0' {
+		width = len(digits)
+	}
+
+	n += int64(delta)
+	if n < 0 {
+		return fmt.Sprintf("-%0*d", width, -n), nil
+	}
+	return fmt.Sprintf("%0*d", width, n), nil
+}
+
+// editorIncrementCommand handles the increment and decrement commands, which
+// take the amount to change the number by as an optional argument.
+func editorIncrementCommand(args string, sign int) {
+	count := 1
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n < 1 {
+			editorSetStatusMessage("Invalid count: %s", args)
+			return
+		}
+		count = n
+	}
+
+	editorIncrementNumber(sign * count)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type editorOption struct {
+	name string
+	// set parses value and applies it. An empty value is passed when no value
+	// was given.
+	set func(value string) error
+	// get returns the current value formatted for display.
+	get func() string
+}
+
+var editorOptions = []editorOption{
+	boolOption("virtualtext.cursorline", &e.virtualTextCursorLineOnly),
+	boolOption("cursorline", &e.cursorLine),
+	boolOption("matchbrackets", &e.matchBrackets),
+	rainbowBracketsOption(),
+	boolOption("whitespace", &e.showWhitespace),
+	boolOption("trimwhitespace", &e.trimTrailingWhitespace),
+	boolOption("finalnewline", &e.fixFinalNewline),
+	colourColumnOption(),
+	boolOption("indentguides", &e.indentGuides),
+	boolOption("autoindent", &e.autoIndent),
+	tabStopOption(),
+	scrollOffOption(),
+	sideScrollOption("sidescrolloff", &e.sideScrollOff, 999),
+	sideScrollOption("sidescroll", &e.sideScroll, 999),
+	boolOption("expandtab", &e.expandTab),
+	indentWidthOption(),
+	boolOption("detectindent", &e.detectIndent),
+	stringOption("timestampformat", &e.timestampFormat),
+	stringOption("formatter", &e.formatter),
+	boolOption("formatonsave", &e.formatOnSave),
+	stringOption("linter", &e.linter),
+	stringOption("buildcommand", &e.buildCommand),
+	stringOption("languageserver", &e.languageServer),
+	spellCheckOption(),
+	dictionaryOption(),
+	boolOption("readonly", &e.readOnly),
+	boolOption("undofile", &e.undoFile),
+	boolOption("wrapsearch", &e.wrapSearch),
+	alignColumnsOption(),
+	stringOption("statusformat", &e.statusFormat),
+	boolOption("wordcount", &e.showWordCount),
+	colourDepthOption(),
+	themeOption(),
+}
+
+// boolOption returns an option which controls the value of b. Setting it
+// without a value toggles it.
+func boolOption(name string, b *bool) editorOption {
+	return editorOption{
+		name: name,
+		set: func(value string) error {
+			if value == "" {
+				*b = !*b
+				return nil
+			}
+
+			switch value {
+			case "on", "yes":
+				*b = true
+				return nil
+			case "off", "no":
+				*b = false
+				return nil
+			}
+
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("expected on or off, given %q", value)
+			}
+
+			*b = parsed
+			return nil
+		},
+		get: func() string {
+			if *b {
+				return "on"
+			}
+			return "off"
+		},
+	}
+}
+
+// stringOption returns an option which sets s to the value it's given.
+// Setting it without a value clears it, e.g. to stop using a formatter.
+func stringOption(name string, s *string) editorOption {
+	return editorOption{
+		name: name,
+		set: func(value string) error {
+			*s = value
+			return nil
+		},
+		get: func() string {
+			return *s
+		},
+	}
+}
+
+// editorHasOption returns whether there's an option with the given name.
+func editorHasOption(name string) bool {
+	for _, opt := range editorOptions {
+		if opt.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editorGetOption returns the value of the option with the given name,
+// formatted for display. ok is false when there isn't an option with that
+// name.
+func editorGetOption(name string) (value string, ok bool) {
+	for _, opt := range editorOptions {
+		if opt.name == name {
+			return opt.get(), true
+		}
+	}
+
+	return "", false
+}
+
+// editorSetOption sets the option with the given name.
+func editorSetOption(name, value string) error {
+	for _, opt := range editorOptions {
+		if opt.name == name {
+			return opt.set(value)
+		}
+	}
+
+	return fmt.Errorf("unknown option %q", name)
+}
+
+// editorSetOptionCommand handles input like "name value" or "name=value" from
+// the command prompt.
+func editorSetOptionCommand(args string) {
+	name, value, found := strings.Cut(args, "=")
+	if !found {
+		name, value, _ = strings.Cut(args, " ")
+	}
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+
+	if name == "" {
+		editorSetStatusMessage("Usage: set <option> [value]")
+		return
+	}
+
+	if err := editorSetOption(name, value); err != nil {
+		editorSetStatusMessage("Can't set %s: %s", name, err.Error())
+		return
+	}
+
+	for _, opt := range editorOptions {
+		if opt.name == name {
+			editorSetStatusMessage("%s = %s", name, opt.get())
+		}
+	}
+}
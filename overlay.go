@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// overlay is a list of lines which is displayed in place of the file, e.g. to
+// show the marks which are set.
+type overlay struct {
+	lines []string
+	// offset is the index of the line at the top of the screen.
+	offset int
+	// selected is the index of the highlighted line when one is being picked,
+	// or -1 when the lines are only being viewed.
+	selected int
+}
+
+// activeOverlay is the overlay being displayed, if any.
+var activeOverlay *overlay
+
+// editorShowOverlay displays lines in place of the file until Escape, q, or
+// Enter is pressed. The arrow and page keys scroll through them.
+func editorShowOverlay(title string, lines []string) {
+	runOverlay(&overlay{lines: lines, selected: -1}, title+" | Esc = close")
+}
+
+// editorPickFromOverlay displays lines in place of the file so that one can be
+// chosen with the arrow keys and Enter. It returns the index of the chosen
+// line, and false if Escape was pressed instead.
+func editorPickFromOverlay(title string, lines []string) (int, bool) {
+	if len(lines) == 0 {
+		return 0, false
+	}
+
+	o := &overlay{lines: lines}
+	ok := runOverlay(o, title+" | Enter = select | Esc = cancel")
+	return o.selected, ok
+}
+
+// runOverlay displays o until it's closed, and returns whether it was closed
+// with Enter.
+func runOverlay(o *overlay, help string) bool {
+	activeOverlay = o
+	defer func() { activeOverlay = nil }()
+
+	for {
+		editorSetPromptMessage("%s", help)
+		editorRefreshScreen()
+
+		switch editorReadKey() {
+		case arrowUp:
+			o.move(-1)
+		case arrowDown:
+			o.move(1)
+		case pageUp:
+			o.move(-e.screenRows)
+		case pageDown:
+			o.move(e.screenRows)
+		case '\r':
+			editorSetStatusMessage("")
+			return true
+		case '\x1b', 'q':
+			editorSetStatusMessage("")
+			return false
+		}
+	}
+}
+
+// move moves the selected line by delta, or scrolls by delta lines when there
+// isn't one.
+func (o *overlay) move(delta int) {
+	if o.selected < 0 {
+		o.offset = max(0, min(o.offset+delta, len(o.lines)-e.screenRows))
+		return
+	}
+
+	o.selected = max(0, min(o.selected+delta, len(o.lines)-1))
+	if o.selected < o.offset {
+		o.offset = o.selected
+	}
+	if o.selected >= o.offset+e.screenRows {
+		o.offset = o.selected - e.screenRows + 1
+	}
+}
+
+// editorDrawOverlay draws o in the area of the screen which normally contains
+// the file.
+func editorDrawOverlay(w io.Writer, o *overlay) {
+	for y := range e.screenRows {
+		i := o.offset + y
+		if i < len(o.lines) {
+			line := []rune(o.lines[i])
+			line = line[:min(len(line), e.screenCols)]
+
+			if i == o.selected {
+				fmt.Fprint(w, "\x1b[7m")
+			}
+			fmt.Fprint(w, string(line))
+			fmt.Fprint(w, "\x1b[m")
+		}
+
+		fmt.Fprint(w, "\x1b[K")
+		fmt.Fprint(w, "\r\n")
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// previewCell is a character in the Markdown preview, and how it's styled.
+type previewCell struct {
+	r rune
+	s style
+}
+
+// markdownPreview is a formatted version of a Markdown file, which is shown in
+// the right half of the screen while it's edited.
+var markdownPreview struct {
+	shown bool
+	lines [][]previewCell
+	// rowStart contains the index in lines of the first line for each row, so
+	// that the preview scrolls along with the file.
+	rowStart []int
+	// stale is set when the preview needs to be formatted again before it's
+	// drawn.
+	stale bool
+}
+
+func init() {
+	bufferOnChange(func(bufferChange) { markdownPreview.stale = true })
+}
+
+// editorTogglePreview shows or hides the preview of a Markdown file.
+func editorTogglePreview() {
+	if !markdownPreview.shown && (e.syntax == nil || e.syntax.fileType != "markdown") {
+		editorSetStatusMessage("Only Markdown files can be previewed")
+		return
+	}
+
+	markdownPreview.shown = !markdownPreview.shown
+	markdownPreview.stale = true
+}
+
+// editorPreviewShown returns whether the preview is being displayed. It's
+// hidden when switching to a file which isn't Markdown.
+func editorPreviewShown() bool {
+	return markdownPreview.shown && e.syntax != nil && e.syntax.fileType == "markdown"
+}
+
+// editorPreviewWidth returns the number of columns at the right of the screen
+// used by the preview, including the line separating it from the file.
+func editorPreviewWidth() int {
+	if !editorPreviewShown() {
+		return 0
+	}
+
+	return e.screenCols / 2
+}
+
+// editorUpdatePreview formats the preview again if the file has changed.
+func editorUpdatePreview() {
+	if !editorPreviewShown() || !markdownPreview.stale {
+		return
+	}
+	markdownPreview.stale = false
+
+	// Leave room for the separator and a space after it.
+	width := max(editorPreviewWidth()-2, 1)
+
+	p := &markdownPreview
+	p.lines = p.lines[:0]
+	p.rowStart = p.rowStart[:0]
+
+	inFence := false
+	var fenceSyntax *editorSyntax
+	for _, row := range e.row {
+		p.rowStart = append(p.rowStart, len(p.lines))
+
+		trimmed := strings.TrimLeft(row.render, " ")
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			fenceSyntax = syntaxForFence(strings.TrimSpace(trimmed[3:]))
+			continue
+		}
+
+		var line []previewCell
+		if inFence {
+			line = previewCode(row.render, fenceSyntax)
+		} else {
+			line = previewMarkdownLine(row.render, width)
+		}
+
+		p.lines = append(p.lines, wrapPreviewLine(line, width)...)
+	}
+}
+
+// syntaxForFence returns the syntax for the language named in the info
+// string of a fenced code block, e.g. "go" for ```go.
+func syntaxForFence(lang string) *editorSyntax {
+	if lang == "" {
+		return nil
+	}
+
+	for i := range highlightDB {
+		if highlightDB[i].fileType == lang {
+			return &highlightDB[i]
+		}
+	}
+
+	return syntaxForFilename("." + lang)
+}
+
+// previewCode formats a line of a fenced code block, highlighted as code of
+// the given syntax.
+func previewCode(text string, syntax *editorSyntax) []previewCell {
+	line := previewText("  ", style{})
+
+	hl := make([]editorHighlight, len(text))
+	// File types with their own highlighting rules may look at other rows, so
+	// only the common rules are used.
+	if syntax != nil && syntax.highlightRow == nil {
+		row := editorRow{idx: -1, raw: text, render: text, highlight: hl}
+
+		saved := e.syntax
+		e.syntax = syntax
+		highlightCode(&row)
+		e.syntax = saved
+	}
+
+	for i, r := range text {
+		line = append(line, previewCell{r, editorSyntaxToStyle(hl[i])})
+	}
+	return line
+}
+
+// previewMarkdownLine formats a line of Markdown outside of code blocks.
+func previewMarkdownLine(text string, width int) []previewCell {
+	t := editorCurrentTheme()
+	trimmed := strings.TrimLeft(text, " ")
+	indent := text[:len(text)-len(trimmed)]
+
+	if isMarkdownHeading(trimmed) {
+		s := editorSyntaxToStyle(highlightKeyword1)
+		s.underline = true
+		return previewText(strings.TrimLeft(trimmed, "# "), s)
+	}
+
+	if isMarkdownRule(trimmed) {
+		return previewText(strings.Repeat("─", width), style{fg: t.whitespace})
+	}
+
+	var line []previewCell
+	if quote, ok := strings.CutPrefix(trimmed, ">"); ok {
+		line = previewText(indent+"│ ", style{fg: t.virtualText})
+		return append(line, previewInline(strings.TrimPrefix(quote, " "))...)
+	}
+
+	line = previewText(indent, style{})
+	if n := markdownBulletLen(trimmed); n > 0 {
+		bullet := trimmed[:n]
+		if strings.IndexByte("-*+", bullet[0]) >= 0 {
+			bullet = "• "
+		}
+		line = append(line, previewText(bullet, editorSyntaxToStyle(highlightKeyword2))...)
+		trimmed = trimmed[n:]
+	}
+
+	return append(line, previewInline(trimmed)...)
+}
+
+// isMarkdownRule returns whether s is a thematic break, like "---".
+func isMarkdownRule(s string) bool {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	return len(s) >= 3 && strings.IndexByte("-*_", s[0]) >= 0 && strings.Count(s, s[:1]) == len(s)
+}
+
+// previewInline formats the inline elements of Markdown in s, removing the
+// markers around them.
+func previewInline(s string) []previewCell {
+	var line []previewCell
+
+	i := 0
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		case '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				end += i + 1
+				line = append(line, previewText(s[i+1:end], editorSyntaxToStyle(highlightString))...)
+				i = end + 1
+				continue
+			}
+		case '*', '_':
+			if end := markdownEmphasisEnd(s, i); end > i {
+				marker := 1
+				if end-i >= 4 && s[i+1] == s[i] {
+					marker = 2
+				}
+				line = append(line, previewText(s[i+marker:end-marker], editorSyntaxToStyle(highlightEmphasis))...)
+				i = end
+				continue
+			}
+		case '[':
+			if end := markdownLinkEnd(s, i); end > i {
+				text := s[i+1 : i+strings.IndexByte(s[i:], ']')]
+				linkStyle := editorSyntaxToStyle(highlightLink)
+				linkStyle.underline = true
+				line = append(line, previewText(text, linkStyle)...)
+				i = end
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		line = append(line, previewCell{r, style{}})
+		i += size
+	}
+
+	return line
+}
+
+// previewText returns the cells for s, all with the same style.
+func previewText(s string, st style) []previewCell {
+	cells := make([]previewCell, 0, len(s))
+	for _, r := range s {
+		cells = append(cells, previewCell{r, st})
+	}
+	return cells
+}
+
+// wrapPreviewLine splits line into lines which are at most width characters
+// long, breaking at spaces where possible.
+func wrapPreviewLine(line []previewCell, width int) [][]previewCell {
+	var lines [][]previewCell
+	for len(line) > width {
+		split := width
+		for i := width; i > 0; i-- {
+			if line[i].r == ' ' {
+				split = i
+				break
+			}
+		}
+
+		lines = append(lines, line[:split])
+		line = line[split:]
+		for len(line) > 0 && line[0].r == ' ' {
+			line = line[1:]
+		}
+	}
+
+	return append(lines, line)
+}
+
+// editorDrawPreviewLine draws the line of the preview which is next to the row
+// at index y of the screen.
+func editorDrawPreviewLine(w io.Writer, y int) {
+	if !editorPreviewShown() {
+		return
+	}
+
+	p := &markdownPreview
+	fmt.Fprintf(w, "\x1b[%dG", e.screenCols-editorPreviewWidth()+1)
+	fmt.Fprint(w, "\x1b[49m")
+	fmt.Fprint(w, editorCurrentTheme().whitespace.fgSGR())
+	fmt.Fprint(w, "│ ")
+
+	i := -1
+	if e.rowOffset < len(p.rowStart) {
+		i = p.rowStart[e.rowOffset] + y
+	}
+	if i >= 0 && i < len(p.lines) {
+		current := style{fg: editorCurrentTheme().whitespace}
+		for _, c := range p.lines[i] {
+			if c.s != current {
+				fmt.Fprint(w, c.s.sgr())
+				current = c.s
+			}
+			fmt.Fprint(w, string(c.r))
+		}
+	}
+
+	fmt.Fprint(w, "\x1b[24;39;49m")
+	fmt.Fprint(w, "\x1b[K")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressInterval is the minimum amount of time between redraws of the
+// message bar caused by progress updates.
+const progressInterval = 100 * time.Millisecond
+
+var progress struct {
+	sync.Mutex
+
+	message string
+	pending bool
+
+	lastDraw time.Time
+}
+
+// editorPostProgress reports the progress of a background job, e.g. "grep: 4213
+// files scanned". It's safe to call from any goroutine, and as often as
+// needed, since only the latest message is kept and the message bar is
+// redrawn at most once every progressInterval.
+func editorPostProgress(format string, a ...any) {
+	message := fmt.Sprintf(format, a...)
+
+	progress.Lock()
+	defer progress.Unlock()
+
+	progress.message = message
+	progress.pending = true
+}
+
+// editorTakeProgress sets the status message to the latest progress update
+// which hasn't been displayed yet. It returns false when there wasn't one.
+func editorTakeProgress() bool {
+	progress.Lock()
+	defer progress.Unlock()
+
+	if !progress.pending {
+		return false
+	}
+
+	progress.pending = false
+	progress.lastDraw = time.Now()
+	// Progress is shown frequently and goes out of date, so it isn't logged.
+	editorSetPromptMessage("%s", progress.message)
+
+	return true
+}
+
+// editorDiscardProgress drops the progress update which hasn't been displayed
+// yet, so that it doesn't replace a newer status message, e.g. the result of
+// the job.
+func editorDiscardProgress() {
+	progress.Lock()
+	defer progress.Unlock()
+
+	progress.pending = false
+}
+
+// editorFlushProgress redraws only the message bar if there is a new progress
+// update to display. It's called while waiting for input so that updates are
+// displayed without interfering with typing.
+func editorFlushProgress() {
+	if inPrompt {
+		// Don't overwrite the prompt in the message bar.
+		return
+	}
+
+	progress.Lock()
+	tooSoon := time.Since(progress.lastDraw) < progressInterval
+	progress.Unlock()
+	if tooSoon || !editorTakeProgress() {
+		return
+	}
+
+	buf := bufio.NewWriter(os.Stdout)
+
+	// Save the cursor position, and restore it once done
+	fmt.Fprint(buf, "\x1b7")
+	fmt.Fprintf(buf, "\x1b[%d;1H", e.screenRows+2)
+	editorDrawMessageBar(buf)
+	fmt.Fprint(buf, "\x1b8")
+
+	buf.Flush()
+}
+
+// posted contains functions which background jobs have asked to run on the
+// main goroutine, since that's the only one which may access the editor's
+// state.
+var posted struct {
+	sync.Mutex
+
+	funcs []func()
+}
+
+// editorPostToMain arranges for f to be run on the main goroutine while it's
+// waiting for input. It's safe to call from any goroutine.
+func editorPostToMain(f func()) {
+	posted.Lock()
+	defer posted.Unlock()
+
+	posted.funcs = append(posted.funcs, f)
+}
+
+// editorRunPosted runs the functions passed to editorPostToMain since it was
+// last called. It returns whether there were any, in which case the screen
+// needs to be refreshed.
+func editorRunPosted() bool {
+	posted.Lock()
+	funcs := posted.funcs
+	posted.funcs = nil
+	posted.Unlock()
+
+	for _, f := range funcs {
+		f()
+	}
+
+	return len(funcs) > 0
+}
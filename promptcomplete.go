@@ -0,0 +1,142 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// promptCompletion is the state of Tab completion in a prompt. Pressing Tab
+// completes as much of the input as all of the candidates share, and shows
+// them in the message bar. Pressing it again cycles through them.
+type promptCompletion struct {
+	// complete returns the inputs which the input can be completed to.
+	complete func(input string) []string
+
+	candidates []string
+	// chosen is the index of the candidate in the input, or -1 before
+	// cycling through them has started.
+	chosen int
+}
+
+// next returns the input completed by pressing Tab, or Shift-Tab when dir is
+// -1.
+func (c *promptCompletion) next(input string, dir int) string {
+	if len(c.candidates) > 0 {
+		if c.chosen < 0 && dir < 0 {
+			c.chosen = 0
+		}
+		c.chosen = (c.chosen + dir + len(c.candidates)) % len(c.candidates)
+		return c.candidates[c.chosen]
+	}
+
+	candidates := c.complete(input)
+	switch len(candidates) {
+	case 0:
+		return input
+	case 1:
+		return candidates[0]
+	}
+
+	c.candidates = candidates
+	c.chosen = -1
+	if prefix := commonPrefix(candidates); len(prefix) > len(input) {
+		return prefix
+	}
+	return input
+}
+
+// reset stops cycling through the candidates, e.g. after the input is edited.
+func (c *promptCompletion) reset() {
+	c.candidates = nil
+}
+
+// String returns the candidates to display after the input, with the chosen
+// one in brackets. Only the part of each one after the completed word is shown,
+// e.g. the name of a file without its directory.
+func (c *promptCompletion) String() string {
+	if len(c.candidates) == 0 {
+		return ""
+	}
+
+	prefix := commonPrefix(c.candidates)
+	wordStart := strings.LastIndexAny(prefix, "/ ") + 1
+
+	var b strings.Builder
+	b.WriteString("  ")
+	for i, candidate := range c.candidates {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if i == c.chosen {
+			b.WriteString("[" + candidate[wordStart:] + "]")
+		} else {
+			b.WriteString(candidate[wordStart:])
+		}
+	}
+
+	return b.String()
+}
+
+// commonPrefix returns the longest prefix shared by all of the strings in ss.
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		n := 0
+		for n < len(prefix) && n < len(s) && prefix[n] == s[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+
+	return prefix
+}
+
+// completeWords returns the words which start with the last word of input,
+// each appended to the rest of input.
+func completeWords(input string, words []string) []string {
+	start := strings.LastIndexByte(input, ' ') + 1
+
+	var completions []string
+	for _, word := range words {
+		if strings.HasPrefix(word, input[start:]) {
+			completions = append(completions, input[:start]+word)
+		}
+	}
+
+	return completions
+}
+
+// pathCommands are the commands whose argument is the path of a file.
+var pathCommands = []string{"open", "insert-file", "export-html", "r"}
+
+// completeCommand completes the name of a command in the command prompt, or
+// its argument for the commands which take the name of an option, a theme, or
+// a file.
+func completeCommand(input string) []string {
+	name, args, found := strings.Cut(input, " ")
+	if !found {
+		var names []string
+		for _, cmd := range editorCommands {
+			names = append(names, cmd.name)
+		}
+		return completeWords(input, names)
+	}
+
+	var completions []string
+	switch {
+	case name == "theme" || strings.HasPrefix(args, "theme "):
+		completions = completeWords(input, themeNames())
+	case name == "set" && !strings.Contains(args, " "):
+		var names []string
+		for _, opt := range editorOptions {
+			names = append(names, opt.name)
+		}
+		completions = completeWords(input, names)
+	case slices.Contains(pathCommands, name):
+		for _, path := range completePath(args) {
+			completions = append(completions, name+" "+path)
+		}
+	}
+
+	return completions
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// quickfixList contains the errors reported by the last compile command.
+var quickfixList []location
+
+// quickfixIndex is the index of the error in quickfixList which was last jumped
+// to, or -1 before jumping to any.
+var quickfixIndex = -1
+
+// editorBuildCommand returns the shell command run by the compile command.
+// make is used when there isn't one for the file type.
+func editorBuildCommand() string {
+	if e.buildCommand != "" {
+		return e.buildCommand
+	}
+	if e.syntax != nil && e.syntax.buildCommand != "" {
+		return e.syntax.buildCommand
+	}
+
+	return "make"
+}
+
+// editorCompile runs the build command given in args, or the buildcommand
+// option when there isn't one, and collects the errors in its output which
+// refer to positions in files. When there are any, they're listed so that one
+// can be jumped to. Alt-e and Alt-E move through them afterwards.
+func editorCompile(args string) {
+	command := args
+	if command == "" {
+		command = editorBuildCommand()
+	}
+
+	editorSetPromptMessage("Running %s...", command)
+	editorRefreshScreen()
+
+	quickfixList = nil
+	quickfixIndex = -1
+	err := editorRunWithProgress(command, func(line string) {
+		if loc, ok := parseLocation(strings.TrimSpace(line)); ok {
+			quickfixList = append(quickfixList, loc)
+		}
+	})
+
+	status := "succeeded"
+	if err != nil {
+		status = err.Error()
+	}
+
+	if len(quickfixList) == 0 {
+		editorSetStatusMessage("%s: %s", command, status)
+		return
+	}
+
+	editorListErrors()
+}
+
+// editorRunWithProgress runs the shell command, and passes each line of its
+// output to handle as it's written. The number of lines and errors so far are
+// shown as progress, since builds can take a while.
+func editorRunWithProgress(command string, handle func(line string)) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Start()
+	// The command has its own copy of w, so the pipe is closed once it exits.
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	lines := 0
+	for scanner.Scan() {
+		handle(scanner.Text())
+
+		lines++
+		editorPostProgress("Running %s: %d lines of output, %d errors", command, lines, len(quickfixList))
+		editorFlushProgress()
+	}
+
+	return cmd.Wait()
+}
+
+// editorListErrors shows the errors from the last compile command, and jumps
+// to the one which is picked.
+func editorListErrors() {
+	if len(quickfixList) == 0 {
+		editorSetStatusMessage("No errors")
+		return
+	}
+
+	lines := make([]string, len(quickfixList))
+	for i, loc := range quickfixList {
+		lines[i] = formatLocation(loc)
+	}
+
+	title := fmt.Sprintf("%d errors", len(quickfixList))
+	if i, ok := editorPickFromOverlay(title, lines); ok {
+		editorGoToError(i)
+	}
+}
+
+// editorNextError jumps to the error dir places after the one last jumped to
+// in the list from the compile command.
+func editorNextError(dir int) {
+	if len(quickfixList) == 0 {
+		editorSetStatusMessage("No errors")
+		return
+	}
+
+	i := quickfixIndex + dir
+	if quickfixIndex < 0 && dir < 0 {
+		i = len(quickfixList) - 1
+	}
+	if i < 0 || i >= len(quickfixList) {
+		editorSetStatusMessage("No more errors")
+		return
+	}
+
+	editorGoToError(i)
+}
+
+// editorGoToError opens the file containing the error at index i of the list
+// from the compile command, and moves the cursor to it.
+func editorGoToError(i int) {
+	loc := quickfixList[i]
+	from := editorCurrentJump()
+	if !editorSwitchFile(loc.path) {
+		return
+	}
+
+	quickfixIndex = i
+
+	editorPushJump(from)
+	editorGoToPos(bufferPos{max(loc.line-1, 0), max(loc.col-1, 0)})
+	editorSetStatusMessage("[%d/%d] %s", i+1, len(quickfixList), loc.message)
+}
+
+// formatLocation formats loc in the form it's usually given in, e.g.
+// file.go:12:5: message.
+func formatLocation(loc location) string {
+	if loc.col == 0 {
+		return fmt.Sprintf("%s:%d: %s", loc.path, loc.line, loc.message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", loc.path, loc.line, loc.col, loc.message)
+}
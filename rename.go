@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// editorRename asks the language server to rename the symbol under the cursor
+// to newName, prompting for it when it's empty, and applies the changes. All
+// of them, including those to other files, are undone together.
+func editorRename(newName string) {
+	if !editorLSPReady() {
+		return
+	}
+
+	if newName == "" {
+		var current string
+		if start, end, ok := editorWordUnderCursor(); ok {
+			current = bufferText(start, end)
+		}
+
+		newName = editorPromptDefault("Rename to: %s", current, func(string, rune) {})
+		if newName == "" {
+			return
+		}
+	}
+
+	params := editorLSPTextDocumentPosition()
+	params["newName"] = newName
+
+	result, err := lsp.request("textDocument/rename", params)
+	if err != nil {
+		editorSetStatusMessage("Rename: %s", err.Error())
+		return
+	}
+
+	edits := parseWorkspaceEdit(result)
+	if len(edits) == 0 {
+		editorSetStatusMessage("Nothing to rename")
+		return
+	}
+
+	// The changes to other files are worked out before anything is changed,
+	// so that nothing is when one of them can't be.
+	count := 0
+	var openEdits []textEdit
+	var changes []fileChange
+	var failed []string
+	for uri, fileEdits := range edits {
+		count += len(fileEdits)
+
+		path := uriPath(uri)
+		if path == "" {
+			failed = append(failed, "can't edit non-file URI")
+			continue
+		}
+		if sameFile(path, e.filename) {
+			openEdits = convertFileEdits(path, fileEdits)
+			continue
+		}
+
+		change, err := renameFileChange(path, fileEdits)
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	if len(failed) > 0 {
+		editorSetStatusMessage("Rename failed: %s", strings.Join(failed, "; "))
+		return
+	}
+	if err := applyFileChanges(changes, false); err != nil {
+		editorSetStatusMessage("Rename failed: %s", err.Error())
+		return
+	}
+
+	for _, edit := range openEdits {
+		editorReplaceKeepingCursor(edit.start, edit.end, edit.newText)
+	}
+	undoRecordFileChanges(changes)
+
+	editorSetStatusMessage("Renamed %d occurrences in %d files", count, len(edits))
+}
+
+// parseWorkspaceEdit returns the edits in a workspace edit from the language
+// server, by the URI of the file they apply to. They're given either as a map
+// of changes, or as a list of document changes.
+func parseWorkspaceEdit(result json.RawMessage) map[string][]lspTextEdit {
+	var edit struct {
+		Changes         map[string][]lspTextEdit `json:"changes"`
+		DocumentChanges []struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Edits []lspTextEdit `json:"edits"`
+		} `json:"documentChanges"`
+	}
+	if err := json.Unmarshal(result, &edit); err != nil {
+		return nil
+	}
+
+	edits := edit.Changes
+	if edits == nil {
+		edits = make(map[string][]lspTextEdit)
+	}
+	for _, change := range edit.DocumentChanges {
+		uri := change.TextDocument.URI
+		edits[uri] = append(edits[uri], change.Edits...)
+	}
+
+	return edits
+}
+
+// convertFileEdits converts edits from the language server to the file at path
+// into positions in the buffer, sorted from the bottom of the file so that
+// making them in order keeps the positions of the later ones valid.
+func convertFileEdits(path string, edits []lspTextEdit) []textEdit {
+	lineText := fileLineText(path)
+	converted := make([]textEdit, len(edits))
+	for i, edit := range edits {
+		converted[i] = textEdit{
+			start:   lsp.fromLSP(edit.Range.Start, lineText),
+			end:     lsp.fromLSP(edit.Range.End, lineText),
+			newText: edit.NewText,
+		}
+	}
+
+	slices.SortStableFunc(converted, func(a, b textEdit) int {
+		switch {
+		case a.start.before(b.start):
+			return 1
+		case b.start.before(a.start):
+			return -1
+		}
+		return 0
+	})
+
+	return converted
+}
+
+// fileChange is a change to a file other than the open one, made by renaming a
+// symbol. It's undone by writing the old contents of the file back.
+type fileChange struct {
+	path             string
+	oldText, newText string
+}
+
+// renameFileChange returns the change which makes edits from the language
+// server to the file at path, without making it.
+func renameFileChange(path string, edits []lspTextEdit) (fileChange, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return fileChange{}, err
+	}
+
+	lines := strings.Split(string(bb), "\n")
+	for _, edit := range convertFileEdits(path, edits) {
+		lines = replaceInLines(lines, edit)
+	}
+
+	return fileChange{path: path, oldText: string(bb), newText: strings.Join(lines, "\n")}, nil
+}
+
+// applyFileChanges writes the new contents of the files in changes, or the old
+// ones when undo is true. When one of them can't be written, the ones which
+// were are changed back, so that either all of the files are changed or none
+// are.
+func applyFileChanges(changes []fileChange, undo bool) error {
+	for i, change := range changes {
+		from, to := change.oldText, change.newText
+		if undo {
+			from, to = to, from
+		}
+
+		if err := replaceFileContents(change.path, from, to); err != nil {
+			for _, written := range slices.Backward(changes[:i]) {
+				if undo {
+					replaceFileContents(written.path, written.oldText, written.newText)
+				} else {
+					replaceFileContents(written.path, written.newText, written.oldText)
+				}
+			}
+			return err
+		}
+
+		if lsp != nil {
+			lsp.notify("workspace/didChangeWatchedFiles", map[string]any{
+				"changes": []any{map[string]any{"uri": fileURI(change.path), "type": 2}},
+			})
+		}
+	}
+
+	return nil
+}
+
+// replaceFileContents writes to to the file at path, as long as it contains
+// from, so that changes made to it since the rename aren't lost.
+func replaceFileContents(path, from, to string) error {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(bb) != from {
+		return fmt.Errorf("%s has changed since the rename", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(to), info.Mode())
+}
+
+// replaceInLines applies edit to the text made up of lines.
+func replaceInLines(lines []string, edit textEdit) []string {
+	if edit.start.line >= len(lines) || edit.end.line >= len(lines) {
+		return lines
+	}
+
+	before := lines[edit.start.line][:edit.start.col]
+	after := lines[edit.end.line][edit.end.col:]
+	replacement := strings.Split(before+edit.newText+after, "\n")
+
+	return slices.Replace(lines, edit.start.line, edit.end.line+1, replacement...)
+}
@@ -0,0 +1,69 @@
+package main
+
+import "slices"
+
+// lastEdit contains the keys of the most recent edit, so that it can be
+// repeated.
+var lastEdit []rune
+
+// recordingEdit indicates whether the previous key was part of the edit in
+// lastEdit, so that following keys are added to it.
+var recordingEdit bool
+
+// replayingEdit indicates whether the keys being handled come from repeating
+// lastEdit.
+var replayingEdit bool
+
+// keyLog contains the keys read while handling the current key press,
+// including ones read by prompts, so that they can be repeated.
+var keyLog []rune
+
+// bufferChangeCount is incremented every time the buffer changes.
+var bufferChangeCount int
+
+// unrepeatableKeys change the buffer, but aren't edits which make sense to
+// repeat.
+var unrepeatableKeys = []rune{ctrl('z'), ctrl('r'), ctrl('s'), alt('r')}
+
+func init() {
+	bufferOnChange(func(bufferChange) {
+		bufferChangeCount++
+	})
+}
+
+// editorRecordEdit is called after handling each key press. Consecutive key
+// presses which change the buffer are recorded together as one edit, which
+// ends when a key which doesn't (e.g. an arrow key) is pressed. changesBefore
+// is the value of bufferChangeCount before the key was handled.
+func editorRecordEdit(key rune, changesBefore int) {
+	if replayingEdit {
+		return
+	}
+
+	if bufferChangeCount == changesBefore || slices.Contains(unrepeatableKeys, key) {
+		recordingEdit = false
+		return
+	}
+
+	if !recordingEdit {
+		lastEdit = nil
+		recordingEdit = true
+	}
+	lastEdit = append(lastEdit, keyLog...)
+}
+
+// editorRepeatEdit repeats the most recent edit at the cursor.
+func editorRepeatEdit() {
+	if len(lastEdit) == 0 {
+		editorSetStatusMessage("No edit to repeat")
+		return
+	}
+
+	replayingEdit = true
+	defer func() { replayingEdit = false }()
+
+	pendingKeys = append(pendingKeys, lastEdit...)
+	for len(pendingKeys) > 0 {
+		editorProcessKeypress()
+	}
+}
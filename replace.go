@@ -0,0 +1,254 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// replaceMatch is an occurrence of the text being replaced.
+type replaceMatch struct {
+	start, end  bufferPos
+	replacement string
+}
+
+// editorReplace prompts for text to find and what to replace it with, then
+// steps through each occurrence, asking whether to replace it. Only the
+// occurrences in the selection are replaced when there is one.
+//
+// When regex is set, the text to find is a regular expression, and the
+// replacement can refer to the groups in it.
+func editorReplace(regex bool) {
+	// limit is the position that occurrences have to end before.
+	pos, limit := bufferPos{0, 0}, bufferPos{len(e.row), 0}
+	initial := editorSearchDefault()
+	scope := ""
+	if start, end, ok := editorSelection(); ok {
+		pos, limit = start, end
+		initial = ""
+		scope = " in the selection"
+	}
+
+	what := "Replace"
+	if regex {
+		what = "Replace regex"
+		initial = regexp.QuoteMeta(initial)
+	}
+	query := editorPromptDefault(what+scope+": %s", initial, func(string, rune) {})
+	editorClearSelection()
+	if query == "" {
+		return
+	}
+
+	prompt := "Replace " + strings.ReplaceAll(query, "%", "%%") + " with: %s"
+	var re *regexp.Regexp
+	if regex {
+		var err error
+		// The regex runs over the whole text, so multi-line mode keeps ^ and $
+		// matching at the start and end of each row.
+		if re, err = regexp.Compile("(?m)" + query); err != nil {
+			editorSetStatusMessage("Invalid regex: %s", err.Error())
+			return
+		}
+		prompt = "Replace with (\\1 or $1 = group, \\$ = $, \\\\ = \\): %s"
+	}
+
+	template, ok := editorPromptAllowingEmpty(prompt)
+	if !ok {
+		return
+	}
+
+	if regex {
+		template = regexReplacement(template)
+	}
+	search := newReplaceSearch(query, re, template, pos)
+
+	editorRecordJump()
+	replaced, total := 0, 0
+	all := false
+	for {
+		m, found := search.next()
+		if !found || limit.before(m.end) {
+			break
+		}
+		total++
+
+		if !all {
+			// Select the occurrence to highlight it.
+			e.selecting = true
+			e.anchor = m.start
+			e.cy, e.cx = m.end.line, m.end.col
+			editorScrollTo(m.start.line, m.start.col, scrollCenterIfHidden)
+
+			answer := editorAskReplace()
+			editorClearSelection()
+			if answer == 'q' {
+				break
+			} else if answer == 'n' {
+				continue
+			}
+			all = answer == 'a'
+		}
+
+		end := bufferReplaceRange(m.start, m.end, m.replacement)
+		search.replaced(end)
+		limit = limit.adjust(bufferChange{start: m.start, end: m.end, newText: m.replacement})
+		e.cy, e.cx = m.start.line, m.start.col
+		replaced++
+	}
+
+	editorSetStatusMessage("Replaced %d of %d occurrences%s", replaced, total, scope)
+}
+
+// replaceSearch finds the occurrences to replace in the text of the buffer from
+// when the replacement started, so that the text is only built, and a regex
+// only run over it, once. Every replacement is before the occurrences which
+// are still to be found, so their positions only need to be moved by where
+// the end of the last replacement ended up.
+type replaceSearch struct {
+	t searchText
+	// from is the offset in t to find the next occurrence from.
+	from int
+
+	query string
+	// When re is set, matches are its matches in t which haven't been found
+	// yet, and template is expanded with their groups.
+	re       *regexp.Regexp
+	matches  [][]int
+	template string
+
+	// found is the end of the last occurrence found, in t.
+	found bufferPos
+	// oldEnd is the end of the last occurrence replaced, in t, and newEnd is
+	// the end of its replacement in the buffer.
+	oldEnd, newEnd bufferPos
+}
+
+// newReplaceSearch returns a search for the occurrences of query at or after
+// pos, or of re when it isn't nil.
+func newReplaceSearch(query string, re *regexp.Regexp, template string, pos bufferPos) *replaceSearch {
+	s := &replaceSearch{t: newSearchText(), query: query, re: re, template: template}
+	s.from = s.t.offset(pos)
+	if re != nil {
+		// The whole text is matched, rather than the text after pos, so that
+		// anchors like ^ still refer to the start of the row.
+		s.matches = re.FindAllStringSubmatchIndex(s.t.text, -1)
+		for len(s.matches) > 0 && s.matches[0][0] < s.from {
+			s.matches = s.matches[1:]
+		}
+	}
+	return s
+}
+
+// next returns the next occurrence, with its position in the buffer.
+func (s *replaceSearch) next() (replaceMatch, bool) {
+	start, end, replacement := 0, 0, s.template
+	if s.re != nil {
+		if len(s.matches) == 0 {
+			return replaceMatch{}, false
+		}
+		match := s.matches[0]
+		s.matches = s.matches[1:]
+		start, end = match[0], match[1]
+		replacement = string(s.re.ExpandString(nil, s.template, s.t.text, match))
+	} else {
+		idx := strings.Index(s.t.text[s.from:], s.query)
+		if idx < 0 {
+			return replaceMatch{}, false
+		}
+		start = s.from + idx
+		end = start + len(s.query)
+		s.from = end
+	}
+
+	s.found = s.t.pos(end)
+	return replaceMatch{s.bufferPos(s.t.pos(start)), s.bufferPos(s.found), replacement}, true
+}
+
+// replaced records that the last occurrence found was replaced, and that the
+// replacement ends at end in the buffer.
+func (s *replaceSearch) replaced(end bufferPos) {
+	s.oldEnd, s.newEnd = s.found, end
+}
+
+// bufferPos returns where pos in t is in the buffer, which is after all of
+// the replacements.
+func (s *replaceSearch) bufferPos(pos bufferPos) bufferPos {
+	if pos.line == s.oldEnd.line {
+		return bufferPos{s.newEnd.line, s.newEnd.col + pos.col - s.oldEnd.col}
+	}
+	return bufferPos{pos.line + s.newEnd.line - s.oldEnd.line, pos.col}
+}
+
+// editorAskReplace asks whether to replace the selected occurrence, and
+// returns y, n, a (for all of the rest), or q.
+func editorAskReplace() rune {
+	for {
+		editorSetPromptMessage("Replace? (y)es, (n)o, (a)ll, or (q)uit")
+		editorRefreshScreen()
+
+		switch c := editorReadKey(); c {
+		case 'y', 'n', 'a', 'q':
+			return c
+		case '\x1b':
+			return 'q'
+		}
+	}
+}
+
+// editorPromptAllowingEmpty prompts for input like editorPrompt, except that
+// it returns whether the input was entered, rather than the prompt being
+// cancelled, so that empty input can be entered.
+func editorPromptAllowingEmpty(prompt string) (input string, ok bool) {
+	input = editorPrompt(prompt, func(_ string, key rune) {
+		ok = key == '\r'
+	})
+	return input, ok
+}
+
+// regexReplacement converts a replacement which refers to groups like \1 or $1
+// into the form used by regexp.Expand, which refers to them like ${1}. \n and
+// \t stand for a newline and a tab, \\ for a backslash, and \$ for a dollar
+// sign, which is only needed before a number. Any other $ is kept as it is.
+func regexReplacement(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '$' {
+			// Put the number of a group in braces so that it isn't combined
+			// with the text after it, e.g. $1a.
+			digits := i + 1
+			for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+				digits++
+			}
+			if digits > i+1 {
+				b.WriteString("${" + s[i+1:digits] + "}")
+				i = digits - 1
+				continue
+			}
+
+			// Anything else, like $name, would be expanded as a group which
+			// doesn't exist, rather than being left as it is.
+			b.WriteString("$$")
+			continue
+		}
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			b.WriteString("${" + string(c) + "}")
+		case c == 'n':
+			b.WriteByte('\n')
+		case c == 't':
+			b.WriteByte('\t')
+		case c == '$':
+			b.WriteString("$$")
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// editorSaveHooks are run in order before the file is written to clean up its
+// contents. Each one checks whether it's enabled, so that they can be
+// configured per file type.
+var editorSaveHooks = []func() error{
+	editorTrimTrailingWhitespace,
+	editorFormatOnSave,
+	editorFixFinalNewline,
+}
+
+// editorRunSaveHooks runs every hook in editorSaveHooks, even if some of them
+// fail, and returns their errors.
+func editorRunSaveHooks() error {
+	var errs []error
+	for _, hook := range editorSaveHooks {
+		if err := hook(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// editorTrimTrailingWhitespace removes spaces and tabs from the end of every
+// row when the trimwhitespace option is on.
+func editorTrimTrailingWhitespace() error {
+	if !e.trimTrailingWhitespace {
+		return nil
+	}
+
+	for i := range e.row {
+		raw := e.row[i].raw
+		trimmed := len(strings.TrimRight(raw, " \t"))
+		if trimmed < len(raw) {
+			bufferDeleteRange(bufferPos{i, trimmed}, bufferPos{i, len(raw)})
+		}
+	}
+
+	if e.cy < len(e.row) {
+		e.cx = min(e.cx, len(e.row[e.cy].raw))
+	}
+
+	return nil
+}
+
+// editorFormatOnSave runs the file through its formatter when the formatonsave
+// option is on and there is one.
+func editorFormatOnSave() error {
+	if !e.formatOnSave || editorFormatter() == "" {
+		return nil
+	}
+
+	return editorFormat()
+}
+
+// editorFixFinalNewline removes empty rows from the end of the file when the
+// finalnewline option is on, so that it ends with exactly one newline.
+func editorFixFinalNewline() error {
+	if !e.fixFinalNewline {
+		return nil
+	}
+
+	last := len(e.row)
+	for last > 0 && e.row[last-1].raw == "" {
+		last--
+	}
+
+	if last == len(e.row) {
+		return nil
+	}
+
+	bufferDeleteRange(bufferPos{last, 0}, bufferPos{len(e.row), 0})
+
+	if e.cy > len(e.row) {
+		e.cy = len(e.row)
+		e.cx = 0
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// editorScrollViewport scrolls the screen by dir rows without moving the
+// cursor, unless it would go off of the screen.
+func editorScrollViewport(dir int) {
+	e.rowOffset = max(0, min(e.rowOffset+dir, len(e.row)-1))
+
+	// Keep the cursor outside of the margins, otherwise editorScroll would
+	// undo the scrolling.
+	margin := editorScrollMargin()
+	if e.rowOffset == 0 {
+		margin = 0
+	}
+	if e.cy < e.rowOffset+margin {
+		e.cy = min(e.rowOffset+margin, len(e.row))
+	}
+	if e.cy >= e.rowOffset+e.screenRows-margin {
+		e.cy = e.rowOffset + e.screenRows - 1 - margin
+	}
+
+	editorClampCursor()
+}
+
+// scrollPosition is where editorScrollTo puts a row on the screen.
+type scrollPosition int
+
+const (
+	// scrollMinimal scrolls as little as possible to show the row.
+	scrollMinimal scrollPosition = iota
+	// scrollCenterIfHidden leaves the screen as it is when the row is already
+	// on it, and otherwise puts it in the middle, so that there's context
+	// around it.
+	scrollCenterIfHidden
+	scrollMiddle
+	scrollTop
+	scrollBottom
+)
+
+// editorScrollTo scrolls so that the given position in the buffer is on the
+// screen, with its row at position.
+func editorScrollTo(line, col int, position scrollPosition) {
+	hidden := line < e.rowOffset || line >= e.rowOffset+e.screenRows
+	if position == scrollCenterIfHidden {
+		position = scrollMinimal
+		if hidden {
+			position = scrollMiddle
+		}
+	}
+
+	margin := editorScrollMargin()
+	switch position {
+	case scrollMinimal:
+		if line < e.rowOffset+margin {
+			e.rowOffset = line - margin
+		} else if line >= e.rowOffset+e.screenRows-margin {
+			e.rowOffset = line - e.screenRows + 1 + margin
+		}
+	case scrollMiddle:
+		e.rowOffset = line - e.screenRows/2
+	case scrollTop:
+		e.rowOffset = line - margin
+	case scrollBottom:
+		e.rowOffset = line - e.screenRows + 1 + margin
+	}
+	e.rowOffset = max(0, min(e.rowOffset, len(e.row)-1))
+
+	if line < len(e.row) {
+		editorScrollToColumn(editorRowCxToRx(e.row[line], min(col, len(e.row[line].raw))))
+	}
+}
+
+// editorScrollToColumn scrolls horizontally so that the render index rx is on
+// the screen, with sideScrollOff columns of context on either side of it. The
+// screen moves by at least sideScroll columns, or half of its width when
+// that's 0, so that moving along a long row doesn't scroll at every step.
+func editorScrollToColumn(rx int) {
+	cols := editorTextCols()
+	margin := max(min(e.sideScrollOff, (cols-1)/2), 0)
+	step := e.sideScroll
+	if step == 0 {
+		step = cols / 2
+	}
+
+	if rx < e.colOffset+margin {
+		offset := min(rx-margin, e.colOffset-step)
+		e.colOffset = max(offset, 0)
+	} else if rx >= e.colOffset+cols-margin {
+		e.colOffset = max(rx-cols+1+margin, e.colOffset+step)
+	}
+}
+
+// recenterPositions are the positions of the cursor row on the screen, in the
+// order that editorRecenter cycles through them.
+var recenterPositions = []scrollPosition{scrollMiddle, scrollTop, scrollBottom}
+
+// lastRecenter is where editorRecenter last scrolled to, so that pressing it
+// again without moving moves on to the next position.
+var lastRecenter struct {
+	cy, rowOffset int
+	// position is an index into recenterPositions.
+	position int
+	valid    bool
+}
+
+// editorRecenter scrolls so that the cursor row is in the middle of the
+// screen. Repeating it moves the row to the top, then the bottom.
+func editorRecenter() {
+	position := 0
+	if lastRecenter.valid && lastRecenter.cy == e.cy && lastRecenter.rowOffset == e.rowOffset {
+		position = (lastRecenter.position + 1) % len(recenterPositions)
+	}
+
+	editorScrollTo(e.cy, e.cx, recenterPositions[position])
+
+	lastRecenter.cy = e.cy
+	lastRecenter.rowOffset = e.rowOffset
+	lastRecenter.position = position
+	lastRecenter.valid = true
+}
+
+// editorScrollMargin returns the number of rows to keep visible above and below
+// the cursor. It's limited so that the cursor can still move on small screens.
+func editorScrollMargin() int {
+	return max(min(e.scrollOff, (e.screenRows-1)/2), 0)
+}
+
+// sideScrollOption returns an option which sets one of the horizontal
+// scrolling settings to a number from 0 to max.
+func sideScrollOption(name string, n *int, max int) editorOption {
+	return editorOption{
+		name: name,
+		set: func(value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 || parsed > max {
+				return fmt.Errorf("expected a number from 0 to %d, given %q", max, value)
+			}
+
+			*n = parsed
+			return nil
+		},
+		get: func() string {
+			return strconv.Itoa(*n)
+		},
+	}
+}
+
+func scrollOffOption() editorOption {
+	return editorOption{
+		name: "scrolloff",
+		set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 999 {
+				return fmt.Errorf("expected a number from 0 to 999, given %q", value)
+			}
+
+			e.scrollOff = n
+			return nil
+		},
+		get: func() string {
+			return strconv.Itoa(e.scrollOff)
+		},
+	}
+}
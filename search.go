@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// lastSearch is the most recent query which was searched for with Ctrl-F, so
+// that the search can be repeated.
+var lastSearch string
+
+// editorFindNext moves the cursor to the next match of lastSearch after it, or
+// the previous one before it when dir is -1. The search wraps around the ends
+// of the file unless wrapSearch is off.
+func editorFindNext(dir int) {
+	if lastSearch == "" {
+		editorSetStatusMessage("No previous search")
+		return
+	}
+
+	matches := bufferFindAll(lastSearch)
+	if len(matches) == 0 {
+		editorSetStatusMessage("Not found: %s", lastSearch)
+		return
+	}
+
+	cursor := bufferPos{e.cy, e.cx}
+	i := 0
+	wrapped := false
+	if dir > 0 {
+		for i < len(matches) && !cursor.before(matches[i][0]) {
+			i++
+		}
+		if i == len(matches) {
+			i = 0
+			wrapped = true
+		}
+		if wrapped && !e.wrapSearch {
+			editorSetStatusMessage("%s: no more matches below", lastSearch)
+			return
+		}
+	} else {
+		i = len(matches) - 1
+		for i >= 0 && !matches[i][0].before(cursor) {
+			i--
+		}
+		if i < 0 {
+			i = len(matches) - 1
+			wrapped = true
+		}
+		if wrapped && !e.wrapSearch {
+			editorSetStatusMessage("%s: no more matches above", lastSearch)
+			return
+		}
+	}
+
+	editorRecordJump()
+	editorGoToPos(matches[i][0])
+
+	message := "%s: match %d of %d"
+	if wrapped && dir > 0 {
+		message += " (wrapped to the top)"
+	} else if wrapped {
+		message += " (wrapped to the bottom)"
+	}
+	editorSetStatusMessage(message, lastSearch, i+1, len(matches))
+}
+
+// searchText is the contents of the buffer as a single string, so that
+// searches can find text which spans rows.
+type searchText struct {
+	text string
+	// rowStarts contains the offset in text of the start of each row.
+	rowStarts []int
+}
+
+func newSearchText() searchText {
+	var b strings.Builder
+	rowStarts := make([]int, len(e.row))
+	for i, row := range e.row {
+		rowStarts[i] = b.Len()
+		b.WriteString(row.raw)
+		b.WriteByte('\n')
+	}
+
+	return searchText{text: b.String(), rowStarts: rowStarts}
+}
+
+// offset returns the offset in the text of pos.
+func (t searchText) offset(pos bufferPos) int {
+	if pos.line >= len(t.rowStarts) {
+		return len(t.text)
+	}
+	return min(t.rowStarts[pos.line]+pos.col, len(t.text))
+}
+
+// pos returns the position in the buffer of offset. The newline after the last
+// row is treated as the end of it, since the buffer doesn't contain another
+// row for it to lead to.
+func (t searchText) pos(offset int) bufferPos {
+	line, found := slices.BinarySearch(t.rowStarts, offset)
+	if !found {
+		line--
+	}
+	if line < 0 {
+		return bufferPos{0, 0}
+	}
+
+	pos := bufferPos{line, offset - t.rowStarts[line]}
+	if rowLen := t.rowLen(line); pos.col > rowLen {
+		pos.col = rowLen
+	}
+	return pos
+}
+
+// rowLen returns the length of the row at line, without its newline.
+func (t searchText) rowLen(line int) int {
+	if line+1 < len(t.rowStarts) {
+		return t.rowStarts[line+1] - 1 - t.rowStarts[line]
+	}
+	return len(t.text) - 1 - t.rowStarts[line]
+}
+
+// bufferFindAll returns the ranges of the occurrences of query in the buffer,
+// in order. Occurrences don't overlap, and can span rows.
+func bufferFindAll(query string) [][2]bufferPos {
+	if query == "" {
+		return nil
+	}
+	t := newSearchText()
+
+	var matches [][2]bufferPos
+	for offset := 0; ; offset += len(query) {
+		idx := strings.Index(t.text[offset:], query)
+		if idx < 0 {
+			break
+		}
+		offset += idx
+		matches = append(matches, [2]bufferPos{t.pos(offset), t.pos(offset + len(query))})
+	}
+
+	return matches
+}
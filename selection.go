@@ -0,0 +1,219 @@
+package main
+
+func init() {
+	bufferOnChange(selectionOnChange)
+}
+
+// selectionRender is the selected range as positions in the render field of
+// rows. It's updated before drawing by editorUpdateSelectionRender.
+var selectionRender struct {
+	start, end renderPos
+
+	// left and right are the range of columns which are selected on each row
+	// when block is set.
+	block       bool
+	left, right int
+}
+
+// selectionOnChange keeps the anchor of the selection on the same text when
+// the buffer changes.
+func selectionOnChange(change bufferChange) {
+	if e.selecting {
+		e.anchor = e.anchor.adjust(change)
+	}
+}
+
+// editorExtendSelection moves the cursor for one of the shifted arrow keys,
+// starting a selection at the cursor if there isn't one.
+func editorExtendSelection(key rune) {
+	if !e.selecting {
+		e.selecting = true
+		e.anchor = bufferPos{e.cy, e.cx}
+	}
+	e.blockSelecting = false
+
+	switch key {
+	case shiftUp:
+		editorMoveCursor(arrowUp)
+	case shiftDown:
+		editorMoveCursor(arrowDown)
+	case shiftLeft:
+		editorMoveCursor(arrowLeft)
+	case shiftRight:
+		editorMoveCursor(arrowRight)
+	}
+}
+
+// editorExtendBlockSelection moves the cursor for one of the Alt-Shift-arrow
+// keys, starting a block selection at the cursor if there isn't one. A block
+// selection contains the same range of columns on each row between the anchor
+// and the cursor.
+func editorExtendBlockSelection(key rune) {
+	if !e.selecting {
+		e.selecting = true
+		e.anchor = bufferPos{e.cy, e.cx}
+	}
+	e.blockSelecting = true
+
+	switch key {
+	case blockUp:
+		editorMoveCursor(arrowUp)
+	case blockDown:
+		editorMoveCursor(arrowDown)
+	case blockLeft:
+		editorMoveCursor(arrowLeft)
+	case blockRight:
+		editorMoveCursor(arrowRight)
+	}
+}
+
+// blockColumns returns the range of columns, as indices into render, which
+// are part of the block selection.
+func blockColumns() (left, right int) {
+	anchorRx, cursorRx := 0, 0
+	if e.anchor.line < len(e.row) {
+		anchorRx = editorRowCxToRx(e.row[e.anchor.line], e.anchor.col)
+	}
+	if e.cy < len(e.row) {
+		cursorRx = editorRowCxToRx(e.row[e.cy], e.cx)
+	}
+
+	return min(anchorRx, cursorRx), max(anchorRx, cursorRx)
+}
+
+// editorDeleteBlockSelection deletes the text in the block selection, and puts
+// a cursor at the left edge of it on each row, so that typing inserts text on
+// all of them. It returns whether any text was deleted.
+func editorDeleteBlockSelection() bool {
+	left, right := blockColumns()
+	top, bottom := min(e.anchor.line, e.cy), max(e.anchor.line, e.cy)
+	bottom = min(bottom, len(e.row)-1)
+	primaryRow := e.cy
+
+	e.selecting = false
+	e.blockSelecting = false
+
+	deleted := false
+	var cursors []cursor
+	for y := top; y <= bottom; y++ {
+		row := e.row[y]
+		start := editorRowRxToCx(row, left)
+		end := editorRowRxToCx(row, right)
+		if end > start {
+			bufferDeleteRange(bufferPos{y, start}, bufferPos{y, end})
+			deleted = true
+		}
+
+		if y == primaryRow {
+			e.cy, e.cx = y, start
+		} else {
+			cursors = append(cursors, cursor{pos: bufferPos{y, start}})
+		}
+	}
+
+	e.cursors = append(e.cursors, cursors...)
+	return deleted
+}
+
+// editorClearSelection stops selecting without changing the buffer.
+func editorClearSelection() {
+	e.selecting = false
+	e.blockSelecting = false
+}
+
+// editorSelection returns the start and end of the selected text, and whether
+// there is any.
+func editorSelection() (start, end bufferPos, ok bool) {
+	if !e.selecting {
+		return bufferPos{}, bufferPos{}, false
+	}
+
+	start, end = e.anchor, bufferPos{e.cy, e.cx}
+	if end.before(start) {
+		start, end = end, start
+	}
+
+	return start, end, start != end
+}
+
+// editorSelectedRows returns the indices of the first and last rows which are
+// part of the selection, or the row containing the cursor when there isn't
+// one. A row is only included when some of its text is selected.
+func editorSelectedRows() (first, last int, ok bool) {
+	first, last = e.cy, e.cy
+
+	if start, end, selected := editorSelection(); selected {
+		first, last = start.line, end.line
+		if end.col == 0 && end.line > start.line {
+			last--
+		}
+	}
+
+	last = min(last, len(e.row)-1)
+	return first, last, first <= last
+}
+
+// editorSelectionSpansRows returns whether the selection includes text from
+// more than one row.
+func editorSelectionSpansRows() bool {
+	start, end, ok := editorSelection()
+	return ok && start.line != end.line
+}
+
+// editorDeleteSelection deletes the selected text, if any, and moves the
+// cursor to where it was. It returns whether anything was deleted.
+func editorDeleteSelection() bool {
+	if e.selecting && e.blockSelecting {
+		return editorDeleteBlockSelection()
+	}
+
+	start, end, ok := editorSelection()
+	e.selecting = false
+	if !ok {
+		return false
+	}
+
+	bufferDeleteRange(start, end)
+	e.cy, e.cx = start.line, start.col
+	return true
+}
+
+// editorUpdateSelectionRender converts the selection to positions in the
+// render field of rows so that it can be drawn.
+func editorUpdateSelectionRender() {
+	start, end, ok := editorSelection()
+	if !ok {
+		selectionRender.start = renderPos{}
+		selectionRender.end = renderPos{}
+		return
+	}
+
+	toRender := func(p bufferPos) renderPos {
+		if p.line >= len(e.row) {
+			return renderPos{p.line, 0}
+		}
+		return renderPos{p.line, editorRowCxToRx(e.row[p.line], p.col)}
+	}
+
+	selectionRender.start = toRender(start)
+	selectionRender.end = toRender(end)
+
+	selectionRender.block = e.blockSelecting
+	if e.blockSelecting {
+		selectionRender.left, selectionRender.right = blockColumns()
+	}
+}
+
+// isSelected returns whether the character at index rx of the render field of
+// the row at index at is selected.
+func isSelected(at, rx int) bool {
+	start, end := selectionRender.start, selectionRender.end
+
+	if selectionRender.block {
+		return at >= start.row && at <= end.row && rx >= selectionRender.left && rx < selectionRender.right
+	}
+
+	afterStart := at > start.row || (at == start.row && rx >= start.rx)
+	beforeEnd := at < end.row || (at == end.row && rx < end.rx)
+	return afterStart && beforeEnd
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+)
+
+// highlightShell highlights keywords, strings, variables and comments in a row
+// of a shell script.
+func highlightShell(row *editorRow) {
+	fillHighlight(row, 0, len(row.render), highlightNormal)
+
+	s := row.render
+
+	// Strings in shell scripts may span multiple lines.
+	var quote byte
+	if row.idx > 0 {
+		quote = byte(e.row[row.idx-1].openString)
+	}
+
+	i := 0
+	for i < len(s) {
+		c := s[i]
+
+		if quote != 0 {
+			// Variables are only expanded inside of double quotes.
+			if quote == '"' {
+				if c == '\\' {
+					fillHighlight(row, i, min(i+2, len(s)), highlightString)
+					i += 2
+					continue
+				}
+				if end := shellVariableEnd(s, i); end > i {
+					fillHighlight(row, i, end, highlightVariable)
+					i = end
+					continue
+				}
+			}
+
+			row.highlight[i] = highlightString
+			if c == quote {
+				quote = 0
+			}
+			i++
+			continue
+		}
+
+		atWordStart := i == 0 || isShellSeparator(s[i-1])
+
+		switch {
+		case c == '\\':
+			// Escaped character
+			i += 2
+		case c == '#' && atWordStart:
+			fillHighlight(row, i, len(s), highlightComment)
+			i = len(s)
+		case c == '\'' || c == '"':
+			quote = c
+			row.highlight[i] = highlightString
+			i++
+		case c == '$':
+			end := shellVariableEnd(s, i)
+			if end == i {
+				i++
+				continue
+			}
+			fillHighlight(row, i, end, highlightVariable)
+			i = end
+		case atWordStart && c != '$' && isIdentifierByte(c):
+			end := i
+			for end < len(s) && s[end] != '$' && isIdentifierByte(s[end]) {
+				end++
+			}
+			if end == len(s) || isShellSeparator(s[end]) {
+				if hl, ok := shellKeywordHighlight(s[i:end]); ok {
+					fillHighlight(row, i, end, hl)
+				}
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+
+	row.openString = rune(quote)
+}
+
+// shellVariableEnd returns the index after the end of the variable reference
+// (e.g. $HOME, ${HOME:-/}, or $1) which starts at index i of s, or i if there
+// isn't one.
+func shellVariableEnd(s string, i int) int {
+	if s[i] != '$' || i+1 >= len(s) {
+		return i
+	}
+
+	next := s[i+1]
+	switch {
+	case next == '{':
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return len(s)
+		}
+		return i + end + 1
+	case next == '_' || (next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z'):
+		end := i + 1
+		for end < len(s) && s[end] != '$' && isIdentifierByte(s[end]) {
+			end++
+		}
+		return end
+	case strings.IndexByte("0123456789@#?$!*-", next) >= 0:
+		return i + 2
+	}
+
+	return i
+}
+
+// shellKeywordHighlight returns how word should be highlighted if it's a
+// keyword of e.syntax.
+func shellKeywordHighlight(word string) (editorHighlight, bool) {
+	for _, keywordPattern := range e.syntax.keywords {
+		isSecondary := strings.HasSuffix(keywordPattern, "|")
+		if strings.TrimSuffix(keywordPattern, "|") != word {
+			continue
+		}
+
+		if isSecondary {
+			return highlightKeyword2, true
+		}
+		return highlightKeyword1, true
+	}
+
+	return highlightNormal, false
+}
+
+func isShellSeparator(c byte) bool {
+	return strings.IndexByte(" \t;|&()<>`", c) >= 0
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// snippet is text which is inserted in place of a trigger word when Tab is
+// pressed after it.
+type snippet struct {
+	trigger string
+	body    string
+}
+
+// snippetField is a placeholder in an expanded snippet which the cursor can
+// move to. Fields with the same number mirror the text of the first one.
+type snippetField struct {
+	number     int
+	start, end bufferPos
+}
+
+// snippetsByFileType caches the snippets loaded for each file type.
+var snippetsByFileType = make(map[string][]snippet)
+
+// snippetSession is an expanded snippet whose fields are being filled in.
+type snippetSession struct {
+	fields []snippetField
+	// numbers contains the numbers of the fields in the order they're moved
+	// through, and current is the index of the one the cursor is in.
+	numbers []int
+	current int
+}
+
+// activeSnippet is the snippet whose fields Tab and Shift-Tab move between, if
+// any.
+var activeSnippet *snippetSession
+
+func init() {
+	bufferOnChange(snippetOnChange)
+}
+
+// snippetOnChange keeps the fields of the active snippet on the same text when
+// the buffer changes. Text inserted at the start of a field becomes part of
+// it.
+func snippetOnChange(change bufferChange) {
+	if activeSnippet == nil {
+		return
+	}
+
+	for i, f := range activeSnippet.fields {
+		if f.start != change.start {
+			f.start = f.start.adjust(change)
+		}
+		f.end = f.end.adjust(change)
+		activeSnippet.fields[i] = f
+	}
+}
+
+// snippetsDir returns the directory which contains snippet files.
+func snippetsDir() string {
+	return filepath.Join(configDir(), "snippets")
+}
+
+// editorSnippets returns the snippets for the type of the open file, which are
+// loaded from <file type>.snippets in the snippets directory, followed by the
+// ones in all.snippets.
+func editorSnippets() []snippet {
+	fileType := ""
+	if e.syntax != nil {
+		fileType = e.syntax.fileType
+	}
+
+	if snippets, ok := snippetsByFileType[fileType]; ok {
+		return snippets
+	}
+
+	var snippets []snippet
+	if fileType != "" {
+		snippets = loadSnippets(filepath.Join(snippetsDir(), fileType+".snippets"))
+	}
+	snippets = append(snippets, loadSnippets(filepath.Join(snippetsDir(), "all.snippets"))...)
+
+	snippetsByFileType[fileType] = snippets
+	return snippets
+}
+
+// loadSnippets reads the snippets in the file at path. Each one starts with a
+// line like "snippet fori", followed by the lines of its body, each indented
+// with a tab. Lines starting with # are ignored.
+//
+// In the body, ${1:default} and $1 are fields which Tab moves between in order
+// of their number, and $0 is where the cursor ends up. Fields which are
+// repeated mirror the text typed in the first one.
+func loadSnippets(path string) []snippet {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var snippets []snippet
+	var body []string
+	finish := func() {
+		if len(snippets) > 0 && snippets[len(snippets)-1].body == "" {
+			snippets[len(snippets)-1].body = strings.Join(body, "\n")
+		}
+		body = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if trigger, ok := strings.CutPrefix(line, "snippet "); ok {
+			finish()
+			snippets = append(snippets, snippet{trigger: strings.TrimSpace(trigger)})
+			continue
+		}
+
+		if text, ok := strings.CutPrefix(line, "\t"); ok && len(snippets) > 0 {
+			body = append(body, text)
+		} else if line == "" && len(body) > 0 {
+			body = append(body, "")
+		}
+	}
+	finish()
+
+	return snippets
+}
+
+// parseSnippetBody returns the text of body with its fields replaced by their
+// default text, and the positions of the fields in it.
+func parseSnippetBody(body string) (string, []snippetField) {
+	var b strings.Builder
+	var fields []snippetField
+	pos := bufferPos{}
+
+	write := func(s string) {
+		b.WriteString(s)
+		pos = textEnd(pos, s)
+	}
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == '\\' && i+1 < len(body) && strings.IndexByte("$}\\", body[i+1]) >= 0 {
+			i++
+			write(body[i : i+1])
+			continue
+		}
+		if c != '$' || i+1 >= len(body) {
+			write(body[i : i+1])
+			continue
+		}
+
+		rest := body[i+1:]
+		number, placeholder, length := parseSnippetField(rest)
+		if length == 0 {
+			write("$")
+			continue
+		}
+
+		start := pos
+		write(placeholder)
+		fields = append(fields, snippetField{number, start, pos})
+		i += length
+	}
+
+	return b.String(), fields
+}
+
+// parseSnippetField parses the field at the start of s, which follows a $. It
+// returns the number of the field, its default text, and the number of bytes
+// it takes up, which is 0 when s doesn't start with a field.
+func parseSnippetField(s string) (number int, placeholder string, length int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, "", 0
+		}
+
+		n, text, _ := strings.Cut(s[1:end], ":")
+		number, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, "", 0
+		}
+		return number, text, end + 1
+	}
+
+	digits := 0
+	for digits < len(s) && '0' <= s[digits] && s[digits] <= '9' {
+		digits++
+	}
+	number, err := strconv.Atoi(s[:digits])
+	if err != nil {
+		return 0, "", 0
+	}
+	return number, "", digits
+}
+
+// editorExpandSnippet replaces the word before the cursor with the snippet it
+// triggers, if there is one, and moves to the first field. It returns whether
+// a snippet was expanded.
+func editorExpandSnippet() bool {
+	if e.selecting || len(e.cursors) > 0 || e.cy >= len(e.row) {
+		return false
+	}
+
+	start := completionWordStart()
+	word := e.row[e.cy].raw[start:e.cx]
+	if word == "" {
+		return false
+	}
+
+	i := slices.IndexFunc(editorSnippets(), func(s snippet) bool { return s.trigger == word })
+	if i < 0 {
+		return false
+	}
+
+	text, fields := parseSnippetBody(editorSnippets()[i].body)
+	text, fields = indentSnippet(text, fields, leadingWhitespace(e.row[e.cy].raw))
+
+	at := bufferPos{e.cy, start}
+	bufferReplaceRange(at, bufferPos{e.cy, e.cx}, text)
+
+	end := textEnd(at, text)
+	for i, f := range fields {
+		fields[i].start = offsetPos(f.start, at)
+		fields[i].end = offsetPos(f.end, at)
+	}
+
+	if len(fields) == 0 {
+		e.cy, e.cx = end.line, end.col
+		return true
+	}
+
+	numbers := make([]int, 0, len(fields))
+	for _, f := range fields {
+		if !slices.Contains(numbers, f.number) {
+			numbers = append(numbers, f.number)
+		}
+	}
+	// The cursor finishes at $0, or the end of the snippet when there isn't
+	// one.
+	slices.SortFunc(numbers, func(a, b int) int {
+		if a == 0 || b == 0 {
+			return b - a
+		}
+		return a - b
+	})
+	if !slices.Contains(numbers, 0) {
+		numbers = append(numbers, 0)
+		fields = append(fields, snippetField{0, end, end})
+	}
+
+	activeSnippet = &snippetSession{fields: fields, numbers: numbers, current: -1}
+	editorMoveToSnippetField(1)
+	return true
+}
+
+// indentSnippet indents each line of text after the first with indent, and
+// replaces the tabs which indent it with the file's indentation.
+func indentSnippet(text string, fields []snippetField, indent string) (string, []snippetField) {
+	lines := strings.Split(text, "\n")
+	// added contains the number of bytes which were added to the start of each
+	// line.
+	added := make([]int, len(lines))
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+
+		tabs := len(line) - len(strings.TrimLeft(line, "\t"))
+		lines[i] = indent + strings.Repeat(editorIndentUnit(), tabs) + line[tabs:]
+		added[i] = len(lines[i]) - len(line)
+	}
+
+	for i, f := range fields {
+		fields[i].start.col += added[f.start.line]
+		fields[i].end.col += added[f.end.line]
+	}
+
+	return strings.Join(lines, "\n"), fields
+}
+
+// offsetPos converts p, a position in text inserted at at, to a position in
+// the buffer.
+func offsetPos(p, at bufferPos) bufferPos {
+	if p.line == 0 {
+		return bufferPos{at.line, at.col + p.col}
+	}
+	return bufferPos{at.line + p.line, p.col}
+}
+
+// editorMoveToSnippetField moves to the next field of the active snippet, or
+// the previous one when dir is negative, and selects its text. The snippet
+// stops being active once the last field is reached. It returns whether there
+// was a snippet to move in.
+func editorMoveToSnippetField(dir int) bool {
+	s := activeSnippet
+	if s == nil {
+		return false
+	}
+
+	next := s.current + dir
+	if next < 0 {
+		return true
+	}
+	s.current = min(next, len(s.numbers)-1)
+
+	i := slices.IndexFunc(s.fields, func(f snippetField) bool { return f.number == s.numbers[s.current] })
+	f := s.fields[i]
+
+	editorClearSelection()
+	if f.start != f.end {
+		e.selecting = true
+		e.anchor = f.start
+	}
+	e.cy, e.cx = f.end.line, f.end.col
+
+	if s.numbers[s.current] == 0 {
+		activeSnippet = nil
+	}
+	return true
+}
+
+// editorUpdateSnippetMirrors copies the text of the first field with each
+// number to the other fields with the same number. It's called after every
+// key, so that they change while typing.
+func editorUpdateSnippetMirrors() {
+	s := activeSnippet
+	if s == nil {
+		return
+	}
+
+	// Stop when the cursor leaves the snippet.
+	cursor := bufferPos{e.cy, e.cx}
+	inField := slices.ContainsFunc(s.fields, func(f snippetField) bool {
+		return !cursor.before(f.start) && !f.end.before(cursor)
+	})
+	if !inField {
+		activeSnippet = nil
+		return
+	}
+
+	for i, f := range s.fields {
+		first := slices.IndexFunc(s.fields, func(g snippetField) bool { return g.number == f.number })
+		if first == i {
+			continue
+		}
+
+		text := bufferText(s.fields[first].start, s.fields[first].end)
+		if bufferText(f.start, f.end) != text {
+			editorReplaceKeepingCursor(f.start, f.end, text)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// spellWords contains the words in the dictionary in lower case. It's loaded
+// when spell checking is first needed.
+var spellWords map[string]bool
+
+// spellLoadFailed is set when the dictionary couldn't be read, so that it isn't
+// tried again for every row.
+var spellLoadFailed bool
+
+// editorSpellDictionary returns the words in the dictionary, loading it if
+// necessary. It returns nil when there isn't one.
+func editorSpellDictionary() map[string]bool {
+	if spellWords != nil || spellLoadFailed {
+		return spellWords
+	}
+
+	words, err := loadDictionary(e.dictionary)
+	if err != nil {
+		spellLoadFailed = true
+		editorSetStatusMessage("Can't load dictionary: %s", err.Error())
+		return nil
+	}
+
+	// Words which were added by the user are kept separately so that they
+	// aren't lost when the dictionary is updated.
+	personal, _ := loadDictionary(personalDictionaryPath())
+	maps.Copy(words, personal)
+
+	spellWords = words
+	return spellWords
+}
+
+// personalDictionaryPath returns the path of the file containing the words
+// added to the dictionary by the user.
+func personalDictionaryPath() string {
+	return filepath.Join(configDir(), "dictionary")
+}
+
+// loadDictionary reads the words from the file at path, which contains one
+// word per line. Hunspell's .dic files are supported by ignoring the count on
+// the first line and the affix flags after each word.
+func loadDictionary(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), "/")
+		if word != "" {
+			words[strings.ToLower(word)] = true
+		}
+	}
+
+	return words, scanner.Err()
+}
+
+// spellCheckOption returns the option which enables spell checking.
+func spellCheckOption() editorOption {
+	opt := boolOption("spell", &e.spellCheck)
+
+	set := opt.set
+	opt.set = func(value string) error {
+		if err := set(value); err != nil {
+			return err
+		}
+
+		editorSpellCheckAll()
+		return nil
+	}
+
+	return opt
+}
+
+// dictionaryOption returns the option which sets the path of the dictionary.
+func dictionaryOption() editorOption {
+	opt := stringOption("dictionary", &e.dictionary)
+
+	set := opt.set
+	opt.set = func(value string) error {
+		if err := set(value); err != nil {
+			return err
+		}
+
+		spellWords = nil
+		spellLoadFailed = false
+		editorSpellCheckAll()
+		return nil
+	}
+
+	return opt
+}
+
+// editorSpellCheckAll checks the spelling of every row again.
+func editorSpellCheckAll() {
+	for i := range e.row {
+		spellCheckRow(&e.row[i])
+	}
+}
+
+// spellCheckRow finds the misspelled words in row. In prose, like Markdown,
+// all of the text is checked except for code. In other files, only comments
+// and strings are.
+func spellCheckRow(row *editorRow) {
+	row.misspelled = row.misspelled[:0]
+	if !e.spellCheck {
+		return
+	}
+
+	dictionary := editorSpellDictionary()
+	if dictionary == nil {
+		return
+	}
+
+	prose := e.syntax == nil || e.syntax.flags&proseFile != 0
+	checked := func(hl editorHighlight) bool {
+		switch hl {
+		case highlightComment, highlightMultiComment:
+			return true
+		case highlightString:
+			return !prose
+		case highlightLink:
+			return false
+		}
+		return prose
+	}
+
+	for _, w := range spellWordsIn(row.render) {
+		if !checked(row.highlight[w[0]]) {
+			continue
+		}
+
+		if word := row.render[w[0]:w[1]]; !isSpelledCorrectly(word, dictionary) {
+			row.misspelled = append(row.misspelled, w)
+		}
+	}
+}
+
+// spellWordsIn returns the ranges of the words in s which are spell checked.
+// Words with digits or underscores, or which are joined to other text with
+// punctuation like dots, are likely to be code, so they aren't included.
+func spellWordsIn(s string) [][2]int {
+	var words [][2]int
+
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		code := start > 0 && strings.IndexByte("_./\\", s[start-1]) >= 0
+		for i < len(s) {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			isApostrophe := r == '\'' && i+1 < len(s) && isLetterAt(s, i+1)
+			if unicode.IsDigit(r) || r == '_' {
+				code = true
+			} else if !unicode.IsLetter(r) && !isApostrophe {
+				break
+			}
+			i += size
+		}
+
+		if i < len(s) && strings.IndexByte("_./\\(", s[i]) >= 0 && i+1 < len(s) && !isSpace(s[i+1]) {
+			code = true
+		}
+
+		if !code {
+			words = append(words, [2]int{start, i})
+		}
+	}
+
+	return words
+}
+
+// isLetterAt returns whether s has a letter at index i.
+func isLetterAt(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r)
+}
+
+// isSpelledCorrectly returns whether word is in dictionary. Words with capital
+// letters after the first one, like acronyms and names in code, are always
+// treated as correct.
+func isSpelledCorrectly(word string, dictionary map[string]bool) bool {
+	if utf8.RuneCountInString(word) < 2 {
+		return true
+	}
+
+	for _, r := range word[1:] {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+
+	lower := strings.ToLower(word)
+	if dictionary[lower] {
+		return true
+	}
+
+	base, possessive := strings.CutSuffix(lower, "'s")
+	return possessive && dictionary[base]
+}
+
+// isMisspelled returns whether the character at index rx of the render field
+// of row is part of a misspelled word.
+func isMisspelled(row *editorRow, rx int) bool {
+	for _, w := range row.misspelled {
+		if rx >= w[0] && rx < w[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editorMisspelledWordAtCursor returns the range of the misspelled word which
+// contains the cursor, or which ends at it.
+func editorMisspelledWordAtCursor() (start, end bufferPos, ok bool) {
+	if e.cy >= len(e.row) {
+		return bufferPos{}, bufferPos{}, false
+	}
+
+	row := e.row[e.cy]
+	rx := editorRowCxToRx(row, e.cx)
+	for _, w := range row.misspelled {
+		if rx >= w[0] && rx <= w[1] {
+			start := bufferPos{e.cy, editorRowRxToCx(row, w[0])}
+			end := bufferPos{e.cy, editorRowRxToCx(row, w[1])}
+			return start, end, true
+		}
+	}
+
+	return bufferPos{}, bufferPos{}, false
+}
+
+// editorSpellSuggest shows the corrections for the misspelled word at the
+// cursor, and replaces it with the one which is picked. The word can also be
+// added to the personal dictionary from the list.
+func editorSpellSuggest() {
+	start, end, ok := editorMisspelledWordAtCursor()
+	if !ok {
+		editorSetStatusMessage("No misspelled word at the cursor")
+		return
+	}
+
+	word := bufferText(start, end)
+	suggestions := spellSuggestions(word, editorSpellDictionary())
+
+	lines := slices.Clone(suggestions)
+	lines = append(lines, fmt.Sprintf("Add %q to the dictionary", word))
+
+	i, ok := editorPickFromOverlay(fmt.Sprintf("Corrections for %s", word), lines)
+	if !ok {
+		return
+	}
+
+	if i == len(suggestions) {
+		if err := editorAddToDictionary(word); err != nil {
+			editorSetStatusMessage("Can't add to dictionary: %s", err.Error())
+		}
+		return
+	}
+
+	editorReplaceKeepingCursor(start, end, suggestions[i])
+	e.cy, e.cx = start.line, start.col+len(suggestions[i])
+}
+
+// maxSuggestions is the maximum number of corrections shown for a word.
+const maxSuggestions = 20
+
+// spellSuggestions returns the words in dictionary which are closest to word,
+// best first. Only words which are at most two edits away are included. The
+// suggestions are capitalized in the same way as word.
+func spellSuggestions(word string, dictionary map[string]bool) []string {
+	type suggestion struct {
+		word     string
+		distance int
+	}
+
+	lower := []rune(strings.ToLower(word))
+	var found []suggestion
+	for candidate := range dictionary {
+		c := []rune(candidate)
+		if len(c) < len(lower)-2 || len(c) > len(lower)+2 {
+			continue
+		}
+
+		if d := editDistance(lower, c); d <= 2 {
+			found = append(found, suggestion{candidate, d})
+		}
+	}
+
+	// Words which start with the same letter are more likely to be what was
+	// meant.
+	slices.SortFunc(found, func(a, b suggestion) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		aFirst := strings.HasPrefix(a.word, string(lower[:1]))
+		bFirst := strings.HasPrefix(b.word, string(lower[:1]))
+		if aFirst != bFirst {
+			if aFirst {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.word, b.word)
+	})
+
+	var suggestions []string
+	for _, s := range found[:min(len(found), maxSuggestions)] {
+		suggestions = append(suggestions, matchCase(s.word, word))
+	}
+
+	return suggestions
+}
+
+// editDistance returns the number of insertions, deletions, substitutions, and
+// transpositions of adjacent characters needed to change a into b.
+func editDistance(a, b []rune) int {
+	// d[i][j] is the distance between the first i runes of a and the first j
+	// runes of b.
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+// matchCase returns word, which is in lower case, capitalized like original
+// is.
+func matchCase(word, original string) string {
+	if strings.ToUpper(original) == original {
+		return strings.ToUpper(word)
+	}
+
+	first, _ := utf8.DecodeRuneInString(original)
+	if unicode.IsUpper(first) {
+		r, size := utf8.DecodeRuneInString(word)
+		return string(unicode.ToUpper(r)) + word[size:]
+	}
+
+	return word
+}
+
+// editorAddToDictionary adds word to the personal dictionary, so that it's no
+// longer treated as misspelled.
+func editorAddToDictionary(word string) error {
+	path := personalDictionaryPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, word); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if spellWords != nil {
+		spellWords[strings.ToLower(word)] = true
+	}
+	editorSpellCheckAll()
+
+	editorSetStatusMessage("Added %s to the dictionary", word)
+	return nil
+}
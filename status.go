@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultStatusFormat is the format of the status bar when it hasn't been
+// configured. See expandStatusFormat.
+const defaultStatusFormat = "%20f - %L lines %m%>%y | %l/%L | col %c (%v) | byte %o"
+
+// editorStatusLine returns the text on the left and right of the status bar,
+// as configured by the statusformat option. The text after %> is on the right.
+func editorStatusLine() (left, right string) {
+	left, right, _ = strings.Cut(e.statusFormat, "%>")
+	return expandStatusFormat(left), expandStatusFormat(right)
+}
+
+// expandStatusFormat replaces the placeholders in format with the values they
+// stand for:
+//
+//	%f  the name of the file
+//	%m  (modified) or (read-only), when the file is
+//	%y  the type of the file
+//	%l  the line number of the cursor
+//	%L  the number of lines in the file
+//	%c  the column of the cursor on the screen, which counts tabs as
+//	    multiple columns
+//	%v  the column of the cursor in characters, which counts tabs as one
+//	%o  the offset of the cursor from the start of the file, in bytes
+//	%w  the number of words in the file, or the selection
+//	%p  how far through the file the cursor is, as a percentage
+//	%%  a percent sign
+//
+// A number between the % and the letter limits the value to that many
+// characters, e.g. %20f. Anything else is left as it is.
+func expandStatusFormat(format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format)-1 && format[i] >= '0' && format[i] <= '9' {
+			i++
+		}
+		value, ok := statusValue(format[i])
+		if !ok {
+			b.WriteString(format[start : i+1])
+			continue
+		}
+
+		if width, err := strconv.Atoi(format[start+1 : i]); err == nil {
+			value = truncateChars(value, width)
+		}
+		b.WriteString(value)
+	}
+
+	return b.String()
+}
+
+// statusValue returns the value of the placeholder with the letter c in the
+// status bar format, and whether there is one.
+func statusValue(c byte) (string, bool) {
+	switch c {
+	case 'f':
+		if e.filename == "" {
+			return "[No Name]", true
+		}
+		return e.filename, true
+	case 'm':
+		if e.dirty {
+			return "(modified)", true
+		}
+		if e.readOnly {
+			return "(read-only)", true
+		}
+		return "", true
+	case 'y':
+		if e.syntax != nil {
+			return e.syntax.fileType, true
+		}
+		return "no ft", true
+	case 'l':
+		return strconv.Itoa(e.cy + 1), true
+	case 'L':
+		return strconv.Itoa(len(e.row)), true
+	case 'c':
+		return strconv.Itoa(e.rx + 1), true
+	case 'v':
+		column := 1
+		if e.cy < len(e.row) {
+			column += utf8.RuneCountInString(e.row[e.cy].raw[:e.cx])
+		}
+		return strconv.Itoa(column), true
+	case 'o':
+		return strconv.Itoa(editorPositionToOffset(e.cy, e.cx)), true
+	case 'w':
+		return strconv.Itoa(editorCountWords()), true
+	case 'p':
+		percent := 100
+		if len(e.row) > 0 {
+			percent = min(e.cy+1, len(e.row)) * 100 / len(e.row)
+		}
+		return strconv.Itoa(percent), true
+	case '%':
+		return "%", true
+	}
+
+	return "", false
+}
+
+// truncateChars returns the first n characters of s.
+func truncateChars(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tag is the location of a definition from a tags file.
+type tag struct {
+	name string
+	path string
+	// line is the 1-based line number of the definition, or 0 when it's found
+	// by searching for pattern instead.
+	line    int
+	pattern string
+	kind    string
+}
+
+// findTagsFile returns the path of the closest tags file in the directory of
+// the open file, or one of its parents.
+func findTagsFile() (string, bool) {
+	dir, err := filepath.Abs(filepath.Dir(e.filename))
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		path := filepath.Join(dir, "tags")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// readTags returns the tags named name in the tags file at path, in the
+// format written by ctags. The paths of the tags are made relative to the
+// working directory.
+func readTags(path, name string) ([]tag, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	wd, _ := os.Getwd()
+
+	var tags []tag
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, name+"\t") {
+			continue
+		}
+
+		t, ok := parseTag(line)
+		if !ok {
+			continue
+		}
+
+		if !filepath.IsAbs(t.path) {
+			t.path = filepath.Join(filepath.Dir(path), t.path)
+		}
+		if rel, err := filepath.Rel(wd, t.path); err == nil {
+			t.path = rel
+		}
+
+		tags = append(tags, t)
+	}
+
+	return tags, scanner.Err()
+}
+
+// parseTag parses a line from a tags file, like
+// "main\tmain.go\t/^func main() {$/;\"\tf".
+func parseTag(line string) (tag, bool) {
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) < 3 {
+		return tag{}, false
+	}
+
+	t := tag{name: fields[0], path: fields[1]}
+	address, extra, _ := strings.Cut(fields[2], ";\"")
+
+	if n, err := strconv.Atoi(address); err == nil {
+		t.line = n
+	} else if len(address) >= 2 && (address[0] == '/' || address[0] == '?') {
+		t.pattern = unescapeTagPattern(address[1 : len(address)-1])
+	} else {
+		return tag{}, false
+	}
+
+	// The first extension field without a name is the kind of the tag.
+	for field := range strings.SplitSeq(strings.TrimSpace(extra), "\t") {
+		if field != "" && !strings.Contains(field, ":") {
+			t.kind = field
+			break
+		}
+	}
+
+	return t, true
+}
+
+// unescapeTagPattern removes the backslashes which escape the delimiters of a
+// search pattern in a tags file.
+func unescapeTagPattern(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+
+	return b.String()
+}
+
+// find returns the 0-based line number of the definition in its file, using
+// lineText to get the text of each line.
+func (t tag) find(lineText func(int) string, lines int) int {
+	if t.pattern == "" {
+		return max(t.line-1, 0)
+	}
+
+	pattern, anchoredStart := strings.CutPrefix(t.pattern, "^")
+	pattern, anchoredEnd := strings.CutSuffix(pattern, "$")
+	for i := range lines {
+		text := lineText(i)
+		switch {
+		case anchoredStart && anchoredEnd && text == pattern,
+			anchoredStart && !anchoredEnd && strings.HasPrefix(text, pattern),
+			!anchoredStart && anchoredEnd && strings.HasSuffix(text, pattern),
+			!anchoredStart && !anchoredEnd && strings.Contains(text, pattern):
+			return i
+		}
+	}
+
+	return 0
+}
+
+// editorGoToTag jumps to the definition of name, or the word under the cursor
+// when it's empty, using the tags file. When there are several definitions,
+// one is picked from a list.
+func editorGoToTag(name string) {
+	if name == "" {
+		start, end, ok := editorWordUnderCursor()
+		if !ok {
+			editorSetStatusMessage("No word under the cursor")
+			return
+		}
+		name = bufferText(start, end)
+	}
+
+	path, ok := findTagsFile()
+	if !ok {
+		editorSetStatusMessage("No tags file")
+		return
+	}
+
+	tags, err := readTags(path, name)
+	if err != nil {
+		editorSetStatusMessage("Can't read tags: %s", err.Error())
+		return
+	}
+	if len(tags) == 0 {
+		editorSetStatusMessage("No tag for %s", name)
+		return
+	}
+
+	i := 0
+	if len(tags) > 1 {
+		lines := make([]string, len(tags))
+		for i, t := range tags {
+			lines[i] = formatTag(t)
+		}
+
+		i, ok = editorPickFromOverlay(fmt.Sprintf("%d tags for %s", len(tags), name), lines)
+		if !ok {
+			return
+		}
+	}
+
+	t := tags[i]
+	from := editorCurrentJump()
+	if !editorSwitchFile(t.path) {
+		return
+	}
+
+	editorPushJump(from)
+	line := t.find(editorRowText, len(e.row))
+	editorGoToPos(bufferPos{line, max(strings.Index(editorRowText(line), t.name), 0)})
+}
+
+// formatTag formats t for the list of tags to pick from.
+func formatTag(t tag) string {
+	where := t.pattern
+	if where == "" {
+		where = strconv.Itoa(t.line)
+	}
+
+	if t.kind == "" {
+		return fmt.Sprintf("%s: %s", t.path, where)
+	}
+	return fmt.Sprintf("%s: %s [%s]", t.path, where, t.kind)
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// theme contains the colours used to display the file and the editor's UI.
+type theme struct {
+	name string
+
+	// highlights contains the style of each type of highlight. Types which
+	// aren't included use the terminal's default colours.
+	highlights map[editorHighlight]style
+
+	statusBar           colour
+	statusBarBackground colour
+	// virtualText is the colour of text which is displayed in the file, but
+	// isn't part of it (e.g. diagnostics).
+	virtualText colour
+	// cursorLine is the background colour of the row containing the cursor.
+	cursorLine colour
+	// matchingBracket is the style of the bracket next to the cursor and the
+	// one which matches it.
+	matchingBracket style
+	// whitespace is the colour of the markers shown for tabs.
+	whitespace colour
+	// trailingWhitespace is the background colour of whitespace at the end of
+	// a row.
+	trailingWhitespace colour
+	// colourColumn is the background colour of the columns which show where
+	// lines become too long.
+	colourColumn colour
+	// indentGuide is the colour of the lines drawn at each level of
+	// indentation.
+	indentGuide colour
+	// selection is the background colour of selected text.
+	selection colour
+	// secondaryCursor is the background colour of the cursors other than the
+	// terminal's one.
+	secondaryCursor colour
+	// diagnostic is the colour of the markers in the gutter next to rows with
+	// problems.
+	diagnostic colour
+	// popup is the background colour of popups, like the list of
+	// completions.
+	popup colour
+	// misspelled is the colour of words which the spell checker doesn't
+	// recognize.
+	misspelled colour
+	// gitAdded, gitChanged, and gitDeleted are the colours of the markers in
+	// the gutter next to rows which differ from the git index.
+	gitAdded, gitChanged, gitDeleted colour
+	// conflictMarker is the colour of the rows which separate the sides of a
+	// merge conflict, and the others are the background colours of each side.
+	conflictMarker                             colour
+	conflictOurs, conflictBase, conflictTheirs colour
+	// diffChanged is the background colour of lines which differ in a diff,
+	// and diffDeleted and diffAdded are the colours of the text which was
+	// removed from and added to them. diffFiller is the background of the
+	// space opposite lines which were only on one side.
+	diffChanged, diffDeleted, diffAdded, diffFiller colour
+}
+
+// themeHighlightNames maps the names used in theme files to the type of
+// highlight that they set the colour of.
+var themeHighlightNames = map[string]editorHighlight{
+	"normal":       highlightNormal,
+	"comment":      highlightComment,
+	"multicomment": highlightMultiComment,
+	"keyword1":     highlightKeyword1,
+	"keyword2":     highlightKeyword2,
+	"string":       highlightString,
+	"number":       highlightNumber,
+	"match":        highlightMatch,
+	"key":          highlightKey,
+	"emphasis":     highlightEmphasis,
+	"link":         highlightLink,
+	"variable":     highlightVariable,
+	"bracket1":     highlightBracket1,
+	"bracket2":     highlightBracket2,
+	"bracket3":     highlightBracket3,
+}
+
+// colourNames maps the names of the 16 basic colours to their index in the
+// palette.
+var colourNames = map[string]uint8{
+	"black": 0, "red": 1, "green": 2, "yellow": 3,
+	"blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+	"brightblack": 8, "brightred": 9, "brightgreen": 10, "brightyellow": 11,
+	"brightblue": 12, "brightmagenta": 13, "brightcyan": 14, "brightwhite": 15,
+}
+
+// builtinThemes are the themes which are available without any theme files.
+// The first one is used by default.
+var builtinThemes = []theme{
+	{
+		name: "default",
+		highlights: map[editorHighlight]style{
+			highlightComment:      {fg: indexedColour(6)},
+			highlightMultiComment: {fg: indexedColour(6)},
+			highlightKeyword1:     {fg: indexedColour(3)},
+			highlightKeyword2:     {fg: indexedColour(2)},
+			highlightString:       {fg: indexedColour(5)},
+			highlightNumber:       {fg: indexedColour(1)},
+			highlightMatch:        {fg: indexedColour(0), bg: indexedColour(3)},
+			highlightKey:          {fg: indexedColour(12)},
+			highlightEmphasis:     {fg: indexedColour(11)},
+			highlightLink:         {fg: indexedColour(14)},
+			highlightVariable:     {fg: indexedColour(10)},
+			highlightBracket1:     {fg: indexedColour(11)},
+			highlightBracket2:     {fg: indexedColour(13)},
+			highlightBracket3:     {fg: indexedColour(12)},
+		},
+		cursorLine:         indexedColour(236),
+		matchingBracket:    style{bg: indexedColour(8)},
+		whitespace:         indexedColour(8),
+		trailingWhitespace: indexedColour(1),
+		colourColumn:       indexedColour(236),
+		indentGuide:        indexedColour(239),
+		selection:          indexedColour(4),
+		secondaryCursor:    indexedColour(7),
+		diagnostic:         indexedColour(1),
+		popup:              indexedColour(236),
+		misspelled:         indexedColour(9),
+		gitAdded:           indexedColour(2),
+		gitChanged:         indexedColour(3),
+		gitDeleted:         indexedColour(1),
+		conflictMarker:     indexedColour(13),
+		conflictOurs:       indexedColour(28),
+		conflictBase:       indexedColour(237),
+		conflictTheirs:     indexedColour(25),
+		diffChanged:        indexedColour(236),
+		diffDeleted:        indexedColour(88),
+		diffAdded:          indexedColour(28),
+		diffFiller:         indexedColour(234),
+	},
+	{
+		name: "gruvbox",
+		highlights: map[editorHighlight]style{
+			highlightComment:      {fg: rgbColour(0x92, 0x83, 0x74)},
+			highlightMultiComment: {fg: rgbColour(0x92, 0x83, 0x74)},
+			highlightKeyword1:     {fg: rgbColour(0xfb, 0x49, 0x34)},
+			highlightKeyword2:     {fg: rgbColour(0xfa, 0xbd, 0x2f)},
+			highlightString:       {fg: rgbColour(0xb8, 0xbb, 0x26)},
+			highlightNumber:       {fg: rgbColour(0xd3, 0x86, 0x9b)},
+			highlightMatch:        {fg: rgbColour(0x28, 0x28, 0x28), bg: rgbColour(0xfa, 0xbd, 0x2f)},
+			highlightKey:          {fg: rgbColour(0x8e, 0xc0, 0x7c)},
+			highlightEmphasis:     {fg: rgbColour(0xfe, 0x80, 0x19)},
+			highlightLink:         {fg: rgbColour(0x83, 0xa5, 0x98)},
+			highlightVariable:     {fg: rgbColour(0x8e, 0xc0, 0x7c)},
+			highlightBracket1:     {fg: rgbColour(0xfa, 0xbd, 0x2f)},
+			highlightBracket2:     {fg: rgbColour(0xd3, 0x86, 0x9b)},
+			highlightBracket3:     {fg: rgbColour(0x83, 0xa5, 0x98)},
+		},
+		statusBar:           rgbColour(0xeb, 0xdb, 0xb2),
+		statusBarBackground: rgbColour(0x50, 0x49, 0x45),
+		virtualText:         rgbColour(0x92, 0x83, 0x74),
+		cursorLine:          rgbColour(0x3c, 0x38, 0x36),
+		matchingBracket:     style{bg: rgbColour(0x66, 0x5c, 0x54)},
+		whitespace:          rgbColour(0x66, 0x5c, 0x54),
+		trailingWhitespace:  rgbColour(0xcc, 0x24, 0x1d),
+		colourColumn:        rgbColour(0x3c, 0x38, 0x36),
+		indentGuide:         rgbColour(0x50, 0x49, 0x45),
+		selection:           rgbColour(0x45, 0x85, 0x88),
+		secondaryCursor:     rgbColour(0xa8, 0x99, 0x84),
+		diagnostic:          rgbColour(0xfb, 0x49, 0x34),
+		popup:               rgbColour(0x3c, 0x38, 0x36),
+		misspelled:          rgbColour(0xfb, 0x49, 0x34),
+		gitAdded:            rgbColour(0xb8, 0xbb, 0x26),
+		gitChanged:          rgbColour(0xfa, 0xbd, 0x2f),
+		gitDeleted:          rgbColour(0xfb, 0x49, 0x34),
+		conflictMarker:      rgbColour(0xd3, 0x86, 0x9b),
+		conflictOurs:        rgbColour(0x32, 0x36, 0x1a),
+		conflictBase:        rgbColour(0x3c, 0x38, 0x36),
+		conflictTheirs:      rgbColour(0x0e, 0x36, 0x3e),
+		diffChanged:         rgbColour(0x3c, 0x38, 0x36),
+		diffDeleted:         rgbColour(0x6e, 0x1e, 0x1b),
+		diffAdded:           rgbColour(0x4a, 0x52, 0x1a),
+		diffFiller:          rgbColour(0x1d, 0x20, 0x21),
+	},
+}
+
+// editorCurrentTheme returns the theme in use.
+func editorCurrentTheme() *theme {
+	if e.theme == nil {
+		return &builtinThemes[0]
+	}
+
+	return e.theme
+}
+
+// editorSetTheme switches to the theme with the given name, which is either
+// built-in, or loaded from a file in the themes directory.
+func editorSetTheme(name string) error {
+	for i := range builtinThemes {
+		if builtinThemes[i].name == name {
+			e.theme = &builtinThemes[i]
+			return nil
+		}
+	}
+
+	if t := preloadedThemes[name]; t != nil {
+		delete(preloadedThemes, name)
+		e.theme = t
+		return nil
+	}
+
+	t, err := loadTheme(name)
+	if err != nil {
+		return err
+	}
+
+	e.theme = t
+	return nil
+}
+
+// preloadedThemes contains themes which were loaded in the background along
+// with the config file, keyed by name. Each is only used once, so that
+// switching to a theme again picks up changes to its file.
+var preloadedThemes = make(map[string]*theme)
+
+// isBuiltinTheme returns whether name is the name of a built-in theme.
+func isBuiltinTheme(name string) bool {
+	for _, t := range builtinThemes {
+		if t.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// themesDir returns the directory which contains theme files.
+func themesDir() string {
+	return filepath.Join(configDir(), "themes")
+}
+
+// themeNames returns the names of all of the available themes.
+func themeNames() []string {
+	var names []string
+	for _, t := range builtinThemes {
+		names = append(names, t.name)
+	}
+
+	entries, _ := os.ReadDir(themesDir())
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".theme"); ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// loadTheme reads the theme with the given name from the themes directory.
+//
+// Theme files contain lines like "comment = #928374" which set the colour of
+// an element. The background colour of a type of highlight is set by adding
+// .background to its name, e.g. "match.background = yellow". Colours are given
+// as #rrggbb, an index into the 256 colour palette, the name of one of the
+// basic colours (e.g. red or brightred), or default. Lines starting with # are
+// ignored.
+func loadTheme(name string) (*theme, error) {
+	path := filepath.Join(themesDir(), name+".theme")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := &theme{
+		name:       name,
+		highlights: make(map[editorHighlight]style),
+	}
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		key, value, ok := parseConfigLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		c, err := parseColour(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+
+		element, isBackground := strings.CutSuffix(key, ".background")
+		if hl, ok := themeHighlightNames[element]; ok {
+			s := t.highlights[hl]
+			if isBackground {
+				s.bg = c
+			} else {
+				s.fg = c
+			}
+			t.highlights[hl] = s
+			continue
+		}
+
+		switch key {
+		case "statusbar":
+			t.statusBar = c
+		case "statusbar.background":
+			t.statusBarBackground = c
+		case "virtualtext":
+			t.virtualText = c
+		case "cursorline":
+			t.cursorLine = c
+		case "matchbracket":
+			t.matchingBracket.fg = c
+		case "matchbracket.background":
+			t.matchingBracket.bg = c
+		case "whitespace":
+			t.whitespace = c
+		case "trailingwhitespace.background":
+			t.trailingWhitespace = c
+		case "colourcolumn.background":
+			t.colourColumn = c
+		case "indentguide":
+			t.indentGuide = c
+		case "selection.background":
+			t.selection = c
+		case "secondarycursor.background":
+			t.secondaryCursor = c
+		case "diagnostic":
+			t.diagnostic = c
+		case "popup.background":
+			t.popup = c
+		case "misspelled":
+			t.misspelled = c
+		case "git.added":
+			t.gitAdded = c
+		case "git.changed":
+			t.gitChanged = c
+		case "git.deleted":
+			t.gitDeleted = c
+		case "conflict.marker":
+			t.conflictMarker = c
+		case "conflict.ours.background":
+			t.conflictOurs = c
+		case "conflict.base.background":
+			t.conflictBase = c
+		case "conflict.theirs.background":
+			t.conflictTheirs = c
+		case "diff.changed.background":
+			t.diffChanged = c
+		case "diff.deleted.background":
+			t.diffDeleted = c
+		case "diff.added.background":
+			t.diffAdded = c
+		case "diff.filler.background":
+			t.diffFiller = c
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown element %q", path, lineNumber, key)
+		}
+	}
+
+	return t, scanner.Err()
+}
+
+// parseColour parses a colour in one of the formats supported by theme files.
+func parseColour(s string) (colour, error) {
+	s = strings.ToLower(s)
+
+	if s == "default" {
+		return colour{}, nil
+	}
+
+	if hex, ok := strings.CutPrefix(s, "#"); ok && len(hex) == 6 {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err == nil {
+			return rgbColour(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+		}
+	}
+
+	if index, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return indexedColour(uint8(index)), nil
+	}
+
+	if index, ok := colourNames[strings.ReplaceAll(s, "-", "")]; ok {
+		return indexedColour(index), nil
+	}
+
+	return colour{}, fmt.Errorf("invalid colour %q", s)
+}
+
+func themeOption() editorOption {
+	return editorOption{
+		name: "theme",
+		set:  editorSetTheme,
+		get: func() string {
+			return editorCurrentTheme().name
+		},
+	}
+}
+
+// editorThemeCommand switches to the given theme, prompting for its name when
+// none is given.
+func editorThemeCommand(name string) {
+	if name == "" {
+		name = editorPromptCompleting("Theme: %s", "", func(string, rune) {}, func(input string) []string {
+			return completeWords(input, themeNames())
+		})
+		if name == "" {
+			return
+		}
+	}
+
+	if err := editorSetTheme(name); err != nil {
+		editorSetStatusMessage("Can't load theme: %s", err.Error())
+		return
+	}
+
+	editorSetStatusMessage("Theme: %s", name)
+}
@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// editorInsertTimestamp inserts the current time at the cursor. It's formatted
+// with the layout given in args, or the timestampformat option when there
+// isn't one. Layouts use the format of Go's time package, e.g. 2006-01-02.
+func editorInsertTimestamp(args string) {
+	layout := args
+	if layout == "" {
+		layout = e.timestampFormat
+	}
+
+	editorDeleteSelection()
+	editorForEachCursor(func() {
+		editorInsertText(time.Now().Format(layout))
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+// editorTransposeChars swaps the characters before and after the cursor, and
+// moves the cursor past both of them. At the end of a row, the two characters
+// before the cursor are swapped instead.
+func editorTransposeChars() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	raw := e.row[e.cy].raw
+	mid := e.cx
+	if mid == len(raw) {
+		mid = prevGraphemeStart(raw, mid)
+	}
+	if mid == 0 {
+		editorSetStatusMessage("No characters to transpose")
+		return
+	}
+
+	start := prevGraphemeStart(raw, mid)
+	end := nextGraphemeEnd(raw, mid)
+	bufferReplaceRange(bufferPos{e.cy, start}, bufferPos{e.cy, end}, raw[mid:end]+raw[start:mid])
+	e.cx = end
+}
+
+// editorTransposeWords swaps the word before the cursor, or the one it's in,
+// with the word after it, and moves the cursor to the end of both of them.
+// When the cursor is at the start of a word, that word is the one after it.
+func editorTransposeWords() {
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	raw := e.row[e.cy].raw
+	isWord := func(i int) bool { return !isWordSeparator(raw[i]) }
+
+	firstEnd := e.cx
+	for firstEnd > 0 && firstEnd < len(raw) && isWord(firstEnd-1) && isWord(firstEnd) {
+		firstEnd++
+	}
+	for firstEnd > 0 && !isWord(firstEnd-1) {
+		firstEnd--
+	}
+	firstStart := firstEnd
+	for firstStart > 0 && isWord(firstStart-1) {
+		firstStart--
+	}
+
+	secondStart := firstEnd
+	for secondStart < len(raw) && !isWord(secondStart) {
+		secondStart++
+	}
+	secondEnd := secondStart
+	for secondEnd < len(raw) && isWord(secondEnd) {
+		secondEnd++
+	}
+
+	if firstStart == firstEnd || secondStart == secondEnd {
+		editorSetStatusMessage("No words to transpose")
+		return
+	}
+
+	text := raw[secondStart:secondEnd] + raw[firstEnd:secondStart] + raw[firstStart:firstEnd]
+	bufferReplaceRange(bufferPos{e.cy, firstStart}, bufferPos{e.cy, secondEnd}, text)
+	e.cx = secondEnd
+}
@@ -0,0 +1,124 @@
+package main
+
+import "os"
+
+// tutorLesson is the text of the tutorial opened by lte --tutor.
+const tutorLesson = `Welcome to the lte tutorial
+===========================
+
+This is a copy of the tutorial which was made just for you, so feel free to
+change it however you like while you practice. Read each lesson, then follow
+its instructions.
+
+
+Lesson 1: Moving the cursor
+---------------------------
+
+Use the arrow keys to move the cursor one character or line at a time. Hold
+Ctrl with Left or Right to move by words instead.
+
+  1. Move the cursor to the X at the end of this line.      X
+  2. Press Home (or Ctrl-A) to move to the start of the line, and End (or
+     Ctrl-E) to move to the end of it.
+  3. Press Page Down and Page Up to move a screen at a time.
+  4. Press Ctrl-Home to go to the top of the file, and Ctrl-End to go to the
+     bottom. Press Ctrl-O to jump back to where you were.
+  5. Press Ctrl-G, type 40, and press Enter to go to line 40.
+
+
+Lesson 2: Typing and deleting
+-----------------------------
+
+Text is typed wherever the cursor is. There are no modes to switch between.
+
+  1. Fix this line by adding the missing words:
+         The quick fox over the lazy dog.
+  2. Press Backspace to delete the character before the cursor, and Delete
+     to delete the one after it. Fix the mistakes in this line:
+         Thhe quickk brownn fox.
+  3. Press Ctrl-W to delete the word before the cursor, and Alt-D to delete
+     the one after it. Delete the repeated words in this line:
+         The the brown brown fox.
+  4. Press Ctrl-K to cut the rest of a line, and Ctrl-Y to paste it back.
+     Move this line below the next one:
+         Second line.
+         First line.
+
+
+Lesson 3: Undo and redo
+-----------------------
+
+  1. Delete this whole line with Ctrl-K.
+  2. Press Ctrl-Z to undo the change, and Ctrl-R to redo it.
+
+
+Lesson 4: Selecting text
+------------------------
+
+Hold Shift with the arrow keys to select text. Typing replaces the selection,
+and Escape cancels it.
+
+  1. Select the word "slow" in this line and type "fast" over it:
+         The slow brown fox.
+  2. Select both of these lines and press Ctrl-D to duplicate them:
+         One.
+         Two.
+
+
+Lesson 5: Searching
+-------------------
+
+  1. Press Ctrl-F and type "needle". The cursor moves to the first match.
+  2. Press Down to go to the next match, and Up to go to the previous one.
+  3. Press Enter to stop at the match, or Escape to go back to where you
+     started.
+  4. Once you've stopped at a match, press F3 to go to the next one, and
+     Shift-F3 to go to the previous one.
+
+     hay hay needle hay hay
+     hay needle hay hay hay
+
+
+Lesson 6: Commands
+------------------
+
+Less common features are run by name. Press Ctrl-P, type the name of a
+command, and press Enter.
+
+  1. Run "count" to see how many words are in this file.
+  2. Run "set cursorline" to highlight the line the cursor is on, and run it
+     again to turn it off.
+  3. Run "messages" to read the messages which have been shown at the bottom
+     of the screen.
+
+
+Lesson 7: Saving and quitting
+-----------------------------
+
+  1. Press Ctrl-S to save the file. Saving this one only changes your copy of
+     the tutorial.
+  2. Press Ctrl-Q to quit. When there are unsaved changes, you'll be asked to
+     press it a few more times, so that they aren't lost by accident.
+
+To open a file, run lte with its name, like:
+
+    lte notes.txt
+
+That's the end of the tutorial. Run lte --tutor again to start over.
+`
+
+// tutorFile writes a new copy of the tutorial to a temporary file, and returns
+// its path.
+func tutorFile() (string, error) {
+	f, err := os.CreateTemp("", "lte-tutor-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(tutorLesson); err != nil {
+		return "", err
+	}
+
+	return f.Name(), f.Close()
+}
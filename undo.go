@@ -0,0 +1,149 @@
+package main
+
+import (
+	"unicode"
+)
+
+// undoGroup is a set of changes which are undone together.
+type undoGroup struct {
+	changes []bufferChange
+	// files contains changes to other files which were made along with the
+	// ones to the buffer, e.g. by renaming a symbol.
+	files []fileChange
+
+	// cx and cy are the position of the cursor before the changes were made,
+	// which is restored when they're undone.
+	cx, cy int
+}
+
+var undoStack []undoGroup
+var redoStack []undoGroup
+
+// undoGroupOpen indicates whether new changes are added to the last group on
+// undoStack, rather than starting a new group.
+var undoGroupOpen = false
+
+// undoApplying indicates whether changes are being made by undo / redo, which
+// shouldn't be recorded.
+var undoApplying = false
+
+// undoLastKeyTyped indicates whether the last key pressed typed part of a
+// word.
+var undoLastKeyTyped = false
+
+func init() {
+	bufferOnChange(undoRecordChange)
+}
+
+func undoRecordChange(change bufferChange) {
+	if undoApplying {
+		return
+	}
+
+	redoStack = nil
+
+	if undoGroupOpen && len(undoStack) > 0 {
+		last := &undoStack[len(undoStack)-1]
+		last.changes = append(last.changes, change)
+		return
+	}
+
+	undoStack = append(undoStack, undoGroup{
+		changes: []bufferChange{change},
+		cx:      e.cx,
+		cy:      e.cy,
+	})
+	undoGroupOpen = true
+}
+
+// undoRecordFileChanges adds changes to other files to the current group, so
+// that they're undone along with the changes to the buffer.
+func undoRecordFileChanges(changes []fileChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	redoStack = nil
+
+	if !undoGroupOpen || len(undoStack) == 0 {
+		undoStack = append(undoStack, undoGroup{cx: e.cx, cy: e.cy})
+		undoGroupOpen = true
+	}
+
+	last := &undoStack[len(undoStack)-1]
+	last.files = append(last.files, changes...)
+}
+
+// editorUndoBoundary is called before each key is processed to determine which
+// changes are undone together. Every key is undone separately, except for
+// consecutive keys which type a word.
+func editorUndoBoundary(key rune) {
+	typed := key == '_' || unicode.IsLetter(key) || unicode.IsDigit(key)
+	if !typed || !undoLastKeyTyped {
+		undoGroupOpen = false
+	}
+	undoLastKeyTyped = typed
+}
+
+// editorUndoReset discards the undo history, e.g. when a file is opened.
+func editorUndoReset() {
+	undoStack = nil
+	redoStack = nil
+	undoGroupOpen = false
+}
+
+func editorUndo() {
+	if len(undoStack) == 0 {
+		editorSetStatusMessage("Already at oldest change")
+		return
+	}
+
+	group := undoStack[len(undoStack)-1]
+	if err := applyFileChanges(group.files, true); err != nil {
+		editorSetStatusMessage("Can't undo: %s", err.Error())
+		return
+	}
+	undoStack = undoStack[:len(undoStack)-1]
+
+	undoApplying = true
+	for i := len(group.changes) - 1; i >= 0; i-- {
+		change := group.changes[i]
+		bufferReplaceRange(change.start, textEnd(change.start, change.newText), change.oldText)
+	}
+	undoApplying = false
+
+	e.cx = group.cx
+	e.cy = group.cy
+
+	redoStack = append(redoStack, group)
+	undoGroupOpen = false
+}
+
+func editorRedo() {
+	if len(redoStack) == 0 {
+		editorSetStatusMessage("Already at newest change")
+		return
+	}
+
+	group := redoStack[len(redoStack)-1]
+	if err := applyFileChanges(group.files, false); err != nil {
+		editorSetStatusMessage("Can't redo: %s", err.Error())
+		return
+	}
+	redoStack = redoStack[:len(redoStack)-1]
+
+	undoApplying = true
+	var end bufferPos
+	for _, change := range group.changes {
+		end = bufferReplaceRange(change.start, change.end, change.newText)
+	}
+	undoApplying = false
+
+	if len(group.changes) > 0 {
+		e.cx = end.col
+		e.cy = end.line
+	}
+
+	undoStack = append(undoStack, group)
+	undoGroupOpen = false
+}
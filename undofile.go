@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// maxSavedUndoGroups is the number of changes kept in undo files. Older ones
+// are dropped so that the files don't grow forever.
+const maxSavedUndoGroups = 1000
+
+// undoFile is the undo history of a file which is saved between sessions.
+type undoFile struct {
+	// Hash is the hash of the contents that the history leads to. The history
+	// is only loaded when the file still has those contents, since it can't be
+	// applied to anything else.
+	Hash string
+	Undo []savedUndoGroup
+	Redo []savedUndoGroup
+}
+
+type savedUndoGroup struct {
+	Changes []savedChange
+	Files   []savedFileChange
+	Cx, Cy  int
+}
+
+type savedChange struct {
+	StartLine, StartCol int
+	EndLine, EndCol     int
+	OldText, NewText    string
+}
+
+type savedFileChange struct {
+	Path             string
+	OldText, NewText string
+}
+
+// undoFilePath returns the path of the file which the undo history of the file
+// at path is saved in.
+func undoFilePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "lte", "undo", hex.EncodeToString(name[:])), nil
+}
+
+// contentHash returns the hash of the rows, which identifies the version of the
+// file that an undo history leads to.
+func contentHash() string {
+	sum := sha256.Sum256(editorRowsToString())
+	return hex.EncodeToString(sum[:])
+}
+
+// editorWriteUndoFile saves the undo history of the file, when the rows match
+// the contents of the file on disk, so that it can be loaded the next time
+// that the file is opened.
+func editorWriteUndoFile() error {
+	if !e.undoFile || e.filename == "" {
+		return nil
+	}
+
+	hash := contentHash()
+	onDisk, err := os.ReadFile(e.filename)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(onDisk); hex.EncodeToString(sum[:]) != hash {
+		return nil
+	}
+
+	path, err := undoFilePath(e.filename)
+	if err != nil {
+		return err
+	}
+
+	if len(undoStack) == 0 && len(redoStack) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	saved := undoFile{
+		Hash: hash,
+		Undo: saveUndoGroups(undoStack[max(len(undoStack)-maxSavedUndoGroups, 0):]),
+		Redo: saveUndoGroups(redoStack[max(len(redoStack)-maxSavedUndoGroups, 0):]),
+	}
+	bb, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, bb, 0o600)
+}
+
+// editorReadUndoFile restores the undo history saved for the file, unless the
+// file has changed since then.
+func editorReadUndoFile() {
+	if !e.undoFile || e.filename == "" {
+		return
+	}
+
+	path, err := undoFilePath(e.filename)
+	if err != nil {
+		return
+	}
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var saved undoFile
+	if err := json.Unmarshal(bb, &saved); err != nil || saved.Hash != contentHash() {
+		return
+	}
+
+	undoStack = loadUndoGroups(saved.Undo)
+	redoStack = loadUndoGroups(saved.Redo)
+}
+
+func saveUndoGroups(groups []undoGroup) []savedUndoGroup {
+	saved := make([]savedUndoGroup, 0, len(groups))
+	for _, group := range groups {
+		g := savedUndoGroup{Cx: group.cx, Cy: group.cy}
+		for _, f := range group.files {
+			g.Files = append(g.Files, savedFileChange{Path: f.path, OldText: f.oldText, NewText: f.newText})
+		}
+		for _, c := range group.changes {
+			g.Changes = append(g.Changes, savedChange{
+				StartLine: c.start.line,
+				StartCol:  c.start.col,
+				EndLine:   c.end.line,
+				EndCol:    c.end.col,
+				OldText:   c.oldText,
+				NewText:   c.newText,
+			})
+		}
+		saved = append(saved, g)
+	}
+
+	return saved
+}
+
+func loadUndoGroups(saved []savedUndoGroup) []undoGroup {
+	groups := make([]undoGroup, 0, len(saved))
+	for _, g := range saved {
+		group := undoGroup{cx: g.Cx, cy: g.Cy}
+		for _, f := range g.Files {
+			group.files = append(group.files, fileChange{path: f.Path, oldText: f.OldText, newText: f.NewText})
+		}
+		for _, c := range g.Changes {
+			group.changes = append(group.changes, bufferChange{
+				start:   bufferPos{c.StartLine, c.StartCol},
+				end:     bufferPos{c.EndLine, c.EndCol},
+				oldText: c.OldText,
+				newText: c.NewText,
+			})
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
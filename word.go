@@ -0,0 +1,88 @@
+package main
+
+import "strings"
+
+// isWordSeparator returns whether c separates words when moving or deleting by
+// word. It extends isSeparator with characters which don't matter for syntax
+// highlighting.
+func isWordSeparator(c byte) bool {
+	return isSpace(c) || isSeparator(rune(c)) || strings.IndexByte("{}\"'`:!&|^?@#\\", c) >= 0
+}
+
+// isSpace returns whether c is a space or tab.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// prevWordStart returns the index of the start of the word before index i of
+// s, skipping any whitespace in between. A run of punctuation counts as a word.
+func prevWordStart(s string, i int) int {
+	for i > 0 && isSpace(s[i-1]) {
+		i--
+	}
+	if i == 0 {
+		return 0
+	}
+
+	punctuation := isWordSeparator(s[i-1])
+	for i > 0 && !isSpace(s[i-1]) && isWordSeparator(s[i-1]) == punctuation {
+		i--
+	}
+
+	return i
+}
+
+// nextWordEnd returns the index after the end of the word after index i of s,
+// skipping any whitespace in between. A run of punctuation counts as a word.
+func nextWordEnd(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	if i == len(s) {
+		return i
+	}
+
+	punctuation := isWordSeparator(s[i])
+	for i < len(s) && !isSpace(s[i]) && isWordSeparator(s[i]) == punctuation {
+		i++
+	}
+
+	return i
+}
+
+// editorDeleteWordBackward deletes the word before the cursor, or the newline
+// at the start of the row.
+func editorDeleteWordBackward() {
+	if editorDeleteSelection() {
+		return
+	}
+	if e.cy >= len(e.row) || e.cx == 0 {
+		editorDelChar()
+		return
+	}
+
+	start := prevWordStart(e.row[e.cy].raw, e.cx)
+	bufferDeleteRange(bufferPos{e.cy, start}, bufferPos{e.cy, e.cx})
+	e.cx = start
+}
+
+// editorDeleteWordForward deletes the word after the cursor, or the newline at
+// the end of the row.
+func editorDeleteWordForward() {
+	if editorDeleteSelection() {
+		return
+	}
+	if e.cy >= len(e.row) {
+		return
+	}
+
+	raw := e.row[e.cy].raw
+	if e.cx == len(raw) {
+		if e.cy+1 < len(e.row) {
+			bufferDeleteRange(bufferPos{e.cy, e.cx}, bufferPos{e.cy + 1, 0})
+		}
+		return
+	}
+
+	bufferDeleteRange(bufferPos{e.cy, e.cx}, bufferPos{e.cy, nextWordEnd(raw, e.cx)})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wordCompletion is the state of completing the word before the cursor with
+// words from the file, which continues while Ctrl-N is pressed repeatedly.
+var wordCompletion struct {
+	active bool
+	// start is the position of the start of the word being completed, and end
+	// is the position after the text which was inserted for it.
+	start, end bufferPos
+	prefix     string
+	candidates []string
+	// index is the index in candidates of the word which was inserted, or
+	// len(candidates) when the prefix has been restored.
+	index int
+}
+
+// editorWordCompletionBoundary is called with every key before it's handled to
+// determine whether it continues cycling through the completions.
+func editorWordCompletionBoundary(key rune) {
+	if key != ctrl('n') {
+		wordCompletion.active = false
+	}
+}
+
+// editorCompleteWord completes the word before the cursor with a word from the
+// file which starts with it, closest to the cursor first. Pressing it again
+// replaces the completion with the next one, and eventually the original
+// word.
+func editorCompleteWord() {
+	c := &wordCompletion
+	if !c.active || c.end != (bufferPos{e.cy, e.cx}) {
+		start := completionWordStart()
+		prefix := editorRowText(e.cy)[start:e.cx]
+		if prefix == "" {
+			editorSetStatusMessage("No word to complete")
+			return
+		}
+
+		candidates := bufferWordsStartingWith(prefix, bufferPos{e.cy, start})
+		if len(candidates) == 0 {
+			editorSetStatusMessage("No completions for %s", prefix)
+			return
+		}
+
+		c.active = true
+		c.start, c.end = bufferPos{e.cy, start}, bufferPos{e.cy, e.cx}
+		c.prefix = prefix
+		c.candidates = candidates
+		c.index = -1
+	}
+
+	c.index = (c.index + 1) % (len(c.candidates) + 1)
+	text := c.prefix
+	if c.index < len(c.candidates) {
+		text = c.candidates[c.index]
+		editorSetStatusMessage("Completion %d of %d", c.index+1, len(c.candidates))
+	} else {
+		editorSetStatusMessage("Back to %s", c.prefix)
+	}
+
+	bufferReplaceRange(c.start, c.end, text)
+	c.end = bufferPos{c.start.line, c.start.col + len(text)}
+	e.cy, e.cx = c.end.line, c.end.col
+}
+
+// bufferWordsStartingWith returns the distinct words in the file which start
+// with prefix and are longer than it, ordered by how close they are to pos.
+// The word at pos isn't included.
+func bufferWordsStartingWith(prefix string, pos bufferPos) []string {
+	type candidate struct {
+		text            string
+		lines, distance int
+	}
+
+	var found []candidate
+	for line, row := range e.row {
+		for _, w := range rowWords(row.raw) {
+			if line == pos.line && w.start == pos.col {
+				continue
+			}
+			if len(w.text) > len(prefix) && strings.HasPrefix(w.text, prefix) {
+				found = append(found, candidate{w.text, max(line-pos.line, pos.line-line), max(w.start-pos.col, pos.col-w.start)})
+			}
+		}
+	}
+
+	slices.SortStableFunc(found, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(a.lines, b.lines), cmp.Compare(a.distance, b.distance))
+	})
+
+	var words []string
+	seen := make(map[string]bool)
+	for _, c := range found {
+		if !seen[c.text] {
+			seen[c.text] = true
+			words = append(words, c.text)
+		}
+	}
+
+	return words
+}
+
+// rowWord is a word in a row, and the index that it starts at.
+type rowWord struct {
+	text  string
+	start int
+}
+
+// rowWords returns the words in raw which are made up of letters, digits, and
+// underscores.
+func rowWords(raw string) []rowWord {
+	var words []rowWord
+	start := -1
+	for i := 0; i <= len(raw); {
+		r, size := utf8.DecodeRuneInString(raw[i:])
+		inWord := i < len(raw) && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			words = append(words, rowWord{raw[start:i], start})
+			start = -1
+		}
+
+		if i == len(raw) {
+			break
+		}
+		i += size
+	}
+
+	return words
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// yamlListMarkerHighlights are used to highlight the markers of list items
+// based on how deeply they're nested, to make the structure of the document
+// easier to follow.
+var yamlListMarkerHighlights = []editorHighlight{
+	highlightKeyword1,
+	highlightKeyword2,
+	highlightLink,
+}
+
+var yamlConstants = []string{"true", "false", "yes", "no", "on", "off", "null", "~"}
+
+// highlightYAML highlights keys, anchors, comments, strings and list markers in
+// a row of a YAML file.
+func highlightYAML(row *editorRow) {
+	fillHighlight(row, 0, len(row.render), highlightNormal)
+
+	s := row.render
+	trimmed := strings.TrimLeft(s, " ")
+	i := len(s) - len(trimmed)
+
+	// Document start / end markers
+	if trimmed == "---" || trimmed == "..." || strings.HasPrefix(trimmed, "--- ") {
+		fillHighlight(row, i, i+3, highlightKeyword1)
+		i += 3
+	}
+
+	for s[i:] == "-" || strings.HasPrefix(s[i:], "- ") {
+		// Nesting is based on the column of the marker, assuming that each level
+		// is indented by two spaces.
+		depth := i / 2
+		row.highlight[i] = yamlListMarkerHighlights[depth%len(yamlListMarkerHighlights)]
+
+		i++
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+	}
+
+	if colon := yamlKeyEnd(s, i); colon > i {
+		fillHighlight(row, i, colon, highlightKey)
+		i = colon + 1
+	}
+
+	highlightYAMLValue(row, i)
+}
+
+// yamlKeyEnd returns the index of the colon which ends the key starting at
+// index i of s, or i if there isn't a key.
+func yamlKeyEnd(s string, i int) int {
+	if i >= len(s) || strings.IndexByte("#&*!|>[{%@`", s[i]) >= 0 {
+		return i
+	}
+
+	end := i
+	if s[i] == '"' || s[i] == '\'' {
+		closing := strings.IndexByte(s[i+1:], s[i])
+		if closing < 0 {
+			return i
+		}
+		end = i + 1 + closing + 1
+	} else {
+		colon := strings.Index(s[i:], ": ")
+		if colon < 0 && strings.HasSuffix(s, ":") {
+			colon = len(s) - 1 - i
+		}
+		if colon < 0 || strings.Contains(s[i:i+colon], " #") {
+			return i
+		}
+		end = i + colon
+	}
+
+	if end < len(s) && s[end] == ':' && (end+1 == len(s) || s[end+1] == ' ') {
+		return end
+	}
+
+	return i
+}
+
+// highlightYAMLValue highlights the value in row starting at index i.
+func highlightYAMLValue(row *editorRow, i int) {
+	s := row.render
+
+	for i < len(s) {
+		atTokenStart := i == 0 || strings.IndexByte(" ,[{", s[i-1]) >= 0
+
+		switch {
+		case s[i] == '#' && (i == 0 || s[i-1] == ' '):
+			fillHighlight(row, i, len(s), highlightComment)
+			return
+		case s[i] == '"' || s[i] == '\'':
+			end := yamlStringEnd(s, i)
+			fillHighlight(row, i, end, highlightString)
+			i = end
+		case atTokenStart && (s[i] == '&' || s[i] == '*'):
+			end := yamlTokenEnd(s, i)
+			fillHighlight(row, i, end, highlightVariable)
+			i = end
+		case atTokenStart && s[i] == '!':
+			end := yamlTokenEnd(s, i)
+			fillHighlight(row, i, end, highlightKeyword2)
+			i = end
+		case atTokenStart && s[i] != ' ':
+			end := yamlTokenEnd(s, i)
+			token := s[i:end]
+			if slices.Contains(yamlConstants, strings.ToLower(token)) {
+				fillHighlight(row, i, end, highlightKeyword2)
+			} else if _, err := strconv.ParseFloat(token, 64); err == nil {
+				fillHighlight(row, i, end, highlightNumber)
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+}
+
+// yamlStringEnd returns the index after the closing quote of the string which
+// starts at index i of s, or len(s) if it isn't closed.
+func yamlStringEnd(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		if quote == '"' && s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == quote {
+			// Single quotes are escaped by doubling them.
+			if quote == '\'' && j+1 < len(s) && s[j+1] == '\'' {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+
+	return len(s)
+}
+
+// yamlTokenEnd returns the index after the end of the token which starts at
+// index i of s.
+func yamlTokenEnd(s string, i int) int {
+	end := strings.IndexAny(s[i:], " ,]}")
+	if end < 0 {
+		return len(s)
+	}
+
+	return i + end
+}